@@ -4,12 +4,16 @@ go 1.16
 
 require (
 	github.com/blang/semver/v4 v4.0.0
+	github.com/containerd/containerd v1.3.4
+	github.com/deislabs/oras v0.8.1
 	github.com/fatih/structtag v1.1.0
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/go-logr/logr v0.3.0
 	github.com/iancoleman/strcase v0.0.0-20191112232945-16388991a334
 	github.com/kr/text v0.1.0
 	github.com/markbates/inflect v1.0.4
 	github.com/maxbrunsfeld/counterfeiter/v6 v6.2.2
+	github.com/mitchellh/copystructure v1.0.0
 	github.com/onsi/ginkgo v1.15.2
 	github.com/onsi/gomega v1.11.0
 	github.com/operator-framework/api v0.8.2-0.20210526151024-41d37db9141f
@@ -31,6 +35,7 @@ require (
 	golang.org/x/sys v0.0.0-20210521090106-6ca3eb03dfc2 // indirect
 	golang.org/x/tools v0.1.1
 	gomodules.xyz/jsonpatch/v3 v3.0.1
+	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776
 	helm.sh/helm/v3 v3.4.1
 	k8s.io/api v0.20.2
 	k8s.io/apiextensions-apiserver v0.20.2
@@ -42,6 +47,7 @@ require (
 	sigs.k8s.io/controller-runtime v0.8.3
 	sigs.k8s.io/controller-tools v0.5.0
 	sigs.k8s.io/kubebuilder/v3 v3.0.0-beta.1
+	sigs.k8s.io/kustomize/api v0.8.0
 	sigs.k8s.io/yaml v1.2.0
 )
 
@@ -31,9 +31,11 @@ import (
 	ctrlpredicate "sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	ansiblestatus "github.com/operator-framework/operator-sdk/internal/ansible/controller/status"
 	"github.com/operator-framework/operator-sdk/internal/ansible/events"
 	"github.com/operator-framework/operator-sdk/internal/ansible/handler"
 	"github.com/operator-framework/operator-sdk/internal/ansible/predicate"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/auth"
 	"github.com/operator-framework/operator-sdk/internal/ansible/runner"
 )
 
@@ -52,6 +54,17 @@ type Options struct {
 	WatchClusterScopedResources bool
 	MaxConcurrentReconciles     int
 	Selector                    metav1.LabelSelector
+	TaskResults                 int
+	Conditions                  []ansiblestatus.ConditionRule
+	KubernetesEvents            events.KubernetesEventOptions
+	ProxyTokens                 *auth.Tokens
+	ProxyCAFile                 string
+	ImpersonateServiceAccount   string
+	ImpersonateNamespace        string
+	// Converter serves a conversion webhook for the served versions of the
+	// GVK at ConversionWebhookPath(GVK), if set. Up to MaxConcurrentReconciles
+	// objects of a conversion review are converted at a time.
+	Converter runner.Converter
 }
 
 // Add - Creates a new ansible operator controller and adds it to the manager
@@ -62,6 +75,10 @@ func Add(mgr manager.Manager, options Options) *controller.Controller {
 		options.EventHandlers = []events.EventHandler{}
 	}
 	eventHandlers := append(options.EventHandlers, events.NewLoggingEventHandler(options.LoggingLevel))
+	if options.KubernetesEvents.Enabled() {
+		recorder := mgr.GetEventRecorderFor(fmt.Sprintf("%v-controller", strings.ToLower(options.GVK.Kind)))
+		eventHandlers = append(eventHandlers, events.NewKubernetesEventHandler(recorder, options.KubernetesEvents))
+	}
 
 	aor := &AnsibleOperatorReconciler{
 		Client:           mgr.GetClient(),
@@ -71,7 +88,14 @@ func Add(mgr manager.Manager, options Options) *controller.Controller {
 		ReconcilePeriod:  options.ReconcilePeriod,
 		ManageStatus:     options.ManageStatus,
 		AnsibleDebugLogs: options.AnsibleDebugLogs,
+		TaskResults:      options.TaskResults,
+		Conditions:       options.Conditions,
 		APIReader:        mgr.GetAPIReader(),
+
+		ProxyTokens:               options.ProxyTokens,
+		ProxyCAFile:               options.ProxyCAFile,
+		ImpersonateServiceAccount: options.ImpersonateServiceAccount,
+		ImpersonateNamespace:      options.ImpersonateNamespace,
 	}
 
 	scheme := mgr.GetScheme()
@@ -118,5 +142,15 @@ func Add(mgr manager.Manager, options Options) *controller.Controller {
 		os.Exit(1)
 	}
 
+	if options.Converter != nil {
+		path := ConversionWebhookPath(options.GVK)
+		mgr.GetWebhookServer().Register(path, &conversionWebhook{
+			converter:     options.Converter,
+			maxConcurrent: options.MaxConcurrentReconciles,
+		})
+		log.Info("Serving conversion webhook", "Options.Group", options.GVK.Group, "Options.Kind",
+			options.GVK.Kind, "path", path)
+	}
+
 	return &c
 }
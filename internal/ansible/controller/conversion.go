@@ -0,0 +1,155 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner"
+)
+
+// conversionTimeout is how long the API server waits for a conversion webhook
+// to respond. Conversions still running then are stopped.
+const conversionTimeout = 30 * time.Second
+
+// ConversionWebhookPath returns the path the conversion webhook of a GVK is
+// served at.
+func ConversionWebhookPath(gvk schema.GroupVersionKind) string {
+	return fmt.Sprintf("/convert-%s-%s", strings.ReplaceAll(gvk.Group, ".", "-"), strings.ToLower(gvk.Kind))
+}
+
+// conversionWebhook serves ConversionReviews for the served versions of a GVK
+// by converting each object with a Converter, running up to maxConcurrent
+// conversions at a time.
+type conversionWebhook struct {
+	converter     runner.Converter
+	maxConcurrent int
+}
+
+var _ http.Handler = &conversionWebhook{}
+
+func (c *conversionWebhook) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	review := apiextensionsv1.ConversionReview{}
+	if err := json.NewDecoder(req.Body).Decode(&review); err != nil {
+		log.Error(err, "Failed to decode conversion review")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "conversion review has no request", http.StatusBadRequest)
+		return
+	}
+
+	// The request and response of v1beta1 ConversionReviews are the same as
+	// those of v1, so the review is answered in the version it was sent in.
+	ctx, cancel := context.WithTimeout(req.Context(), conversionTimeout)
+	defer cancel()
+	review.Response = c.convert(ctx, review.Request)
+	review.Request = nil
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		log.Error(err, "Failed to write conversion review")
+	}
+}
+
+// convert converts the objects of req concurrently. Once a conversion fails,
+// the others are stopped and the failure is returned.
+func (c *conversionWebhook) convert(ctx context.Context,
+	req *apiextensionsv1.ConversionRequest) *apiextensionsv1.ConversionResponse {
+	resp := &apiextensionsv1.ConversionResponse{UID: req.UID}
+	objects := make([]*unstructured.Unstructured, len(req.Objects))
+	for i, o := range req.Objects {
+		objects[i] = &unstructured.Unstructured{}
+		if err := objects[i].UnmarshalJSON(o.Raw); err != nil {
+			resp.Result = conversionFailure(err)
+			return resp
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var mu sync.Mutex
+	var failure error
+	fail := func(u *unstructured.Unstructured, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failure == nil {
+			failure = fmt.Errorf("failed to convert %s/%s: %w", u.GetNamespace(), u.GetName(), err)
+		}
+		cancel()
+	}
+
+	maxConcurrent := c.maxConcurrent
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	sem := make(chan struct{}, maxConcurrent)
+	converted := make([]*unstructured.Unstructured, len(objects))
+	var wg sync.WaitGroup
+	for i, u := range objects {
+		if u.GetAPIVersion() == req.DesiredAPIVersion {
+			converted[i] = u
+			continue
+		}
+		wg.Add(1)
+		go func(i int, u *unstructured.Unstructured) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				fail(u, ctx.Err())
+				return
+			}
+			ident := strconv.Itoa(rand.Int())
+			var err error
+			if converted[i], err = c.converter.Convert(ctx, ident, u, req.DesiredAPIVersion); err != nil {
+				log.Error(err, "Failed to convert object", "job", ident, "name", u.GetName(),
+					"namespace", u.GetNamespace(), "apiVersion", u.GetAPIVersion(),
+					"desiredAPIVersion", req.DesiredAPIVersion)
+				fail(u, err)
+			}
+		}(i, u)
+	}
+	wg.Wait()
+
+	if failure != nil {
+		resp.Result = conversionFailure(failure)
+		return resp
+	}
+	for _, u := range converted {
+		resp.ConvertedObjects = append(resp.ConvertedObjects, runtime.RawExtension{Object: u})
+	}
+	resp.Result = metav1.Status{Status: metav1.StatusSuccess}
+	return resp
+}
+
+func conversionFailure(err error) metav1.Status {
+	return metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+}
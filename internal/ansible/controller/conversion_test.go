@@ -0,0 +1,177 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// fakeConverter fails with err, and waits until the conversion is stopped for
+// objects named "hang".
+type fakeConverter struct {
+	err error
+
+	mu        sync.Mutex
+	converted int
+}
+
+func (f *fakeConverter) Convert(ctx context.Context, _ string, u *unstructured.Unstructured,
+	desiredAPIVersion string) (*unstructured.Unstructured, error) {
+	if u.GetName() == "hang" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.converted++
+	converted := u.DeepCopy()
+	converted.SetAPIVersion(desiredAPIVersion)
+	return converted, nil
+}
+
+func reviewConversion(t *testing.T, c *fakeConverter, apiVersions ...string) *apiextensionsv1.ConversionResponse {
+	review := apiextensionsv1.ConversionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "ConversionReview"},
+		Request: &apiextensionsv1.ConversionRequest{
+			UID:               types.UID("uid"),
+			DesiredAPIVersion: "cache.example.com/v1",
+		},
+	}
+	for _, apiVersion := range apiVersions {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(apiVersion)
+		u.SetKind("Memcached")
+		u.SetName("test")
+		raw, err := u.MarshalJSON()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		review.Request.Objects = append(review.Request.Objects, runtime.RawExtension{Raw: raw})
+	}
+	body, err := json.Marshal(review)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	h := &conversionWebhook{converter: c, maxConcurrent: 2}
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/convert", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code: %v", rec.Code)
+	}
+	got := apiextensionsv1.ConversionReview{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if got.Kind != "ConversionReview" || got.Request != nil || got.Response == nil {
+		t.Fatalf("Unexpected conversion review: %+v", got)
+	}
+	if got.Response.UID != "uid" {
+		t.Fatalf("Unexpected UID: %v", got.Response.UID)
+	}
+	return got.Response
+}
+
+func TestConversionWebhook(t *testing.T) {
+	c := &fakeConverter{}
+	resp := reviewConversion(t, c, "cache.example.com/v1alpha1", "cache.example.com/v1")
+	if resp.Result.Status != metav1.StatusSuccess {
+		t.Fatalf("Unexpected result: %+v", resp.Result)
+	}
+	if c.converted != 1 {
+		t.Fatalf("Unexpected number of conversions: %v, objects of the desired version must not be converted",
+			c.converted)
+	}
+	if len(resp.ConvertedObjects) != 2 {
+		t.Fatalf("Unexpected converted objects: %v", resp.ConvertedObjects)
+	}
+	for _, o := range resp.ConvertedObjects {
+		u := &unstructured.Unstructured{}
+		if err := u.UnmarshalJSON(o.Raw); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if u.GetAPIVersion() != "cache.example.com/v1" || u.GetName() != "test" {
+			t.Fatalf("Unexpected converted object: %+v", u)
+		}
+	}
+
+	resp = reviewConversion(t, &fakeConverter{err: errors.New("boom")}, "cache.example.com/v1alpha1")
+	if resp.Result.Status != metav1.StatusFailure || resp.Result.Message != "failed to convert /test: boom" {
+		t.Fatalf("Unexpected result: %+v", resp.Result)
+	}
+	if len(resp.ConvertedObjects) != 0 {
+		t.Fatalf("Unexpected converted objects: %v", resp.ConvertedObjects)
+	}
+}
+
+func TestConversionWebhookStop(t *testing.T) {
+	request := func(names ...string) *apiextensionsv1.ConversionRequest {
+		req := &apiextensionsv1.ConversionRequest{DesiredAPIVersion: "cache.example.com/v1"}
+		for _, name := range names {
+			u := &unstructured.Unstructured{}
+			u.SetAPIVersion("cache.example.com/v1alpha1")
+			u.SetKind("Memcached")
+			u.SetName(name)
+			raw, err := u.MarshalJSON()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			req.Objects = append(req.Objects, runtime.RawExtension{Raw: raw})
+		}
+		return req
+	}
+
+	// A failed conversion stops the others.
+	h := &conversionWebhook{converter: &fakeConverter{err: errors.New("boom")}, maxConcurrent: 2}
+	resp := h.convert(context.Background(), request("hang", "test"))
+	if resp.Result.Status != metav1.StatusFailure || resp.Result.Message != "failed to convert /test: boom" {
+		t.Fatalf("Unexpected result: %+v", resp.Result)
+	}
+
+	// Conversions are stopped at the deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	h = &conversionWebhook{converter: &fakeConverter{}, maxConcurrent: 1}
+	resp = h.convert(ctx, request("hang", "hang"))
+	if resp.Result.Status != metav1.StatusFailure ||
+		resp.Result.Message != "failed to convert /hang: "+context.DeadlineExceeded.Error() {
+		t.Fatalf("Unexpected result: %+v", resp.Result)
+	}
+}
+
+func TestConversionWebhookPath(t *testing.T) {
+	path := ConversionWebhookPath(schema.GroupVersionKind{Group: "cache.example.com", Version: "v1", Kind: "Memcached"})
+	if path != "/convert-cache-example-com-memcached" {
+		t.Fatalf("Unexpected path: %v", path)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
@@ -39,6 +40,7 @@ import (
 	ansiblestatus "github.com/operator-framework/operator-sdk/internal/ansible/controller/status"
 	"github.com/operator-framework/operator-sdk/internal/ansible/events"
 	"github.com/operator-framework/operator-sdk/internal/ansible/metrics"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/auth"
 	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
 	"github.com/operator-framework/operator-sdk/internal/ansible/runner"
 	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
@@ -61,6 +63,54 @@ type AnsibleOperatorReconciler struct {
 	ReconcilePeriod  time.Duration
 	ManageStatus     bool
 	AnsibleDebugLogs bool
+	TaskResults      int
+	// Conditions derive custom conditions of CRs from each run.
+	Conditions []ansiblestatus.ConditionRule
+	// ProxyTokens issues the tokens that runs authenticate to the proxy with.
+	// Runs reach the proxy over plain HTTP, identified by their owner only, if
+	// it is nil.
+	ProxyTokens *auth.Tokens
+	// ProxyCAFile is the CA certificate that runs verify the proxy with.
+	ProxyCAFile string
+	// ImpersonateServiceAccount is the ServiceAccount that runs make requests
+	// as, in ImpersonateNamespace or else the namespace of the CR.
+	ImpersonateServiceAccount string
+	ImpersonateNamespace      string
+}
+
+// createKubeconfig creates the kubeconfig that the run for u reaches the proxy
+// with, and returns a function that revokes the run's access to the proxy.
+func (r *AnsibleOperatorReconciler) createKubeconfig(ownerRef metav1.OwnerReference,
+	u *unstructured.Unstructured) (*os.File, func(), error) {
+	if r.ProxyTokens == nil {
+		kc, err := kubeconfig.Create(ownerRef, "http://localhost:8888", u.GetNamespace())
+		return kc, func() {}, err
+	}
+
+	id := auth.Identity{
+		Owner: kubeconfig.NamespacedOwnerReference{OwnerReference: ownerRef, Namespace: u.GetNamespace()},
+	}
+	if r.ImpersonateServiceAccount != "" {
+		namespace := r.ImpersonateNamespace
+		if namespace == "" {
+			namespace = u.GetNamespace()
+		}
+		if namespace == "" {
+			return nil, nil, fmt.Errorf("namespace of ServiceAccount %q to impersonate is required for cluster-scoped resources",
+				r.ImpersonateServiceAccount)
+		}
+		id.ImpersonateUser = auth.ServiceAccountUser(namespace, r.ImpersonateServiceAccount)
+	}
+	token, err := r.ProxyTokens.Issue(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	kc, err := kubeconfig.CreateWithToken(token, "https://localhost:8888", u.GetNamespace(), r.ProxyCAFile)
+	if err != nil {
+		r.ProxyTokens.Revoke(token)
+		return nil, nil, err
+	}
+	return kc, func() { r.ProxyTokens.Revoke(token) }, nil
 }
 
 // Reconcile - handle the event.
@@ -143,7 +193,7 @@ func (r *AnsibleOperatorReconciler) Reconcile(ctx context.Context, request recon
 		UID:        u.GetUID(),
 	}
 
-	kc, err := kubeconfig.Create(ownerRef, "http://localhost:8888", u.GetNamespace())
+	kc, revoke, err := r.createKubeconfig(ownerRef, u)
 	if err != nil {
 		errmark := r.markError(ctx, request.NamespacedName, u, "Unable to run reconciliation")
 		if errmark != nil {
@@ -153,10 +203,13 @@ func (r *AnsibleOperatorReconciler) Reconcile(ctx context.Context, request recon
 		return reconcileResult, err
 	}
 	defer func() {
+		revoke()
 		if err := os.Remove(kc.Name()); err != nil {
 			logger.Error(err, "Failed to remove generated kubeconfig file")
 		}
 	}()
+	// The generation that is reconciled is that of the CR the run is passed.
+	generation := u.GetGeneration()
 	result, err := r.Runner.Run(ident, u, kc.Name())
 	if err != nil {
 		errmark := r.markError(ctx, request.NamespacedName, u, "Unable to run reconciliation")
@@ -170,9 +223,26 @@ func (r *AnsibleOperatorReconciler) Reconcile(ctx context.Context, request recon
 	// iterate events from ansible, looking for the final one
 	statusEvent := eventapi.StatusJobEvent{}
 	failureMessages := eventapi.FailureMessages{}
+	var taskResults []ansiblestatus.TaskResult
+	conditions := ansiblestatus.NewConditionEvaluator(r.Conditions)
+	handlerQueues := r.handleEvents(ident, u)
+	defer func() {
+		for _, q := range handlerQueues {
+			q.close()
+		}
+	}()
 	for event := range result.Events() {
-		for _, eHandler := range r.EventHandlers {
-			go eHandler.Handle(ident, u, event)
+		for _, q := range handlerQueues {
+			q.push(event)
+		}
+		conditions.Observe(event)
+		if r.TaskResults > 0 {
+			if tr, ok := ansiblestatus.NewTaskResultFromJobEvent(event); ok {
+				taskResults = append(taskResults, tr)
+				if len(taskResults) > r.TaskResults {
+					taskResults = taskResults[1:]
+				}
+			}
 		}
 		if event.Event == eventapi.EventPlaybookOnStats {
 			// convert to StatusJobEvent; would love a better way to do this
@@ -255,7 +325,8 @@ func (r *AnsibleOperatorReconciler) Reconcile(ctx context.Context, request recon
 		}
 	}
 	if r.ManageStatus {
-		errmark := r.markDone(ctx, request.NamespacedName, u, statusEvent, failureMessages)
+		errmark := r.markDone(ctx, request.NamespacedName, u, statusEvent, failureMessages, taskResults,
+			conditions.Conditions(), generation)
 		if errmark != nil {
 			logger.Error(errmark, "Failed to mark status done")
 		}
@@ -273,6 +344,69 @@ func (r *AnsibleOperatorReconciler) Reconcile(ctx context.Context, request recon
 	return reconcileResult, nil
 }
 
+// handleEvents starts passing the events of a run to every event handler.
+// Each handler handles the events in order, in a goroutine of its own, so that
+// handlers neither block reading the events nor each other.
+func (r *AnsibleOperatorReconciler) handleEvents(ident string, u *unstructured.Unstructured) []*eventQueue {
+	queues := make([]*eventQueue, 0, len(r.EventHandlers))
+	for _, eHandler := range r.EventHandlers {
+		q := &eventQueue{ready: make(chan struct{}, 1)}
+		queues = append(queues, q)
+		go q.handle(eHandler, ident, u.DeepCopy())
+	}
+	return queues
+}
+
+// eventQueue is an unbounded queue of the events of a run for an event
+// handler, so that a handler that is behind misses no events.
+type eventQueue struct {
+	mu     sync.Mutex
+	events []eventapi.JobEvent
+	closed bool
+	// ready is signaled when events are pushed or the queue is closed.
+	ready chan struct{}
+}
+
+func (q *eventQueue) push(event eventapi.JobEvent) {
+	q.mu.Lock()
+	q.events = append(q.events, event)
+	q.mu.Unlock()
+	q.signal()
+}
+
+// close closes the queue once the run has no more events. The events already
+// in the queue are still handled.
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *eventQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// handle passes the events in the queue to eHandler until the queue is closed.
+func (q *eventQueue) handle(eHandler events.EventHandler, ident string, u *unstructured.Unstructured) {
+	for {
+		q.mu.Lock()
+		queued, closed := q.events, q.closed
+		q.events = nil
+		q.mu.Unlock()
+		for _, event := range queued {
+			eHandler.Handle(ident, u, event)
+		}
+		if closed {
+			return
+		}
+		<-q.ready
+	}
+}
+
 func printEventStats(statusEvent eventapi.StatusJobEvent, u *unstructured.Unstructured) {
 	if len(statusEvent.StdOut) > 0 {
 		str := fmt.Sprintf("Ansible Task Status Event StdOut (%s, %s/%s)", u.GroupVersionKind(), u.GetName(), u.GetNamespace())
@@ -359,7 +493,8 @@ func (r *AnsibleOperatorReconciler) markError(ctx context.Context, nn types.Name
 }
 
 func (r *AnsibleOperatorReconciler) markDone(ctx context.Context, nn types.NamespacedName, u *unstructured.Unstructured,
-	statusEvent eventapi.StatusJobEvent, failureMessages eventapi.FailureMessages) error {
+	statusEvent eventapi.StatusJobEvent, failureMessages eventapi.FailureMessages,
+	taskResults []ansiblestatus.TaskResult, conditions []ansiblestatus.Condition, observedGeneration int64) error {
 
 	logger := logf.Log.WithName("markDone")
 	// Get the latest resource to prevent updating a stale status.
@@ -403,6 +538,13 @@ func (r *AnsibleOperatorReconciler) markDone(ctx context.Context, nn types.Names
 		ansiblestatus.RemoveCondition(&crStatus, ansiblestatus.FailureConditionType)
 		ansiblestatus.SetCondition(&crStatus, *c)
 	}
+	if r.TaskResults > 0 {
+		crStatus.TaskResults = taskResults
+	}
+	for _, c := range conditions {
+		ansiblestatus.SetCondition(&crStatus, c)
+	}
+	crStatus.ObservedGeneration = observedGeneration
 	// This needs the status subresource to be enabled by default.
 	u.Object["status"] = crStatus.GetJSONMap()
 
@@ -42,6 +42,17 @@ func TestReconcile(t *testing.T) {
 		Version: "v1beta1",
 	}
 	eventTime := time.Now()
+	// Event handlers that block must not block reconciles.
+	unblock := make(chan struct{})
+	defer close(unblock)
+	blockedEvents := make([]eventapi.JobEvent, 200)
+	for i := range blockedEvents {
+		blockedEvents[i] = eventapi.JobEvent{Event: eventapi.EventRunnerOnOk}
+	}
+	blockedEvents = append(blockedEvents, eventapi.JobEvent{
+		Event:   eventapi.EventPlaybookOnStats,
+		Created: eventapi.EventTime{Time: eventTime},
+	})
 	testCases := []struct {
 		Name            string
 		GVK             schema.GroupVersionKind
@@ -54,6 +65,8 @@ func TestReconcile(t *testing.T) {
 		Request         reconcile.Request
 		ShouldError     bool
 		ManageStatus    bool
+		TaskResults     int
+		Conditions      []ansiblestatus.ConditionRule
 	}{
 		{
 			Name:            "cr not found",
@@ -132,6 +145,63 @@ func TestReconcile(t *testing.T) {
 				},
 			},
 		},
+		{
+			Name:            "completed reconcile with blocked event handler",
+			GVK:             gvk,
+			ReconcilePeriod: 5 * time.Second,
+			ManageStatus:    true,
+			Runner: &fake.Runner{
+				JobEvents: blockedEvents,
+			},
+			EventHandlers: []events.EventHandler{blockingEventHandler(unblock)},
+			Client: fakeclient.NewClientBuilder().WithObjects(&unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"name":      "reconcile",
+						"namespace": "default",
+					},
+					"apiVersion": "operator-sdk/v1beta1",
+					"kind":       "Testing",
+				},
+			}).Build(),
+			Result: reconcile.Result{
+				RequeueAfter: 5 * time.Second,
+			},
+			Request: reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      "reconcile",
+					Namespace: "default",
+				},
+			},
+			ExpectedObject: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"name":      "reconcile",
+						"namespace": "default",
+					},
+					"apiVersion": "operator-sdk/v1beta1",
+					"kind":       "Testing",
+					"spec":       map[string]interface{}{},
+					"status": map[string]interface{}{
+						"conditions": []interface{}{
+							map[string]interface{}{
+								"status": "True",
+								"type":   "Running",
+								"ansibleResult": map[string]interface{}{
+									"changed":    int64(0),
+									"failures":   int64(0),
+									"ok":         int64(0),
+									"skipped":    int64(0),
+									"completion": eventTime.Format("2006-01-02T15:04:05.99999999"),
+								},
+								"message": "Awaiting next reconciliation",
+								"reason":  "Successful",
+							},
+						},
+					},
+				},
+			},
+		},
 		{
 			Name:         "Failure event runner on failed with manageStatus == true",
 			GVK:          gvk,
@@ -206,6 +276,193 @@ func TestReconcile(t *testing.T) {
 			},
 			ShouldError: true,
 		},
+		{
+			Name:         "Task results with manageStatus == true",
+			GVK:          gvk,
+			ManageStatus: true,
+			TaskResults:  1,
+			Runner: &fake.Runner{
+				JobEvents: []eventapi.JobEvent{
+					eventapi.JobEvent{
+						Event: eventapi.EventRunnerOnOk,
+						EventData: map[string]interface{}{
+							"task": "first task",
+						},
+					},
+					eventapi.JobEvent{
+						Event: eventapi.EventRunnerOnFailed,
+						EventData: map[string]interface{}{
+							"task": "second task",
+							"res": map[string]interface{}{
+								"msg": "new failure message",
+							},
+						},
+					},
+					eventapi.JobEvent{
+						Event:   eventapi.EventPlaybookOnStats,
+						Created: eventapi.EventTime{Time: eventTime},
+					},
+				},
+			},
+			Client: fakeclient.NewClientBuilder().WithObjects(&unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"name":      "reconcile",
+						"namespace": "default",
+					},
+					"apiVersion": "operator-sdk/v1beta1",
+					"kind":       "Testing",
+					"spec":       map[string]interface{}{},
+				},
+			}).Build(),
+			Request: reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      "reconcile",
+					Namespace: "default",
+				},
+			},
+			ExpectedObject: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"name":      "reconcile",
+						"namespace": "default",
+					},
+					"apiVersion": "operator-sdk/v1beta1",
+					"kind":       "Testing",
+					"spec":       map[string]interface{}{},
+					"status": map[string]interface{}{
+						"conditions": []interface{}{
+							map[string]interface{}{
+								"status":  "False",
+								"type":    "Running",
+								"message": "Running reconciliation",
+								"reason":  "Running",
+							},
+							map[string]interface{}{
+								"status": "True",
+								"type":   "Failure",
+								"ansibleResult": map[string]interface{}{
+									"changed":    int64(0),
+									"failures":   int64(0),
+									"ok":         int64(0),
+									"skipped":    int64(0),
+									"completion": eventTime.Format("2006-01-02T15:04:05.99999999"),
+								},
+								"message": "new failure message",
+								"reason":  "Failed",
+							},
+						},
+						"taskResults": []interface{}{
+							map[string]interface{}{
+								"name":     "second task",
+								"changed":  false,
+								"failed":   true,
+								"duration": "0s",
+								"message":  "new failure message",
+							},
+						},
+					},
+				},
+			},
+			ShouldError: true,
+		},
+		{
+			Name:         "Custom conditions with manageStatus == true",
+			GVK:          gvk,
+			ManageStatus: true,
+			Conditions: []ansiblestatus.ConditionRule{
+				{Type: "Deployed", Task: "deploy"},
+				{Type: "Ready", Variable: "ready"},
+				{Type: "Upgraded", Task: "upgrade"},
+			},
+			Runner: &fake.Runner{
+				JobEvents: []eventapi.JobEvent{
+					eventapi.JobEvent{
+						Event: eventapi.EventRunnerOnOk,
+						EventData: map[string]interface{}{
+							"task": "deploy",
+							"res": map[string]interface{}{
+								"msg": "deployed",
+							},
+						},
+					},
+					eventapi.JobEvent{
+						Event:   eventapi.EventPlaybookOnStats,
+						Created: eventapi.EventTime{Time: eventTime},
+						EventData: map[string]interface{}{
+							"artifact_data": map[string]interface{}{
+								"ready": true,
+							},
+						},
+					},
+				},
+			},
+			Client: fakeclient.NewClientBuilder().WithObjects(&unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"name":       "reconcile",
+						"namespace":  "default",
+						"generation": int64(3),
+					},
+					"apiVersion": "operator-sdk/v1beta1",
+					"kind":       "Testing",
+					"spec":       map[string]interface{}{},
+				},
+			}).Build(),
+			Request: reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      "reconcile",
+					Namespace: "default",
+				},
+			},
+			ExpectedObject: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"name":      "reconcile",
+						"namespace": "default",
+					},
+					"apiVersion": "operator-sdk/v1beta1",
+					"kind":       "Testing",
+					"spec":       map[string]interface{}{},
+					"status": map[string]interface{}{
+						"conditions": []interface{}{
+							map[string]interface{}{
+								"status": "True",
+								"type":   "Running",
+								"ansibleResult": map[string]interface{}{
+									"changed":    int64(0),
+									"failures":   int64(0),
+									"ok":         int64(0),
+									"skipped":    int64(0),
+									"completion": eventTime.Format("2006-01-02T15:04:05.99999999"),
+								},
+								"message": "Awaiting next reconciliation",
+								"reason":  "Successful",
+							},
+							map[string]interface{}{
+								"status":  "True",
+								"type":    "Deployed",
+								"message": "deployed",
+								"reason":  "TaskSucceeded",
+							},
+							map[string]interface{}{
+								"status":  "True",
+								"type":    "Ready",
+								"message": "ready is true",
+								"reason":  "VariableSet",
+							},
+							map[string]interface{}{
+								"status":  "Unknown",
+								"type":    "Upgraded",
+								"message": `Task "upgrade" did not run`,
+								"reason":  "NotObserved",
+							},
+						},
+						"observedGeneration": int64(3),
+					},
+				},
+			},
+		},
 		{
 			Name:         "Failure event runner on failed",
 			GVK:          gvk,
@@ -544,6 +801,8 @@ func TestReconcile(t *testing.T) {
 				EventHandlers:   tc.EventHandlers,
 				ReconcilePeriod: tc.ReconcilePeriod,
 				ManageStatus:    tc.ManageStatus,
+				TaskResults:     tc.TaskResults,
+				Conditions:      tc.Conditions,
 			}
 			result, err := aor.Reconcile(context.TODO(), tc.Request)
 			if err != nil && !tc.ShouldError {
@@ -575,6 +834,14 @@ func TestReconcile(t *testing.T) {
 				expectedStatus := ansiblestatus.CreateFromMap(sMap)
 				sMap, _ = actualObject.Object["status"].(map[string]interface{})
 				actualStatus := ansiblestatus.CreateFromMap(sMap)
+				if !reflect.DeepEqual(expectedStatus.TaskResults, actualStatus.TaskResults) {
+					t.Fatalf("Status task results not the same\nexpected: %v\nactual: %v",
+						expectedStatus.TaskResults, actualStatus.TaskResults)
+				}
+				if expectedStatus.ObservedGeneration != actualStatus.ObservedGeneration {
+					t.Fatalf("Status observed generation not the same\nexpected: %v\nactual: %v",
+						expectedStatus.ObservedGeneration, actualStatus.ObservedGeneration)
+				}
 				if len(expectedStatus.Conditions) != len(actualStatus.Conditions) {
 					t.Fatalf("Status conditions not the same\nexpected: %v\nactual: %v", expectedStatus,
 						actualStatus)
@@ -600,3 +867,72 @@ func TestReconcile(t *testing.T) {
 		})
 	}
 }
+
+func TestReconcileEventHandlers(t *testing.T) {
+	gvk := schema.GroupVersionKind{Kind: "Testing", Group: "operator-sdk", Version: "v1beta1"}
+	jobEvents := make([]eventapi.JobEvent, 300)
+	for i := range jobEvents {
+		jobEvents[i] = eventapi.JobEvent{Event: eventapi.EventRunnerOnOk}
+	}
+	jobEvents = append(jobEvents, eventapi.JobEvent{Event: eventapi.EventPlaybookOnStats})
+	cr := &unstructured.Unstructured{}
+	cr.SetAPIVersion("operator-sdk/v1beta1")
+	cr.SetKind("Testing")
+	cr.SetNamespace("default")
+	cr.SetName("reconcile")
+
+	// The blocked handler is behind for the whole run.
+	unblock := make(chan struct{})
+	blocked := recordingEventHandler{unblock: unblock, events: make(chan eventapi.JobEvent, len(jobEvents))}
+	unblocked := make(chan struct{})
+	close(unblocked)
+	other := recordingEventHandler{unblock: unblocked, events: make(chan eventapi.JobEvent, len(jobEvents))}
+	aor := &controller.AnsibleOperatorReconciler{
+		GVK:           gvk,
+		Runner:        &fake.Runner{JobEvents: jobEvents},
+		Client:        fakeclient.NewClientBuilder().WithObjects(cr).Build(),
+		EventHandlers: []events.EventHandler{blocked, other},
+	}
+	aor.APIReader = aor.Client
+	if _, err := aor.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{
+		Name:      "reconcile",
+		Namespace: "default",
+	}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	close(unblock)
+
+	// Every handler handles every event of the run in order, ending with the
+	// stats event.
+	for _, h := range []recordingEventHandler{blocked, other} {
+		for i := range jobEvents {
+			select {
+			case e := <-h.events:
+				if e.Event != jobEvents[i].Event {
+					t.Fatalf("Unexpected event %d: %v expected: %v", i, e.Event, jobEvents[i].Event)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Event %d was not handled", i)
+			}
+		}
+	}
+}
+
+// recordingEventHandler handles events by sending them to events, once
+// unblock is closed.
+type recordingEventHandler struct {
+	unblock <-chan struct{}
+	events  chan eventapi.JobEvent
+}
+
+func (h recordingEventHandler) Handle(_ string, _ *unstructured.Unstructured, e eventapi.JobEvent) {
+	<-h.unblock
+	h.events <- e
+}
+
+// blockingEventHandler handles events by waiting until it is closed.
+type blockingEventHandler chan struct{}
+
+func (h blockingEventHandler) Handle(string, *unstructured.Unstructured, eventapi.JobEvent) {
+	<-h
+}
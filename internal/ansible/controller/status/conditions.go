@@ -0,0 +1,151 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+)
+
+const (
+	// TaskSucceededReason - Condition is derived from a task that succeeded
+	TaskSucceededReason = "TaskSucceeded"
+	// TaskFailedReason - Condition is derived from a task that failed
+	TaskFailedReason = "TaskFailed"
+	// VariableSetReason - Condition is derived from a variable set by a run
+	VariableSetReason = "VariableSet"
+	// NotObservedReason - Condition is unknown because the last run did not run
+	// its task or set its variable
+	NotObservedReason = "NotObserved"
+)
+
+// ConditionRule - derives a custom condition of a CR from each run, from the
+// result of the task named Task, or from the value of the Variable that the
+// run set with set_stats.
+type ConditionRule struct {
+	Type     ConditionType
+	Task     string
+	Variable string
+}
+
+// ConditionEvaluator - derives the custom conditions of a CR from the events
+// of a run.
+type ConditionEvaluator struct {
+	rules    []ConditionRule
+	observed map[ConditionType]Condition
+}
+
+// NewConditionEvaluator - creates a ConditionEvaluator for rules.
+func NewConditionEvaluator(rules []ConditionRule) *ConditionEvaluator {
+	return &ConditionEvaluator{rules: rules, observed: map[ConditionType]Condition{}}
+}
+
+// Observe derives the conditions of the rules that je is the task result or
+// the stats of.
+func (e *ConditionEvaluator) Observe(je eventapi.JobEvent) {
+	switch je.Event {
+	case eventapi.EventRunnerOnOk, eventapi.EventRunnerOnFailed:
+		task, _ := je.EventData["task"].(string)
+		for _, rule := range e.rules {
+			if rule.Task == "" || rule.Task != task {
+				continue
+			}
+			if je.Event == eventapi.EventRunnerOnFailed {
+				e.observe(rule.Type, v1.ConditionFalse, TaskFailedReason, je.GetFailedPlaybookMessage())
+				continue
+			}
+			res, _ := je.EventData["res"].(map[string]interface{})
+			message, _ := res["msg"].(string)
+			e.observe(rule.Type, v1.ConditionTrue, TaskSucceededReason, message)
+		}
+	case eventapi.EventPlaybookOnStats:
+		variables, _ := je.EventData["artifact_data"].(map[string]interface{})
+		for _, rule := range e.rules {
+			if rule.Variable == "" {
+				continue
+			}
+			if value, ok := variables[rule.Variable]; ok {
+				status, reason, message := conditionFromVariable(rule.Variable, value)
+				e.observe(rule.Type, status, reason, message)
+			}
+		}
+	}
+}
+
+func (e *ConditionEvaluator) observe(condType ConditionType, status v1.ConditionStatus, reason, message string) {
+	e.observed[condType] = *NewCondition(condType, status, nil, reason, truncateMessage(message))
+}
+
+// Conditions returns the condition of each rule, in order. Conditions that
+// were not observed in the run are unknown.
+func (e *ConditionEvaluator) Conditions() []Condition {
+	conditions := make([]Condition, 0, len(e.rules))
+	for _, rule := range e.rules {
+		c, ok := e.observed[rule.Type]
+		if !ok {
+			c = *NewCondition(rule.Type, v1.ConditionUnknown, nil, NotObservedReason, notObservedMessage(rule))
+		}
+		conditions = append(conditions, c)
+	}
+	return conditions
+}
+
+func notObservedMessage(rule ConditionRule) string {
+	if rule.Task != "" {
+		return fmt.Sprintf("Task %q did not run", rule.Task)
+	}
+	return fmt.Sprintf("Variable %q was not set", rule.Variable)
+}
+
+// conditionFromVariable derives a condition from the value of a variable,
+// which is either a boolean, a condition status, or a map with the status,
+// reason and message of the condition.
+func conditionFromVariable(name string, value interface{}) (v1.ConditionStatus, string, string) {
+	message := fmt.Sprintf("%s is %v", name, value)
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return v1.ConditionTrue, VariableSetReason, message
+		}
+		return v1.ConditionFalse, VariableSetReason, message
+	case string:
+		return parseConditionStatus(v), VariableSetReason, message
+	case map[string]interface{}:
+		status, _ := v["status"].(string)
+		reason, ok := v["reason"].(string)
+		if !ok || reason == "" {
+			reason = VariableSetReason
+		}
+		message, _ = v["message"].(string)
+		return parseConditionStatus(status), reason, message
+	default:
+		return v1.ConditionUnknown, VariableSetReason, message
+	}
+}
+
+func parseConditionStatus(s string) v1.ConditionStatus {
+	switch strings.ToLower(s) {
+	case "true", "yes":
+		return v1.ConditionTrue
+	case "false", "no":
+		return v1.ConditionFalse
+	default:
+		return v1.ConditionUnknown
+	}
+}
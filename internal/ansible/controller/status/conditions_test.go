@@ -0,0 +1,85 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+)
+
+func TestConditionEvaluator(t *testing.T) {
+	e := NewConditionEvaluator([]ConditionRule{
+		{Type: "Deployed", Task: "deploy"},
+		{Type: "Migrated", Task: "migrate"},
+		{Type: "Ready", Variable: "ready"},
+		{Type: "Available", Variable: "available"},
+		{Type: "Degraded", Variable: "degraded"},
+		{Type: "Upgraded", Variable: "upgraded"},
+	})
+	e.Observe(eventapi.JobEvent{Event: eventapi.EventRunnerOnOk, EventData: map[string]interface{}{
+		"task": "deploy",
+		"res":  map[string]interface{}{"msg": "deployed"},
+	}})
+	e.Observe(eventapi.JobEvent{Event: eventapi.EventRunnerOnFailed, EventData: map[string]interface{}{
+		"task": "migrate",
+		"res":  map[string]interface{}{"msg": "migration failed"},
+	}})
+	e.Observe(eventapi.JobEvent{Event: eventapi.EventPlaybookOnStats, EventData: map[string]interface{}{
+		"artifact_data": map[string]interface{}{
+			"ready":     "False",
+			"available": map[string]interface{}{"status": "True", "reason": "MinimumReplicas", "message": "3/3"},
+			"degraded":  false,
+		},
+	}})
+
+	expected := []struct {
+		condType ConditionType
+		status   v1.ConditionStatus
+		reason   string
+		message  string
+	}{
+		{"Deployed", v1.ConditionTrue, TaskSucceededReason, "deployed"},
+		{"Migrated", v1.ConditionFalse, TaskFailedReason, "migration failed"},
+		{"Ready", v1.ConditionFalse, VariableSetReason, "ready is False"},
+		{"Available", v1.ConditionTrue, "MinimumReplicas", "3/3"},
+		{"Degraded", v1.ConditionFalse, VariableSetReason, "degraded is false"},
+		{"Upgraded", v1.ConditionUnknown, NotObservedReason, `Variable "upgraded" was not set`},
+	}
+	conditions := e.Conditions()
+	if len(conditions) != len(expected) {
+		t.Fatalf("Unexpected conditions: %+v", conditions)
+	}
+	for i, c := range conditions {
+		exp := expected[i]
+		if c.Type != exp.condType || c.Status != exp.status || c.Reason != exp.reason || c.Message != exp.message {
+			t.Fatalf("Unexpected condition: %+v expected: %+v", c, exp)
+		}
+	}
+}
+
+func TestCreateFromMapObservedGeneration(t *testing.T) {
+	for _, value := range []interface{}{int64(3), float64(3)} {
+		s := CreateFromMap(map[string]interface{}{"observedGeneration": value, "custom": "value"})
+		if s.ObservedGeneration != 3 {
+			t.Fatalf("Unexpected observed generation: %v", s.ObservedGeneration)
+		}
+		if _, ok := s.CustomStatus["observedGeneration"]; ok {
+			t.Fatal("Observed generation must not be custom status")
+		}
+	}
+}
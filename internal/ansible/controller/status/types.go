@@ -17,6 +17,7 @@ package status
 import (
 	"encoding/json"
 	"time"
+	"unicode/utf8"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -29,6 +30,10 @@ var log = logf.Log.WithName("controller.status")
 
 const (
 	host = "localhost"
+
+	// maxTaskResultMessageLength is the length in bytes that the message of a
+	// task result is truncated to.
+	maxTaskResultMessageLength = 256
 )
 
 // AnsibleResult - encapsulation of the ansible result.
@@ -85,6 +90,56 @@ func NewAnsibleResultFromMap(sm map[string]interface{}) *AnsibleResult {
 	return a
 }
 
+// TaskResult - result of a task of an ansible run.
+type TaskResult struct {
+	Name    string `json:"name"`
+	Role    string `json:"role,omitempty"`
+	Changed bool   `json:"changed"`
+	Failed  bool   `json:"failed"`
+	// Ignored is set if the task failed, but its failure was ignored or rescued.
+	Ignored  bool            `json:"ignored,omitempty"`
+	Duration metav1.Duration `json:"duration"`
+	Message  string          `json:"message,omitempty"`
+}
+
+// NewTaskResultFromJobEvent - creates a task result from a job event. It returns
+// false if the event is not the result of a task.
+func NewTaskResultFromJobEvent(je eventapi.JobEvent) (TaskResult, bool) {
+	if je.Event != eventapi.EventRunnerOnOk && je.Event != eventapi.EventRunnerOnFailed {
+		return TaskResult{}, false
+	}
+	tr := TaskResult{Failed: je.Event == eventapi.EventRunnerOnFailed}
+	tr.Name, _ = je.EventData["task"].(string)
+	tr.Role, _ = je.EventData["role"].(string)
+	if d, ok := je.EventData["duration"].(float64); ok {
+		tr.Duration.Duration = time.Duration(d * float64(time.Second)).Round(time.Millisecond)
+	}
+	res, _ := je.EventData["res"].(map[string]interface{})
+	tr.Changed, _ = res["changed"].(bool)
+	if tr.Failed {
+		tr.Ignored = je.IgnoreError() || je.Rescued()
+		tr.Message = je.GetFailedPlaybookMessage()
+	} else {
+		tr.Message, _ = res["msg"].(string)
+	}
+	tr.Message = truncateMessage(tr.Message)
+	return tr, true
+}
+
+// truncateMessage truncates message to maxTaskResultMessageLength bytes,
+// without splitting a character.
+func truncateMessage(message string) string {
+	const ellipsis = "..."
+	if len(message) <= maxTaskResultMessageLength {
+		return message
+	}
+	end := maxTaskResultMessageLength - len(ellipsis)
+	for end > 0 && !utf8.RuneStart(message[end]) {
+		end--
+	}
+	return message[:end] + ellipsis
+}
+
 // ConditionType - type of condition
 type ConditionType string
 
@@ -151,21 +206,27 @@ func createConditionFromMap(cm map[string]interface{}) Condition {
 
 // Status - The status for custom resources managed by the operator-sdk.
 type Status struct {
-	Conditions   []Condition            `json:"conditions"`
-	CustomStatus map[string]interface{} `json:"-"`
+	Conditions  []Condition  `json:"conditions"`
+	TaskResults []TaskResult `json:"taskResults,omitempty"`
+	// ObservedGeneration is the generation of the CR that was last reconciled.
+	ObservedGeneration int64                  `json:"observedGeneration,omitempty"`
+	CustomStatus       map[string]interface{} `json:"-"`
 }
 
 // CreateFromMap - create a status from the map
 func CreateFromMap(statusMap map[string]interface{}) Status {
 	customStatus := make(map[string]interface{})
 	for key, value := range statusMap {
-		if key != "conditions" {
+		if key != "conditions" && key != "taskResults" && key != "observedGeneration" {
 			customStatus[key] = value
 		}
 	}
+	taskResults := createTaskResultsFromMap(statusMap)
+	observedGeneration := createObservedGenerationFromMap(statusMap)
 	conditionsInterface, ok := statusMap["conditions"].([]interface{})
 	if !ok {
-		return Status{Conditions: []Condition{}, CustomStatus: customStatus}
+		return Status{Conditions: []Condition{}, TaskResults: taskResults, ObservedGeneration: observedGeneration,
+			CustomStatus: customStatus}
 	}
 	conditions := []Condition{}
 	for _, ci := range conditionsInterface {
@@ -176,7 +237,36 @@ func CreateFromMap(statusMap map[string]interface{}) Status {
 		}
 		conditions = append(conditions, createConditionFromMap(cm))
 	}
-	return Status{Conditions: conditions, CustomStatus: customStatus}
+	return Status{Conditions: conditions, TaskResults: taskResults, ObservedGeneration: observedGeneration,
+		CustomStatus: customStatus}
+}
+
+func createObservedGenerationFromMap(statusMap map[string]interface{}) int64 {
+	switch v := statusMap["observedGeneration"].(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	default:
+		return 0
+	}
+}
+
+func createTaskResultsFromMap(statusMap map[string]interface{}) []TaskResult {
+	v, ok := statusMap["taskResults"]
+	if !ok {
+		return nil
+	}
+	var taskResults []TaskResult
+	b, err := json.Marshal(v)
+	if err == nil {
+		err = json.Unmarshal(b, &taskResults)
+	}
+	if err != nil {
+		log.Info("Unknown task results, removing task results", "TaskResults", v)
+		return nil
+	}
+	return taskResults
 }
 
 // GetJSONMap - gets the map value for the status object.
@@ -0,0 +1,135 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+)
+
+func TestNewTaskResultFromJobEvent(t *testing.T) {
+	testCases := []struct {
+		name       string
+		event      eventapi.JobEvent
+		expected   TaskResult
+		isResult   bool
+		messageLen int
+	}{
+		{
+			name:  "not a task result",
+			event: eventapi.JobEvent{Event: eventapi.EventPlaybookOnTaskStart},
+		},
+		{
+			name: "changed task",
+			event: eventapi.JobEvent{
+				Event: eventapi.EventRunnerOnOk,
+				EventData: map[string]interface{}{
+					"task":     "create deployment",
+					"role":     "memcached",
+					"duration": 1.2345,
+					"res":      map[string]interface{}{"changed": true, "msg": "created"},
+				},
+			},
+			expected: TaskResult{
+				Name:     "create deployment",
+				Role:     "memcached",
+				Changed:  true,
+				Duration: metav1.Duration{Duration: 1235 * time.Millisecond},
+				Message:  "created",
+			},
+			isResult: true,
+		},
+		{
+			name: "failed task",
+			event: eventapi.JobEvent{
+				Event: eventapi.EventRunnerOnFailed,
+				EventData: map[string]interface{}{
+					"task": "create deployment",
+					"res":  map[string]interface{}{"msg": "forbidden"},
+				},
+			},
+			expected: TaskResult{Name: "create deployment", Failed: true, Message: "forbidden"},
+			isResult: true,
+		},
+		{
+			name: "ignored failure",
+			event: eventapi.JobEvent{
+				Event: eventapi.EventRunnerOnFailed,
+				EventData: map[string]interface{}{
+					"task":          "check",
+					"ignore_errors": true,
+				},
+			},
+			expected: TaskResult{Name: "check", Failed: true, Ignored: true, Message: "unknown playbook failure"},
+			isResult: true,
+		},
+		{
+			name: "long message",
+			event: eventapi.JobEvent{
+				Event: eventapi.EventRunnerOnFailed,
+				EventData: map[string]interface{}{
+					"task": "render",
+					"res":  map[string]interface{}{"msg": strings.Repeat("é", 200)},
+				},
+			},
+			expected: TaskResult{
+				Name:    "render",
+				Failed:  true,
+				Message: strings.Repeat("é", 126) + "...",
+			},
+			isResult: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tr, ok := NewTaskResultFromJobEvent(tc.event)
+			if ok != tc.isResult {
+				t.Fatalf("Unexpected task result check: %v expected: %v", ok, tc.isResult)
+			}
+			if !reflect.DeepEqual(tr, tc.expected) {
+				t.Fatalf("Unexpected task result\nexpected: %#v\nactual: %#v", tc.expected, tr)
+			}
+			if len(tr.Message) > maxTaskResultMessageLength {
+				t.Fatalf("Message is longer than %d bytes: %d", maxTaskResultMessageLength, len(tr.Message))
+			}
+		})
+	}
+}
+
+func TestCreateFromMapTaskResults(t *testing.T) {
+	taskResults := []TaskResult{{Name: "a", Changed: true, Duration: metav1.Duration{Duration: time.Second}}}
+	status := Status{Conditions: []Condition{}, TaskResults: taskResults, CustomStatus: map[string]interface{}{}}
+	statusMap := status.GetJSONMap()
+
+	got := CreateFromMap(statusMap)
+	if !reflect.DeepEqual(got.TaskResults, taskResults) {
+		t.Fatalf("Unexpected task results\nexpected: %#v\nactual: %#v", taskResults, got.TaskResults)
+	}
+	if _, ok := got.CustomStatus["taskResults"]; ok {
+		t.Fatal("Task results should not be part of the custom status")
+	}
+
+	got = CreateFromMap(map[string]interface{}{"taskResults": "invalid"})
+	if got.TaskResults != nil {
+		t.Fatalf("Unexpected task results for invalid status: %#v", got.TaskResults)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+)
+
+const (
+	// Reasons of the Kubernetes Events emitted for runs.
+	ReasonTaskFailed   = "TaskFailed"
+	ReasonTasksChanged = "TasksChanged"
+	ReasonRunSucceeded = "RunSucceeded"
+	ReasonRunFailed    = "RunFailed"
+
+	// maxFailedTaskEvents is the number of failed tasks of a run that Events
+	// are emitted for. Further failures are counted in the completion Event.
+	maxFailedTaskEvents = 5
+	// maxChangedTaskNames is the number of changed tasks of a run listed by name.
+	maxChangedTaskNames = 10
+	// maxEventMessageLength is the length in bytes that messages are truncated to.
+	maxEventMessageLength = 1024
+	// staleRunTimeout is how long the state of a run that never completed is kept.
+	staleRunTimeout = time.Hour
+
+	host = "localhost"
+)
+
+// KubernetesEventOptions - which Kubernetes Events to emit for runs.
+type KubernetesEventOptions struct {
+	// FailedTasks emits a Warning Event for each failed task of a run.
+	FailedTasks bool
+	// ChangedTasks emits a Normal Event listing the changed tasks of a run.
+	ChangedTasks bool
+	// Completion emits an Event with the stats of a run once it completes.
+	Completion bool
+}
+
+// Enabled returns true if any Events are emitted.
+func (o KubernetesEventOptions) Enabled() bool {
+	return o.FailedTasks || o.ChangedTasks || o.Completion
+}
+
+type kubernetesEventHandler struct {
+	recorder record.EventRecorder
+	options  KubernetesEventOptions
+	mux      *sync.Mutex
+	runs     map[string]*runEvents
+}
+
+// runEvents aggregates the events of a run until it completes.
+type runEvents struct {
+	started            time.Time
+	changedTasks       []string
+	failedTaskEvents   int
+	suppressedFailures int
+}
+
+// NewKubernetesEventHandler - Creates an Event Handler that emits Kubernetes
+// Events on the custom resource of a run. Changed tasks are aggregated into a
+// single Event per run, and at most a few Events are emitted for failed tasks.
+// Handle must be called with the events of a run in order.
+func NewKubernetesEventHandler(recorder record.EventRecorder, options KubernetesEventOptions) EventHandler {
+	return kubernetesEventHandler{
+		recorder: recorder,
+		options:  options,
+		mux:      &sync.Mutex{},
+		runs:     map[string]*runEvents{},
+	}
+}
+
+func (k kubernetesEventHandler) Handle(ident string, u *unstructured.Unstructured, e eventapi.JobEvent) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+
+	switch e.Event {
+	case eventapi.EventRunnerOnFailed:
+		if !k.options.FailedTasks || e.IgnoreError() || e.Rescued() {
+			return
+		}
+		run := k.run(ident)
+		if run.failedTaskEvents == maxFailedTaskEvents {
+			run.suppressedFailures++
+			return
+		}
+		run.failedTaskEvents++
+		k.recorder.Event(u, corev1.EventTypeWarning, ReasonTaskFailed,
+			truncate(fmt.Sprintf("Task %q failed: %s", e.EventData["task"], e.GetFailedPlaybookMessage())))
+	case eventapi.EventRunnerOnOk:
+		res, _ := e.EventData["res"].(map[string]interface{})
+		if changed, _ := res["changed"].(bool); !changed || !k.options.ChangedTasks {
+			return
+		}
+		run := k.run(ident)
+		task, _ := e.EventData["task"].(string)
+		run.changedTasks = append(run.changedTasks, task)
+	case eventapi.EventPlaybookOnStats:
+		run := k.run(ident)
+		delete(k.runs, ident)
+		k.pruneStaleRuns()
+		if len(run.changedTasks) > 0 {
+			k.recorder.Event(u, corev1.EventTypeNormal, ReasonTasksChanged, truncate(changedTasksMessage(run.changedTasks)))
+		}
+		if k.options.Completion {
+			k.emitCompletion(u, e, run)
+		}
+	}
+}
+
+func (k kubernetesEventHandler) run(ident string) *runEvents {
+	run, ok := k.runs[ident]
+	if !ok {
+		run = &runEvents{started: time.Now()}
+		k.runs[ident] = run
+	}
+	return run
+}
+
+// pruneStaleRuns removes runs that never completed, e.g. because ansible-runner
+// was killed.
+func (k kubernetesEventHandler) pruneStaleRuns() {
+	for ident, run := range k.runs {
+		if time.Since(run.started) > staleRunTimeout {
+			delete(k.runs, ident)
+		}
+	}
+}
+
+func (k kubernetesEventHandler) emitCompletion(u *unstructured.Unstructured, e eventapi.JobEvent, run *runEvents) {
+	stats := runStats(e)
+	message := statsMessage(stats)
+	if stats.Failures[host] == 0 {
+		k.recorder.Event(u, corev1.EventTypeNormal, ReasonRunSucceeded, "Run succeeded: "+message)
+		return
+	}
+	message = "Run failed: " + message
+	if run.suppressedFailures > 0 {
+		message += fmt.Sprintf("; %d more failed tasks have no event", run.suppressedFailures)
+	}
+	k.recorder.Event(u, corev1.EventTypeWarning, ReasonRunFailed, message)
+}
+
+// runStats reads the stats of a run from its stats event.
+func runStats(e eventapi.JobEvent) eventapi.StatsEventData {
+	// convert to StatusJobEvent to read the stats of the run.
+	statusEvent := eventapi.StatusJobEvent{}
+	if data, err := json.Marshal(e); err == nil {
+		_ = json.Unmarshal(data, &statusEvent)
+	}
+	return statusEvent.EventData
+}
+
+func statsMessage(stats eventapi.StatsEventData) string {
+	return fmt.Sprintf("ok=%d changed=%d skipped=%d failed=%d",
+		stats.Ok[host], stats.Changed[host], stats.Skipped[host], stats.Failures[host])
+}
+
+func changedTasksMessage(tasks []string) string {
+	names := tasks
+	if len(names) > maxChangedTaskNames {
+		names = names[:maxChangedTaskNames]
+	}
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	message := fmt.Sprintf("Changed %d tasks: %s", len(tasks), strings.Join(quoted, ", "))
+	if more := len(tasks) - len(names); more > 0 {
+		message += fmt.Sprintf(" and %d more", more)
+	}
+	return message
+}
+
+// truncate truncates message to maxEventMessageLength bytes, without splitting
+// a character.
+func truncate(message string) string {
+	const ellipsis = "..."
+	if len(message) <= maxEventMessageLength {
+		return message
+	}
+	end := maxEventMessageLength - len(ellipsis)
+	for end > 0 && !utf8.RuneStart(message[end]) {
+		end--
+	}
+	return message[:end] + ellipsis
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+)
+
+func taskEvent(event, task string, res map[string]interface{}) eventapi.JobEvent {
+	return eventapi.JobEvent{Event: event, EventData: map[string]interface{}{"task": task, "res": res}}
+}
+
+func statsEvent(ok, changed, failures int) eventapi.JobEvent {
+	return eventapi.JobEvent{Event: eventapi.EventPlaybookOnStats, EventData: map[string]interface{}{
+		"ok":       map[string]interface{}{host: ok},
+		"changed":  map[string]interface{}{host: changed},
+		"failures": map[string]interface{}{host: failures},
+	}}
+}
+
+func recorded(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case e := <-recorder.Events:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestKubernetesEventHandler(t *testing.T) {
+	u := &unstructured.Unstructured{}
+	u.SetName("test")
+
+	t.Run("successful run", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(20)
+		h := NewKubernetesEventHandler(recorder, KubernetesEventOptions{FailedTasks: true, ChangedTasks: true, Completion: true})
+		h.Handle("1", u, taskEvent(eventapi.EventRunnerOnOk, "create", map[string]interface{}{"changed": true}))
+		h.Handle("1", u, taskEvent(eventapi.EventRunnerOnOk, "check", map[string]interface{}{"changed": false}))
+		h.Handle("1", u, taskEvent(eventapi.EventRunnerOnOk, "update", map[string]interface{}{"changed": true}))
+		h.Handle("1", u, statsEvent(3, 2, 0))
+		assert.Equal(t, []string{
+			`Normal TasksChanged Changed 2 tasks: "create", "update"`,
+			"Normal RunSucceeded Run succeeded: ok=3 changed=2 skipped=0 failed=0",
+		}, recorded(recorder))
+	})
+
+	t.Run("failed run", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(20)
+		h := NewKubernetesEventHandler(recorder, KubernetesEventOptions{FailedTasks: true, Completion: true})
+		ignored := taskEvent(eventapi.EventRunnerOnFailed, "ignored", nil)
+		ignored.EventData["ignore_errors"] = true
+		h.Handle("1", u, ignored)
+		for i := 0; i < maxFailedTaskEvents+2; i++ {
+			h.Handle("1", u, taskEvent(eventapi.EventRunnerOnFailed, fmt.Sprintf("task %d", i),
+				map[string]interface{}{"msg": "boom", "changed": true}))
+		}
+		h.Handle("1", u, taskEvent(eventapi.EventRunnerOnOk, "create", map[string]interface{}{"changed": true}))
+		h.Handle("1", u, statsEvent(1, 1, maxFailedTaskEvents+2))
+
+		events := recorded(recorder)
+		if assert.Len(t, events, maxFailedTaskEvents+1) {
+			assert.Equal(t, `Warning TaskFailed Task "task 0" failed: boom`, events[0])
+			assert.Equal(t, "Warning RunFailed Run failed: ok=1 changed=1 skipped=0 failed=7; "+
+				"2 more failed tasks have no event", events[maxFailedTaskEvents])
+		}
+	})
+
+	t.Run("aggregated changed tasks", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(20)
+		h := NewKubernetesEventHandler(recorder, KubernetesEventOptions{ChangedTasks: true})
+		for i := 0; i < maxChangedTaskNames+3; i++ {
+			h.Handle("1", u, taskEvent(eventapi.EventRunnerOnOk, fmt.Sprintf("%d", i), map[string]interface{}{"changed": true}))
+		}
+		h.Handle("1", u, statsEvent(13, 13, 0))
+		events := recorded(recorder)
+		if assert.Len(t, events, 1) {
+			assert.True(t, strings.HasPrefix(events[0], `Normal TasksChanged Changed 13 tasks: "0", "1"`))
+			assert.True(t, strings.HasSuffix(events[0], `"9" and 3 more`))
+		}
+	})
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "short", truncate("short"))
+	long := truncate(strings.Repeat("é", maxEventMessageLength))
+	assert.True(t, len(long) <= maxEventMessageLength)
+	assert.True(t, strings.HasSuffix(long, "é..."))
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+)
+
+const treeIndent = "  "
+
+type treeEventHandler struct {
+	w   io.Writer
+	mux *sync.Mutex
+}
+
+// NewTreeEventHandler - Creates an Event Handler that writes the events of a
+// run to w as a tree of plays, their tasks and the results of the tasks. The
+// output of a task result is written below it if it spans several lines, which
+// it does at higher verbosities.
+func NewTreeEventHandler(w io.Writer) EventHandler {
+	return treeEventHandler{
+		w:   w,
+		mux: &sync.Mutex{},
+	}
+}
+
+func (t treeEventHandler) Handle(_ string, _ *unstructured.Unstructured, e eventapi.JobEvent) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	switch e.Event {
+	case eventapi.EventPlaybookOnPlayStart:
+		t.writeLine(0, fmt.Sprintf("PLAY [%v]", e.EventData["play"]))
+	case eventapi.EventPlaybookOnTaskStart:
+		name := fmt.Sprintf("%v", e.EventData["task"])
+		if role, ok := e.EventData["role"].(string); ok && role != "" {
+			name = role + " : " + name
+		}
+		t.writeLine(1, fmt.Sprintf("TASK [%s]", name))
+	case eventapi.EventRunnerOnOk, eventapi.EventRunnerOnFailed, eventapi.EventRunnerOnSkipped,
+		eventapi.EventRunnerOnUnreachable:
+		t.writeLine(2, fmt.Sprintf("%s: [%v]%s", taskResultStatus(e), e.EventData["host"], taskResultMessage(e)))
+		if stdout := strings.TrimSpace(e.StdOut); strings.Contains(stdout, "\n") {
+			for _, line := range strings.Split(stdout, "\n") {
+				t.writeLine(3, line)
+			}
+		}
+	case eventapi.EventPlaybookOnStats:
+		t.writeLine(0, "PLAY RECAP "+statsMessage(runStats(e)))
+	}
+}
+
+func (t treeEventHandler) writeLine(depth int, line string) {
+	fmt.Fprintln(t.w, strings.Repeat(treeIndent, depth)+strings.TrimRight(line, " \r"))
+}
+
+func taskResultStatus(e eventapi.JobEvent) string {
+	switch e.Event {
+	case eventapi.EventRunnerOnFailed:
+		if e.IgnoreError() || e.Rescued() {
+			return "failed (ignored)"
+		}
+		return "failed"
+	case eventapi.EventRunnerOnSkipped:
+		return "skipped"
+	case eventapi.EventRunnerOnUnreachable:
+		return "unreachable"
+	}
+	res, _ := e.EventData["res"].(map[string]interface{})
+	if changed, _ := res["changed"].(bool); changed {
+		return "changed"
+	}
+	return "ok"
+}
+
+func taskResultMessage(e eventapi.JobEvent) string {
+	if e.Event == eventapi.EventRunnerOnFailed || e.Event == eventapi.EventRunnerOnUnreachable {
+		return " " + e.GetFailedPlaybookMessage()
+	}
+	return ""
+}
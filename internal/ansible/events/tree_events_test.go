@@ -0,0 +1,59 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+)
+
+func TestTreeEventHandler(t *testing.T) {
+	u := &unstructured.Unstructured{}
+	out := &bytes.Buffer{}
+	h := NewTreeEventHandler(out)
+
+	h.Handle("1", u, eventapi.JobEvent{Event: eventapi.EventPlaybookOnPlayStart,
+		EventData: map[string]interface{}{"play": "localhost"}})
+	h.Handle("1", u, eventapi.JobEvent{Event: eventapi.EventPlaybookOnTaskStart,
+		EventData: map[string]interface{}{"task": "start memcached", "role": "memcached"}})
+	created := taskEvent(eventapi.EventRunnerOnOk, "start memcached", map[string]interface{}{"changed": true})
+	created.EventData["host"] = host
+	created.StdOut = "changed: [localhost] => {\n    \"changed\": true\n}\n"
+	h.Handle("1", u, created)
+	h.Handle("1", u, eventapi.JobEvent{Event: eventapi.EventPlaybookOnTaskStart,
+		EventData: map[string]interface{}{"task": "check memcached"}})
+	failed := taskEvent(eventapi.EventRunnerOnFailed, "check memcached", map[string]interface{}{"msg": "boom"})
+	failed.EventData["host"] = host
+	failed.EventData["ignore_errors"] = true
+	failed.StdOut = `fatal: [localhost]: FAILED! => {"msg": "boom"}`
+	h.Handle("1", u, failed)
+	h.Handle("1", u, statsEvent(2, 1, 0))
+
+	assert.Equal(t, `PLAY [localhost]
+  TASK [memcached : start memcached]
+    changed: [localhost]
+      changed: [localhost] => {
+          "changed": true
+      }
+  TASK [check memcached]
+    failed (ignored): [localhost] boom
+PLAY RECAP ok=2 changed=1 skipped=0 failed=0
+`, out.String())
+}
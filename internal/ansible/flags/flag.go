@@ -39,6 +39,11 @@ type Flags struct {
 	LeaderElectionNamespace string
 	GracefulShutdownTimeout time.Duration
 	AnsibleArgs             string
+	AnsibleWorkerPoolSize   int
+	AnsibleWorkerTimeout    time.Duration
+	SecureProxy             bool
+	Replay                  string
+	ReplayKind              string
 
 	// Path to a controller-runtime componentconfig file.
 	// If this is empty, use default values.
@@ -90,6 +95,36 @@ func (f *Flags) AddTo(flagSet *pflag.FlagSet) {
 		"",
 		"Ansible args. Allows user to specify arbitrary arguments for ansible-based operators.",
 	)
+	flagSet.IntVar(&f.AnsibleWorkerPoolSize,
+		"ansible-worker-pool-size",
+		0,
+		"Number of ansible worker processes that run playbooks and roles with ansible-runner imported once, "+
+			"instead of starting the ansible-runner command on every reconcile. "+
+			"ansible-playbook is still started on every reconcile. If 0, no workers are run.",
+	)
+	flagSet.DurationVar(&f.AnsibleWorkerTimeout,
+		"ansible-worker-timeout",
+		0,
+		"How long a run in an ansible worker may take before it is stopped. If 0, runs are never stopped.",
+	)
+	flagSet.BoolVar(&f.SecureProxy,
+		"secure-proxy",
+		false,
+		"Serve the proxy over TLS with a generated CA, and only accept requests with the tokens issued to runs. "+
+			"Required for impersonation of ServiceAccounts in watches.",
+	)
+	flagSet.StringVar(&f.Replay,
+		"replay",
+		"",
+		"Reconcile the custom resource <namespace>/<name>, or <name> if it is cluster-scoped, once with full "+
+			"Ansible verbosity, print its events, keep its runner input directory and artifacts, and exit "+
+			"instead of running the operator.",
+	)
+	flagSet.StringVar(&f.ReplayKind,
+		"replay-kind",
+		"",
+		"Kind of the custom resource to replay. Required with --replay if the watches file has several watches.",
+	)
 
 	// Controller flags.
 	flagSet.DurationVar(&f.ReconcilePeriod,
@@ -16,6 +16,7 @@ package metrics
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -60,11 +61,23 @@ var (
 		[]string{
 			"GVK",
 		})
+
+	runs = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: subsystem,
+			Name:      "runs",
+			Help:      "How long in seconds a run of a playbook or role takes, by how it was executed.",
+		},
+		[]string{
+			"GVK",
+			"mode",
+		})
 )
 
 func init() {
 	metrics.Registry.MustRegister(reconcileResults)
 	metrics.Registry.MustRegister(reconciles)
+	metrics.Registry.MustRegister(runs)
 }
 
 // We will never want to panic our app because of metric saving.
@@ -99,3 +112,13 @@ func ReconcileTimer(gvk string) *prometheus.Timer {
 		reconciles.WithLabelValues(gvk).Observe(duration)
 	}))
 }
+
+// RunTimer starts timing a run of a playbook or role, and returns a function
+// that records its duration along with the mode the run was executed in.
+func RunTimer(gvk string) func(mode string) {
+	start := time.Now()
+	return func(mode string) {
+		defer recoverMetricPanic()
+		runs.WithLabelValues(gvk, mode).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auth authenticates the runs of playbooks and roles to the proxy,
+// with bearer tokens issued for each run.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
+)
+
+// Identity is the identity of a run, which requests with its token are made as.
+type Identity struct {
+	// Owner is the CR the run reconciles.
+	Owner kubeconfig.NamespacedOwnerReference
+	// ImpersonateUser is the user that requests of the run are made as, instead
+	// of the operator's user. It is not impersonated if empty.
+	ImpersonateUser string
+}
+
+// ServiceAccountUser returns the username of a ServiceAccount.
+func ServiceAccountUser(namespace, name string) string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name)
+}
+
+// Tokens issues the tokens of runs.
+type Tokens struct {
+	mu     sync.RWMutex
+	tokens map[string]Identity
+}
+
+// NewTokens creates a token store without tokens.
+func NewTokens() *Tokens {
+	return &Tokens{tokens: map[string]Identity{}}
+}
+
+// Issue issues a token for a run with identity id. The token is valid until
+// it is revoked.
+func (t *Tokens) Issue(id Identity) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tokens[token] = id
+	return token, nil
+}
+
+// Revoke revokes token.
+func (t *Tokens) Revoke(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.tokens, token)
+}
+
+// Authenticate returns the identity of the bearer token of req, and false if
+// req has no valid token.
+func (t *Tokens) Authenticate(req *http.Request) (Identity, bool) {
+	const prefix = "Bearer "
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, prefix) {
+		return Identity{}, false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	id, ok := t.tokens[strings.TrimPrefix(header, prefix)]
+	return id, ok
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx with id.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFrom returns the identity of ctx, if it has one.
+func IdentityFrom(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
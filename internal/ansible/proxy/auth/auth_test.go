@@ -0,0 +1,118 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
+)
+
+func TestTokens(t *testing.T) {
+	tokens := NewTokens()
+	id := Identity{
+		Owner: kubeconfig.NamespacedOwnerReference{
+			OwnerReference: metav1.OwnerReference{APIVersion: "cache.example.com/v1", Kind: "Memcached", Name: "test"},
+			Namespace:      "default",
+		},
+		ImpersonateUser: ServiceAccountUser("default", "runner"),
+	}
+	token, err := tokens.Issue(id)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	other, err := tokens.Issue(Identity{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if token == other {
+		t.Fatal("Issued the same token twice")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil)
+	if _, ok := tokens.Authenticate(req); ok {
+		t.Fatal("Authenticated request without token")
+	}
+	req.Header.Set("Authorization", "Bearer invalid")
+	if _, ok := tokens.Authenticate(req); ok {
+		t.Fatal("Authenticated request with invalid token")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	got, ok := tokens.Authenticate(req)
+	if !ok {
+		t.Fatal("Failed to authenticate request with issued token")
+	}
+	if got != id {
+		t.Fatalf("Unexpected identity: %+v expected: %+v", got, id)
+	}
+	if got.ImpersonateUser != "system:serviceaccount:default:runner" {
+		t.Fatalf("Unexpected user to impersonate: %v", got.ImpersonateUser)
+	}
+
+	tokens.Revoke(token)
+	if _, ok := tokens.Authenticate(req); ok {
+		t.Fatal("Authenticated request with revoked token")
+	}
+}
+
+func TestIdentityContext(t *testing.T) {
+	if _, ok := IdentityFrom(context.TODO()); ok {
+		t.Fatal("Found identity in empty context")
+	}
+	id := Identity{ImpersonateUser: "user"}
+	got, ok := IdentityFrom(WithIdentity(context.TODO(), id))
+	if !ok || got != id {
+		t.Fatalf("Unexpected identity: %+v expected: %+v", got, id)
+	}
+}
+
+func TestGenerateTLSConfig(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca", "proxy-ca.crt")
+	config, err := GenerateTLSConfig(caFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	caPEM, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		t.Fatalf("Failed to read CA certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		t.Fatal("Failed to parse CA certificate")
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = config
+	server.StartTLS()
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to verify proxy certificate: %v", err)
+	}
+	resp.Body.Close()
+}
@@ -157,6 +157,12 @@ func (c *cacheResponseHandler) skipCacheLookup(r *k8sRequest.RequestInfo, gvk sc
 		return true
 	}
 
+	// The cache is filled with the operator's permissions, so impersonated
+	// requests must be authorized by the API server.
+	if req.Header.Get(impersonateUserHeader) != "" {
+		return true
+	}
+
 	owner, err := getRequestOwnerRef(req)
 	if err != nil {
 		log.Error(err, "Could not get owner reference from proxy.")
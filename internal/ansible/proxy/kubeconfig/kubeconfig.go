@@ -39,7 +39,11 @@ apiVersion: v1
 kind: Config
 clusters:
 - cluster:
+{{- if .CAFile}}
+    certificate-authority: {{.CAFile}}
+{{- else}}
     insecure-skip-tls-verify: true
+{{- end}}
     server: {{.ProxyURL}}
   name: proxy-server
 contexts:
@@ -52,13 +56,19 @@ preferences: {}
 users:
 - name: admin/proxy-server
   user:
+{{- if .Token}}
+    token: {{.Token}}
+{{- else}}
     username: {{.Username}}
     password: unused
+{{- end}}
 `
 
 // values holds the data used to render the template
 type values struct {
 	Username  string
+	Token     string
+	CAFile    string
 	ProxyURL  string
 	Namespace string
 }
@@ -81,12 +91,29 @@ func Create(ownerRef metav1.OwnerReference, proxyURL string, namespace string) (
 	}
 	username := base64.URLEncoding.EncodeToString(ownerRefJSON)
 	parsedURL.User = url.User(username)
-	v := values{
+	return write(values{
 		Username:  username,
 		ProxyURL:  parsedURL.String(),
 		Namespace: namespace,
+	})
+}
+
+// CreateWithToken renders a kubeconfig template that authenticates to the
+// proxy with a bearer token, verifying the proxy's certificate with the CA in
+// caFile, and writes it to disk.
+func CreateWithToken(token string, proxyURL string, namespace string, caFile string) (*os.File, error) {
+	if _, err := url.Parse(proxyURL); err != nil {
+		return nil, err
 	}
+	return write(values{
+		Token:     token,
+		CAFile:    caFile,
+		ProxyURL:  proxyURL,
+		Namespace: namespace,
+	})
+}
 
+func write(v values) (*os.File, error) {
 	var parsed bytes.Buffer
 
 	t := template.Must(template.New("kubeconfig").Parse(kubeConfigTemplate))
@@ -0,0 +1,85 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubeconfig
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestCreate(t *testing.T) {
+	ownerRef := metav1.OwnerReference{APIVersion: "cache.example.com/v1", Kind: "Memcached", Name: "test"}
+	kc, err := Create(ownerRef, "http://localhost:8888", "default")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.Remove(kc.Name())
+
+	config, err := clientcmd.LoadFromFile(kc.Name())
+	if err != nil {
+		t.Fatalf("Failed to load kubeconfig: %v", err)
+	}
+	cluster := config.Clusters["proxy-server"]
+	if !cluster.InsecureSkipTLSVerify || cluster.CertificateAuthority != "" {
+		t.Fatalf("Unexpected cluster: %+v", cluster)
+	}
+	user := config.AuthInfos["admin/proxy-server"]
+	if user.Token != "" {
+		t.Fatalf("Unexpected token: %v", user.Token)
+	}
+	ownerRefJSON, err := base64.URLEncoding.DecodeString(user.Username)
+	if err != nil {
+		t.Fatalf("Failed to decode username: %v", err)
+	}
+	owner := NamespacedOwnerReference{}
+	if err := json.Unmarshal(ownerRefJSON, &owner); err != nil {
+		t.Fatalf("Failed to unmarshal owner: %v", err)
+	}
+	if owner.Name != "test" || owner.Namespace != "default" {
+		t.Fatalf("Unexpected owner: %+v", owner)
+	}
+}
+
+func TestCreateWithToken(t *testing.T) {
+	kc, err := CreateWithToken("secret", "https://localhost:8888", "default", "/tmp/ansible-operator/proxy-ca.crt")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.Remove(kc.Name())
+
+	config, err := clientcmd.LoadFromFile(kc.Name())
+	if err != nil {
+		t.Fatalf("Failed to load kubeconfig: %v", err)
+	}
+	cluster := config.Clusters["proxy-server"]
+	if cluster.InsecureSkipTLSVerify || cluster.CertificateAuthority != "/tmp/ansible-operator/proxy-ca.crt" {
+		t.Fatalf("Unexpected cluster: %+v", cluster)
+	}
+	if cluster.Server != "https://localhost:8888" {
+		t.Fatalf("Unexpected server: %v", cluster.Server)
+	}
+	user := config.AuthInfos["admin/proxy-server"]
+	if user.Token != "secret" || user.Username != "" {
+		t.Fatalf("Unexpected user: %+v", user)
+	}
+	if config.CurrentContext != "default/proxy-server" {
+		t.Fatalf("Unexpected current context: %v", config.CurrentContext)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/auth"
+	k8sRequest "github.com/operator-framework/operator-sdk/internal/ansible/proxy/requestfactory"
+)
+
+// mutatingVerbs are the verbs of the requests that are audited.
+var mutatingVerbs = sets.NewString("create", "update", "patch", "delete", "deletecollection")
+
+// Audit - logs each mutating request with the CR that it is made for, the user
+// it is impersonated as, if any, and the status code of its response.
+type Audit struct{}
+
+func (a *Audit) handler(next http.Handler) http.Handler {
+	logger := log.WithName("audit")
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r, err := newRequestInfo(req)
+		if err != nil || !r.IsResourceRequest || !mutatingVerbs.Has(r.Verb) {
+			next.ServeHTTP(w, req)
+			return
+		}
+		sw := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(sw, req)
+
+		kvs := []interface{}{
+			"verb", r.Verb,
+			"path", req.URL.Path,
+			"code", sw.code,
+		}
+		if id, ok := auth.IdentityFrom(req.Context()); ok {
+			kvs = append(kvs, "owner", fmt.Sprintf("%s %s/%s", id.Owner.Kind, id.Owner.Namespace, id.Owner.Name),
+				"ownerUID", id.Owner.UID)
+			if id.ImpersonateUser != "" {
+				kvs = append(kvs, "user", id.ImpersonateUser)
+			}
+		}
+		logger.Info("Mutating request", kvs...)
+	})
+}
+
+// statusRecorder records the status code of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.code = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Hijack hijacks the connection of upgrade requests, such as those to
+// pods/exec, whose responses are written to the connection by the proxy.
+// Their status code is recorded as 101 Switching Protocols.
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer %T cannot be hijacked", s.ResponseWriter)
+	}
+	s.code = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// Flush flushes responses that are streamed, such as those of watches.
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func newRequestInfo(req *http.Request) (*k8sRequest.RequestInfo, error) {
+	rf := k8sRequest.RequestInfoFactory{APIPrefixes: sets.NewString("api", "apis"),
+		GrouplessAPIPrefixes: sets.NewString("api")}
+	return rf.NewRequestInfo(req)
+}
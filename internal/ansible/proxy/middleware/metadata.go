@@ -0,0 +1,110 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// InjectMetadata - adds Labels and Annotations to the objects that are
+// created. Labels and annotations that an object already has are not
+// overwritten.
+type InjectMetadata struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+func (i *InjectMetadata) validate() error {
+	for k, v := range i.Labels {
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return fmt.Errorf("invalid label key %q: %s", k, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			return fmt.Errorf("invalid label value %q: %s", v, strings.Join(errs, "; "))
+		}
+	}
+	for k := range i.Annotations {
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return fmt.Errorf("invalid annotation key %q: %s", k, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+func (i *InjectMetadata) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			next.ServeHTTP(w, req)
+			return
+		}
+		r, err := newRequestInfo(req)
+		if err != nil || !r.IsResourceRequest || r.Verb != "create" || r.Subresource != "" ||
+			!strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			m := "Could not read request body"
+			log.Error(err, m)
+			http.Error(w, m, http.StatusInternalServerError)
+			return
+		}
+		data := &unstructured.Unstructured{}
+		if err := json.Unmarshal(body, data); err != nil {
+			m := "Could not deserialize request body"
+			log.Error(err, m)
+			http.Error(w, m, http.StatusBadRequest)
+			return
+		}
+		data.SetLabels(merge(data.GetLabels(), i.Labels))
+		data.SetAnnotations(merge(data.GetAnnotations(), i.Annotations))
+		newBody, err := json.Marshal(data.Object)
+		if err != nil {
+			m := "Could not serialize body"
+			log.Error(err, m)
+			http.Error(w, m, http.StatusInternalServerError)
+			return
+		}
+		req.Body = ioutil.NopCloser(bytes.NewBuffer(newBody))
+		req.ContentLength = int64(len(newBody))
+		next.ServeHTTP(w, req)
+	})
+}
+
+// merge returns values with the values of injected that it does not have.
+func merge(values, injected map[string]string) map[string]string {
+	if len(injected) == 0 {
+		return values
+	}
+	if values == nil {
+		values = map[string]string{}
+	}
+	for k, v := range injected {
+		if _, ok := values[k]; !ok {
+			values[k] = v
+		}
+	}
+	return values
+}
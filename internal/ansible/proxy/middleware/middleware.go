@@ -0,0 +1,122 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package middleware provides the configurable middleware that requests of
+// runs pass through in the proxy, before they are sent to the API server.
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+)
+
+var log = logf.Log.WithName("proxy").WithName("middleware")
+
+// Config - the middleware of the proxy, in the order requests pass through it.
+type Config struct {
+	Middleware []Middleware `json:"middleware,omitempty"`
+}
+
+// Middleware - a middleware of the proxy. Exactly one of its fields is set.
+type Middleware struct {
+	// Audit logs each mutating request with the CR that it is made for.
+	Audit *Audit `json:"audit,omitempty"`
+	// RateLimit limits the rate of the requests made for each CR.
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+	// InjectMetadata adds labels and annotations to the objects that are created.
+	InjectMetadata *InjectMetadata `json:"injectMetadata,omitempty"`
+}
+
+// managerConfig is the part of the operator's manager config file that
+// configures the proxy. The rest of the file is read by controller-runtime.
+type managerConfig struct {
+	Proxy Config `json:"proxy"`
+}
+
+// LoadConfig loads the `proxy` section of the manager config file at path.
+func LoadConfig(path string) (Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read manager config file: %w", err)
+	}
+	mc := managerConfig{}
+	if err := yaml.Unmarshal(b, &mc); err != nil {
+		return Config{}, fmt.Errorf("failed to unmarshal proxy config: %w", err)
+	}
+	if err := mc.Proxy.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid proxy config: %w", err)
+	}
+	return mc.Proxy, nil
+}
+
+// Validate validates each middleware of c.
+func (c Config) Validate() error {
+	for i, m := range c.Middleware {
+		if err := m.validate(); err != nil {
+			return fmt.Errorf("middleware %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (m Middleware) validate() error {
+	set := 0
+	var err error
+	if m.Audit != nil {
+		set++
+	}
+	if m.RateLimit != nil {
+		set++
+		err = m.RateLimit.validate()
+	}
+	if m.InjectMetadata != nil {
+		set++
+		err = m.InjectMetadata.validate()
+	}
+	if set != 1 {
+		return errors.New("exactly one of audit, rateLimit or injectMetadata must be set")
+	}
+	return err
+}
+
+// Chain returns the handler chain of the middleware of c, which passes
+// requests through each middleware in order. It returns nil if c has no
+// middleware. c must be valid.
+func (c Config) Chain() func(http.Handler) http.Handler {
+	if len(c.Middleware) == 0 {
+		return nil
+	}
+	return func(h http.Handler) http.Handler {
+		for i := len(c.Middleware) - 1; i >= 0; i-- {
+			h = c.Middleware[i].handler(h)
+		}
+		return h
+	}
+}
+
+func (m Middleware) handler(next http.Handler) http.Handler {
+	switch {
+	case m.Audit != nil:
+		return m.Audit.handler(next)
+	case m.RateLimit != nil:
+		return m.RateLimit.handler(next)
+	default:
+		return m.InjectMetadata.handler(next)
+	}
+}
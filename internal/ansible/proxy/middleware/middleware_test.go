@@ -0,0 +1,237 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/auth"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "middleware")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "controller_manager_config.yaml")
+
+	config := `apiVersion: controller-runtime.sigs.k8s.io/v1alpha1
+kind: ControllerManagerConfig
+leaderElection:
+  leaderElect: true
+proxy:
+  middleware:
+    - audit: {}
+    - rateLimit:
+        qps: 5
+        burst: 10
+    - injectMetadata:
+        labels:
+          app.kubernetes.io/managed-by: memcached-operator
+`
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := Config{Middleware: []Middleware{
+		{Audit: &Audit{}},
+		{RateLimit: &RateLimit{QPS: 5, Burst: 10}},
+		{InjectMetadata: &InjectMetadata{Labels: map[string]string{"app.kubernetes.io/managed-by": "memcached-operator"}}},
+	}}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("Unexpected config: %+v expected: %+v", c, expected)
+	}
+
+	for _, invalid := range []string{
+		"proxy:\n  middleware:\n    - {}\n",
+		"proxy:\n  middleware:\n    - audit: {}\n      rateLimit: {qps: 1, burst: 1}\n",
+		"proxy:\n  middleware:\n    - rateLimit: {qps: 0, burst: 1}\n",
+		"proxy:\n  middleware:\n    - injectMetadata: {labels: {'invalid key': value}}\n",
+	} {
+		if err := ioutil.WriteFile(path, []byte(invalid), 0644); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := LoadConfig(path); err == nil {
+			t.Fatalf("Expected error for config: %s", invalid)
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	if (Config{}).Chain() != nil {
+		t.Fatal("Expected no chain without middleware")
+	}
+	var order []string
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		order = append(order, "next")
+	})
+	chain := Config{Middleware: []Middleware{
+		{InjectMetadata: &InjectMetadata{Labels: map[string]string{"first": "true"}}},
+		{InjectMetadata: &InjectMetadata{Labels: map[string]string{"first": "false", "second": "true"}}},
+	}}.Chain()
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		order = append(order, decodeBody(t, req).Labels["first"])
+		next.ServeHTTP(w, req)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), createRequest(t, metav1.ObjectMeta{Name: "test"}))
+	if !reflect.DeepEqual(order, []string{"true", "next"}) {
+		t.Fatalf("Unexpected order: %v", order)
+	}
+}
+
+func TestInjectMetadata(t *testing.T) {
+	m := &InjectMetadata{
+		Labels:      map[string]string{"team": "cache", "tier": "backend"},
+		Annotations: map[string]string{"example.com/audited": "true"},
+	}
+	var got metav1.ObjectMeta
+	h := m.handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got = decodeBody(t, req)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), createRequest(t, metav1.ObjectMeta{
+		Name:   "test",
+		Labels: map[string]string{"tier": "frontend"},
+	}))
+	expected := metav1.ObjectMeta{
+		Name:        "test",
+		Labels:      map[string]string{"team": "cache", "tier": "frontend"},
+		Annotations: map[string]string{"example.com/audited": "true"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Unexpected metadata: %+v expected: %+v", got, expected)
+	}
+
+	// Objects are only injected with metadata when they are created.
+	req := createRequest(t, metav1.ObjectMeta{Name: "test"})
+	req.Method = http.MethodPut
+	req.URL.Path += "/test"
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got.Labels != nil || got.Annotations != nil {
+		t.Fatalf("Unexpected metadata for update: %+v", got)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	r := &RateLimit{QPS: 1, Burst: 1}
+	h := r.handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	serve := func(uid string) int {
+		ctx := context.Background()
+		if uid != "" {
+			owner := kubeconfig.NamespacedOwnerReference{OwnerReference: metav1.OwnerReference{UID: types.UID(uid)}}
+			ctx = auth.WithIdentity(ctx, auth.Identity{Owner: owner})
+		}
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, createRequest(t, metav1.ObjectMeta{Name: "test"}).WithContext(ctx))
+		return rec.Code
+	}
+
+	if code := serve("a"); code != http.StatusOK {
+		t.Fatalf("Unexpected status code: %v", code)
+	}
+	if code := serve("a"); code != http.StatusTooManyRequests {
+		t.Fatalf("Unexpected status code over the rate limit: %v", code)
+	}
+	if code := serve("b"); code != http.StatusOK {
+		t.Fatalf("Unexpected status code of another CR: %v", code)
+	}
+	for i := 0; i < 3; i++ {
+		if code := serve(""); code != http.StatusOK {
+			t.Fatalf("Unexpected status code of request without owner: %v", code)
+		}
+	}
+}
+
+func TestAudit(t *testing.T) {
+	a := &Audit{}
+	h := a.handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, createRequest(t, metav1.ObjectMeta{Name: "test"}))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Unexpected status code: %v", rec.Code)
+	}
+}
+
+func TestAuditUpgrade(t *testing.T) {
+	a := &Audit{}
+	srv := httptest.NewServer(a.handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: SPDY/3.1\r\n\r\n"))
+	})))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/v1/namespaces/default/pods/test/exec", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "SPDY/3.1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Unexpected status code: %v", resp.StatusCode)
+	}
+}
+
+func createRequest(t *testing.T, meta metav1.ObjectMeta) *http.Request {
+	body, err := json.Marshal(map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap", "metadata": meta})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/namespaces/default/configmaps",
+		strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func decodeBody(t *testing.T, req *http.Request) metav1.ObjectMeta {
+	obj := struct {
+		Metadata metav1.ObjectMeta `json:"metadata"`
+	}{}
+	if err := json.NewDecoder(req.Body).Decode(&obj); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return obj.Metadata
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/flowcontrol"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/auth"
+)
+
+// limiterIdleTimeout is how long the rate limiter of a CR that makes no
+// requests is kept.
+const limiterIdleTimeout = 10 * time.Minute
+
+// RateLimit - limits the rate of the requests made for each CR to QPS, with
+// bursts of up to Burst requests. Requests over the limit wait until they are
+// within it. Requests that are not made for a CR are not limited.
+type RateLimit struct {
+	QPS   float32 `json:"qps"`
+	Burst int     `json:"burst"`
+}
+
+func (r *RateLimit) validate() error {
+	if r.QPS <= 0 {
+		return errors.New("rateLimit qps must be positive")
+	}
+	if r.Burst <= 0 {
+		return errors.New("rateLimit burst must be positive")
+	}
+	return nil
+}
+
+func (r *RateLimit) handler(next http.Handler) http.Handler {
+	l := &limiters{qps: r.QPS, burst: r.Burst, limiters: map[types.UID]*limiter{}}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id, ok := auth.IdentityFrom(req.Context())
+		if !ok {
+			next.ServeHTTP(w, req)
+			return
+		}
+		if err := l.get(id.Owner.UID).Wait(req.Context()); err != nil {
+			log.V(1).Info("Request was not admitted by the rate limit", "owner", id.Owner.Name,
+				"namespace", id.Owner.Namespace, "error", err.Error())
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// limiters are the rate limiters of CRs, by UID.
+type limiters struct {
+	qps       float32
+	burst     int
+	mu        sync.Mutex
+	limiters  map[types.UID]*limiter
+	lastPrune time.Time
+}
+
+type limiter struct {
+	flowcontrol.RateLimiter
+	lastUsed time.Time
+}
+
+func (l *limiters) get(uid types.UID) *limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastPrune) > limiterIdleTimeout {
+		for k, v := range l.limiters {
+			if now.Sub(v.lastUsed) > limiterIdleTimeout {
+				delete(l.limiters, k)
+			}
+		}
+		l.lastPrune = now
+	}
+	rl, ok := l.limiters[uid]
+	if !ok {
+		rl = &limiter{RateLimiter: flowcontrol.NewTokenBucketRateLimiter(l.qps, l.burst)}
+		l.limiters[uid] = rl
+	}
+	rl.lastUsed = now
+	return rl
+}
@@ -17,6 +17,7 @@ package proxy
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -40,6 +41,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/operator-framework/operator-sdk/internal/ansible/handler"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/auth"
 	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/controllermap"
 	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
 	k8sRequest "github.com/operator-framework/operator-sdk/internal/ansible/proxy/requestfactory"
@@ -50,6 +52,11 @@ import (
 const cacheEstablishmentTimeout = 6 * time.Second
 const AutoSkipCacheREList = "^/api/.*/pods/.*/exec,^/api/.*/pods/.*/attach"
 
+const (
+	impersonateHeaderPrefix = "Impersonate-"
+	impersonateUserHeader   = "Impersonate-User"
+)
+
 // RequestLogHandler - log the requests that come through the proxy.
 func RequestLogHandler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
@@ -69,7 +76,8 @@ func RequestLogHandler(h http.Handler) http.Handler {
 
 // HandlerChain will be used for users to pass defined handlers to the proxy.
 // The hander chain will be run after InjectingOwnerReference if it is added
-// and before the proxy handler.
+// and before the proxy handler. The identity of the run that makes a request is
+// in its context, see auth.IdentityFrom.
 type HandlerChain func(http.Handler) http.Handler
 
 // Options will be used by the user to specify the desired details
@@ -86,6 +94,11 @@ type Options struct {
 	DisableCache      bool
 	OwnerInjection    bool
 	LogRequests       bool
+	// Tokens authenticates requests with the bearer tokens issued to runs, if set.
+	// Requests without a valid token are rejected.
+	Tokens *auth.Tokens
+	// TLSConfig serves the proxy over TLS, if set.
+	TLSConfig *tls.Config
 }
 
 // Run will start a proxy server in a go routine that returns on the error
@@ -179,11 +192,19 @@ func Run(done chan error, o Options) error {
 			skipPathRegexp:    autoSkipCacheRegexp,
 		}
 	}
+	if o.Tokens != nil {
+		server.Handler = authenticate(server.Handler, o.Tokens)
+	} else {
+		server.Handler = identify(server.Handler)
+	}
 
 	l, err := server.Listen(o.Address, o.Port)
 	if err != nil {
 		return err
 	}
+	if o.TLSConfig != nil {
+		l = tls.NewListener(l, o.TLSConfig)
+	}
 	go func() {
 		log.Info("Starting to serve", "Address", l.Addr().String())
 		done <- server.ServeOnListener(l)
@@ -280,6 +301,41 @@ func addWatchToController(owner kubeconfig.NamespacedOwnerReference, cMap *contr
 	return nil
 }
 
+// authenticate rejects requests without a token issued to a run, and makes the
+// others as the identity of their run.
+func authenticate(h http.Handler, tokens *auth.Tokens) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id, ok := tokens.Authenticate(req)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		// Only the proxy may choose who requests are made as.
+		for header := range req.Header {
+			if strings.HasPrefix(header, impersonateHeaderPrefix) {
+				req.Header.Del(header)
+			}
+		}
+		if id.ImpersonateUser != "" {
+			req.Header.Set(impersonateUserHeader, id.ImpersonateUser)
+		}
+		h.ServeHTTP(w, req.WithContext(auth.WithIdentity(req.Context(), id)))
+	})
+}
+
+// identify puts the identity of the run that makes a request, from the owner
+// reference in its kubeconfig, in the request's context, so that the Handler
+// chain can tell which CR a request is made for once its Authorization header
+// was removed.
+func identify(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if owner, err := getRequestOwnerRef(req); err == nil && owner != nil {
+			req = req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{Owner: *owner}))
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
 func removeAuthorizationHeader(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		req.Header.Del("Authorization")
@@ -289,6 +345,10 @@ func removeAuthorizationHeader(h http.Handler) http.Handler {
 
 // Helper function used by recovering dependent watches and owner ref injection.
 func getRequestOwnerRef(req *http.Request) (*kubeconfig.NamespacedOwnerReference, error) {
+	if id, ok := auth.IdentityFrom(req.Context()); ok {
+		owner := id.Owner
+		return &owner, nil
+	}
 	owner := kubeconfig.NamespacedOwnerReference{}
 	user, _, ok := req.BasicAuth()
 	if !ok {
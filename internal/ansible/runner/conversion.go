@@ -0,0 +1,196 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/internal/inputdir"
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+)
+
+// ConvertedObjectStat is the stat that conversion playbooks set, with
+// set_stats, to the converted object.
+const ConvertedObjectStat = "converted_object"
+
+// Converter - converts CRs between the served versions of a GVK.
+type Converter interface {
+	Convert(ctx context.Context, ident string, u *unstructured.Unstructured,
+		desiredAPIVersion string) (*unstructured.Unstructured, error)
+}
+
+// NewConverter - creates a Converter that runs the conversion playbook of a
+// Watch.
+func NewConverter(watch watches.Watch, runnerArgs string, opts ...Option) (Converter, error) {
+	if watch.Conversion == nil {
+		return nil, fmt.Errorf("watch for GVK %v has no conversion", watch.GroupVersionKind)
+	}
+	if err := watch.Validate(); err != nil {
+		return nil, err
+	}
+	r := &runner{
+		Path:               watch.Conversion.Playbook,
+		GVK:                watch.GroupVersionKind,
+		Vars:               watch.Conversion.Vars,
+		cmdFunc:            playbookCmdFunc(watch.Conversion.Playbook),
+		jobFunc:            playbookJobFunc(watch.Conversion.Playbook),
+		maxRunnerArtifacts: watch.MaxRunnerArtifacts,
+		ansibleVerbosity:   watch.AnsibleVerbosity,
+		ansibleArgs:        runnerArgs,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return &converter{runner: r}, nil
+}
+
+type converter struct {
+	runner *runner
+}
+
+// Convert runs the conversion playbook for u, and returns the object that the
+// playbook set the converted_object stat to. The metadata of u is kept, so the
+// playbook only needs to convert the other fields. The playbook is stopped once
+// ctx is done.
+func (c *converter) Convert(ctx context.Context, ident string, u *unstructured.Unstructured,
+	desiredAPIVersion string) (*unstructured.Unstructured, error) {
+	r := c.runner
+	if r.pool == nil {
+		if _, err := exec.LookPath(ansibleRunnerBin); err != nil {
+			return nil, err
+		}
+	}
+	logger := log.WithValues(
+		"job", ident,
+		"name", u.GetName(),
+		"namespace", u.GetNamespace(),
+		"apiVersion", u.GetAPIVersion(),
+		"desiredAPIVersion", desiredAPIVersion,
+	)
+
+	errChan := make(chan error, 1)
+	receiver, err := eventapi.New(ident, errChan)
+	if err != nil {
+		return nil, err
+	}
+	// Conversions of the same CR may run concurrently, so each has its own
+	// input directory, which is removed once it completes.
+	inputDir := inputdir.InputDir{
+		Path:         filepath.Join("/tmp/ansible-operator/conversion/", r.GVK.Group, r.GVK.Kind, ident),
+		PlaybookPath: r.Path,
+		Parameters:   r.makeConversionParameters(u, desiredAPIVersion),
+		Settings: map[string]string{
+			"runner_http_url":  receiver.SocketPath,
+			"runner_http_path": receiver.URLPath,
+		},
+		CmdLine: r.ansibleArgs,
+	}
+	defer func() {
+		if err := os.RemoveAll(inputDir.Path); err != nil {
+			logger.Error(err, "Failed to remove conversion input directory")
+		}
+	}()
+	if err := inputDir.Write(); err != nil {
+		receiver.Close()
+		return nil, err
+	}
+
+	go func() {
+		r.execute(ctx, logger, false, ident, inputDir.Path, r.maxRunnerArtifacts, r.ansibleVerbosity, "")
+		receiver.Close()
+		// http.Server returns this in the case of being closed cleanly
+		if err := <-errChan; err != nil && err != http.ErrServerClosed {
+			logger.Error(err, "Error from event API")
+		}
+	}()
+
+	var stats *eventapi.JobEvent
+	var failures []string
+	for e := range receiver.Events {
+		e := e
+		switch e.Event {
+		case eventapi.EventRunnerOnFailed:
+			if !e.IgnoreError() && !e.Rescued() {
+				failures = append(failures, e.GetFailedPlaybookMessage())
+			}
+		case eventapi.EventPlaybookOnStats:
+			stats = &e
+		}
+	}
+	switch {
+	case ctx.Err() != nil:
+		return nil, fmt.Errorf("conversion playbook did not complete: %w", ctx.Err())
+	case len(failures) > 0:
+		return nil, fmt.Errorf("conversion playbook failed: %s", strings.Join(failures, "; "))
+	case stats == nil:
+		return nil, errors.New("conversion playbook did not complete")
+	}
+	return convertedObject(u, stats, desiredAPIVersion)
+}
+
+// makeConversionParameters - creates the extravars parameters for a
+// conversion playbook. The resulting structure in json is:
+// { "ansible_operator_meta": {
+//      "name": <object_name>,
+//      "namespace": <object_namespace>,
+//      "api_version": <object_api_version>,
+//   },
+//   "desired_api_version": <api_version_to_convert_to>,
+//   <conversion vars>,
+//   _<group_as_snake>_<kind>: {
+//       <cr_object> as is
+//   }
+// }
+func (r *runner) makeConversionParameters(u *unstructured.Unstructured,
+	desiredAPIVersion string) map[string]interface{} {
+	parameters := map[string]interface{}{}
+	for k, v := range r.Vars {
+		parameters[k] = v
+	}
+	parameters["ansible_operator_meta"] = makeMeta(u)
+	parameters["desired_api_version"] = desiredAPIVersion
+	objKey := escapeAnsibleKey(fmt.Sprintf("_%v_%v", r.GVK.Group, strings.ToLower(r.GVK.Kind)))
+	parameters[objKey] = u.Object
+	return parameters
+}
+
+// convertedObject returns the object set to the converted_object stat of a
+// conversion playbook, with the metadata of u.
+func convertedObject(u *unstructured.Unstructured, stats *eventapi.JobEvent,
+	desiredAPIVersion string) (*unstructured.Unstructured, error) {
+	artifacts, _ := stats.EventData["artifact_data"].(map[string]interface{})
+	object, ok := artifacts[ConvertedObjectStat].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("conversion playbook did not set the %s stat to an object", ConvertedObjectStat)
+	}
+	converted := &unstructured.Unstructured{Object: object}
+	if converted.GetAPIVersion() != desiredAPIVersion {
+		return nil, fmt.Errorf("conversion playbook converted to apiVersion %q instead of %q",
+			converted.GetAPIVersion(), desiredAPIVersion)
+	}
+	converted.SetKind(u.GetKind())
+	converted.Object["metadata"] = u.Object["metadata"]
+	return converted, nil
+}
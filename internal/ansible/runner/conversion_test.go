@@ -0,0 +1,111 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+)
+
+func newTestObject() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cache.example.com/v1alpha1",
+		"kind":       "Memcached",
+		"metadata":   map[string]interface{}{"name": "test", "namespace": "default"},
+		"spec":       map[string]interface{}{"size": int64(3)},
+	}}
+}
+
+func TestNewConverter(t *testing.T) {
+	playbook, err := filepath.Abs("testdata/playbook.yml")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	w := watches.New(schema.GroupVersionKind{Group: "cache.example.com", Version: "v1", Kind: "Memcached"},
+		"", playbook, nil, nil)
+	if _, err := NewConverter(*w, ""); err == nil {
+		t.Fatal("Expected error for watch without conversion")
+	}
+
+	w.Versions = []string{"v1alpha1", "v1"}
+	w.Conversion = &watches.Conversion{Playbook: playbook, Vars: map[string]interface{}{"sentinel": "converting"}}
+	c, err := NewConverter(*w, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	r := c.(*converter).runner
+	checkCmdFunc(t, r.cmdFunc, playbook, "", w.AnsibleVerbosity)
+	checkJobFunc(t, r.jobFunc, playbook, "", w.AnsibleVerbosity)
+
+	parameters := r.makeConversionParameters(newTestObject(), "cache.example.com/v1")
+	expected := map[string]interface{}{
+		"sentinel": "converting",
+		"ansible_operator_meta": map[string]string{
+			"name":        "test",
+			"namespace":   "default",
+			"api_version": "cache.example.com/v1alpha1",
+		},
+		"desired_api_version":          "cache.example.com/v1",
+		"_cache_example_com_memcached": newTestObject().Object,
+	}
+	if !reflect.DeepEqual(parameters, expected) {
+		t.Fatalf("Unexpected parameters: %+v expected: %+v", parameters, expected)
+	}
+}
+
+func TestConvertedObject(t *testing.T) {
+	stats := func(artifacts map[string]interface{}) *eventapi.JobEvent {
+		return &eventapi.JobEvent{
+			Event:     eventapi.EventPlaybookOnStats,
+			EventData: map[string]interface{}{"artifact_data": artifacts},
+		}
+	}
+	u := newTestObject()
+
+	if _, err := convertedObject(u, stats(nil), "cache.example.com/v1"); err == nil {
+		t.Fatal("Expected error for missing converted object")
+	}
+	wrongVersion := map[string]interface{}{ConvertedObjectStat: map[string]interface{}{
+		"apiVersion": "cache.example.com/v1beta1",
+	}}
+	if _, err := convertedObject(u, stats(wrongVersion), "cache.example.com/v1"); err == nil {
+		t.Fatal("Expected error for converted object of the wrong version")
+	}
+
+	converted, err := convertedObject(u, stats(map[string]interface{}{ConvertedObjectStat: map[string]interface{}{
+		"apiVersion": "cache.example.com/v1",
+		"metadata":   map[string]interface{}{"name": "renamed"},
+		"spec":       map[string]interface{}{"replicas": int64(3)},
+	}}), "cache.example.com/v1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]interface{}{
+		"apiVersion": "cache.example.com/v1",
+		"kind":       "Memcached",
+		"metadata":   map[string]interface{}{"name": "test", "namespace": "default"},
+		"spec":       map[string]interface{}{"replicas": int64(3)},
+	}
+	if !reflect.DeepEqual(converted.Object, expected) {
+		t.Fatalf("Unexpected converted object: %+v expected: %+v", converted.Object, expected)
+	}
+}
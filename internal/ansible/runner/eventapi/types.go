@@ -23,12 +23,18 @@ import (
 const (
 	// Ansible Events
 
+	// EventPlaybookOnPlayStart - playbook is starting to run a play.
+	EventPlaybookOnPlayStart = "playbook_on_play_start"
 	// EventPlaybookOnTaskStart - playbook is starting to run a task.
 	EventPlaybookOnTaskStart = "playbook_on_task_start"
 	// EventRunnerOnOk - task finished with ok status.
 	EventRunnerOnOk = "runner_on_ok"
 	// EventRunnerOnFailed - task finished with failed status.
 	EventRunnerOnFailed = "runner_on_failed"
+	// EventRunnerOnSkipped - task was skipped.
+	EventRunnerOnSkipped = "runner_on_skipped"
+	// EventRunnerOnUnreachable - task could not reach its host.
+	EventRunnerOnUnreachable = "runner_on_unreachable"
 	// EventPlaybookOnStats - playbook has finished running.
 	EventPlaybookOnStats = "playbook_on_stats"
 
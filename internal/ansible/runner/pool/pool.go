@@ -0,0 +1,363 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pool runs ansible-runner jobs in a pool of Python worker processes,
+// which import ansible-runner once instead of on every run of the
+// ansible-runner command. ansible-runner still starts ansible-playbook as a new
+// process for every job, so Ansible itself is imported on every run.
+package pool
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("worker-pool")
+
+//go:embed worker.py
+var workerScript []byte
+
+const (
+	defaultStartTimeout = 30 * time.Second
+	maxRestartDelay     = time.Minute
+)
+
+// stopGracePeriod is how long a worker has to report the result of a job that
+// ansible-runner stopped at the job's deadline, before it is killed.
+var stopGracePeriod = 10 * time.Second
+
+// ErrUnavailable is returned by Run when no worker is idle, in which case the
+// job should be run by the ansible-runner command instead.
+var ErrUnavailable = errors.New("no ansible worker available")
+
+// Job is a run of a playbook or role, with the arguments of ansible_runner.run.
+type Job struct {
+	Ident           string            `json:"ident"`
+	PrivateDataDir  string            `json:"private_data_dir"`
+	Playbook        string            `json:"playbook,omitempty"`
+	Role            string            `json:"role,omitempty"`
+	RolesPath       string            `json:"roles_path,omitempty"`
+	Hosts           string            `json:"hosts,omitempty"`
+	RoleSkipFacts   bool              `json:"role_skip_facts,omitempty"`
+	RotateArtifacts int               `json:"rotate_artifacts"`
+	Verbosity       int               `json:"verbosity,omitempty"`
+	EnvVars         map[string]string `json:"envvars,omitempty"`
+
+	// Timeout is the number of seconds after which ansible-runner stops the
+	// job. Run sets it from the deadline of its context.
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// Result is the outcome of a Job.
+type Result struct {
+	// RC is the return code of ansible-playbook.
+	RC int `json:"rc"`
+	// Status is the status of the run reported by ansible-runner.
+	Status string `json:"status"`
+	// Error is set if ansible-runner could not run the job.
+	Error string `json:"error,omitempty"`
+}
+
+// Options configures a Pool.
+type Options struct {
+	// Size is the number of workers.
+	Size int
+	// Command is the command that starts a worker. It defaults to python3
+	// running the bundled worker script, written to ScriptDir.
+	Command []string
+	// ScriptDir is the directory the worker script is written to.
+	// It defaults to /tmp/ansible-operator.
+	ScriptDir string
+	// StartTimeout is how long a worker has to become ready. It defaults to 30s.
+	StartTimeout time.Duration
+	// JobTimeout is how long a job may run before it is stopped. Zero means
+	// jobs are never stopped.
+	JobTimeout time.Duration
+}
+
+// Pool is a pool of ansible workers. Start it by adding it to a manager.
+type Pool struct {
+	command      []string
+	size         int
+	startTimeout time.Duration
+	jobTimeout   time.Duration
+
+	idle chan *worker
+
+	mu      sync.Mutex
+	workers map[*worker]struct{}
+	closed  bool
+}
+
+// New creates a Pool of opts.Size workers.
+func New(opts Options) (*Pool, error) {
+	if opts.Size < 1 {
+		return nil, fmt.Errorf("worker pool size must be positive, got %d", opts.Size)
+	}
+	if opts.StartTimeout == 0 {
+		opts.StartTimeout = defaultStartTimeout
+	}
+	if len(opts.Command) == 0 {
+		if opts.ScriptDir == "" {
+			opts.ScriptDir = "/tmp/ansible-operator"
+		}
+		if err := os.MkdirAll(opts.ScriptDir, 0700); err != nil {
+			return nil, err
+		}
+		script := filepath.Join(opts.ScriptDir, "worker.py")
+		if err := ioutil.WriteFile(script, workerScript, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write ansible worker script: %w", err)
+		}
+		opts.Command = []string{"python3", "-u", script}
+	}
+	return &Pool{
+		command:      opts.Command,
+		size:         opts.Size,
+		startTimeout: opts.StartTimeout,
+		jobTimeout:   opts.JobTimeout,
+		idle:         make(chan *worker, opts.Size),
+		workers:      map[*worker]struct{}{},
+	}, nil
+}
+
+// Start starts the workers and stops them when ctx is done. Workers that fail
+// to start are retried in the background, and jobs fall back to the
+// ansible-runner command in the meantime.
+func (p *Pool) Start(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for i := 0; i < p.size; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.startWorker(); err != nil {
+				log.Error(err, "Failed to start ansible worker, running jobs with ansible-runner until it starts")
+				go p.restartWorker(ctx)
+			}
+		}()
+	}
+	wg.Wait()
+	log.Info("Started ansible worker pool", "workers", len(p.idle))
+
+	<-ctx.Done()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for w := range p.workers {
+		w.stop()
+	}
+	return nil
+}
+
+// Run runs job in an idle worker and waits for its result. It returns
+// ErrUnavailable if no worker is idle. The job is stopped when ctx is done or
+// the pool's job timeout passes, whichever is first; if the worker does not
+// report the result of the stopped job in time, it is killed and replaced.
+func (p *Pool) Run(ctx context.Context, job Job) (Result, error) {
+	var w *worker
+	select {
+	case w = <-p.idle:
+	default:
+		return Result{}, ErrUnavailable
+	}
+
+	if p.jobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.jobTimeout)
+		defer cancel()
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		job.Timeout = int(math.Max(1, math.Ceil(time.Until(deadline).Seconds())))
+	}
+	res, err := w.run(ctx, job)
+	if err != nil {
+		p.remove(w)
+		go p.restartWorker(context.Background())
+		return Result{}, fmt.Errorf("ansible worker failed: %w", err)
+	}
+	p.idle <- w
+	return res, nil
+}
+
+func (p *Pool) startWorker() error {
+	w, err := startWorker(p.command, p.startTimeout)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		w.stop()
+		return nil
+	}
+	p.workers[w] = struct{}{}
+	p.idle <- w
+	return nil
+}
+
+// restartWorker starts a worker in place of one that failed, backing off until
+// it starts or the pool is stopped.
+func (p *Pool) restartWorker(ctx context.Context) {
+	delay := time.Second
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if p.isClosed() {
+			return
+		}
+		err := p.startWorker()
+		if err == nil {
+			return
+		}
+		log.V(1).Info("Failed to restart ansible worker", "error", err.Error())
+		if delay *= 2; delay > maxRestartDelay {
+			delay = maxRestartDelay
+		}
+	}
+}
+
+func (p *Pool) remove(w *worker) {
+	w.stop()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.workers, w)
+}
+
+func (p *Pool) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// worker is a worker process, which runs one job at a time.
+type worker struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	out   *bufio.Reader
+
+	// stopOnce stops the worker once, since both Run and Start stop workers.
+	stopOnce sync.Once
+}
+
+func startWorker(command []string, timeout time.Duration) (*worker, error) {
+	cmd := exec.Command(command[0], command[1:]...) //nolint:gosec
+	cmd.Stderr = os.Stderr
+	// Put the worker in its own process group, so that stopping the worker also
+	// stops the job process it forked. ansible-playbook runs in a session of its
+	// own, and is stopped by ansible-runner at the job's deadline.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	w := &worker{cmd: cmd, stdin: stdin, out: bufio.NewReader(stdout)}
+
+	ready := make(chan error, 1)
+	go func() {
+		var msg struct {
+			Ready bool `json:"ready"`
+		}
+		err := w.read(&msg)
+		if err == nil && !msg.Ready {
+			err = errors.New("unexpected message from worker")
+		}
+		ready <- err
+	}()
+	select {
+	case err = <-ready:
+	case <-time.After(timeout):
+		err = fmt.Errorf("worker was not ready after %s", timeout)
+	}
+	if err != nil {
+		w.stop()
+		return nil, err
+	}
+	return w, nil
+}
+
+// run sends job to the worker and waits for its result. Once ctx is done the
+// worker has stopGracePeriod to report the result, since ansible-runner stops
+// the job at the same deadline; after that run gives up with ctx's error, and
+// the worker must be stopped.
+func (w *worker) run(ctx context.Context, job Job) (Result, error) {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return Result{}, err
+	}
+	if _, err := w.stdin.Write(append(data, '\n')); err != nil {
+		return Result{}, err
+	}
+
+	type result struct {
+		res Result
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var res Result
+		err := w.read(&res)
+		done <- result{res, err}
+	}()
+	select {
+	case r := <-done:
+		return r.res, r.err
+	case <-ctx.Done():
+	}
+	select {
+	case r := <-done:
+		return r.res, r.err
+	case <-time.After(stopGracePeriod):
+		return Result{}, fmt.Errorf("job %s did not stop: %w", job.Ident, ctx.Err())
+	}
+}
+
+func (w *worker) read(v interface{}) error {
+	line, err := w.out.ReadBytes('\n')
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(line, v)
+}
+
+func (w *worker) stop() {
+	w.stopOnce.Do(func() {
+		_ = w.stdin.Close()
+		_ = syscall.Kill(-w.cmd.Process.Pid, syscall.SIGKILL)
+		_ = w.cmd.Wait()
+	})
+}
@@ -0,0 +1,115 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeWorker speaks the worker protocol, exits on jobs whose ident is "crash",
+// and never finishes jobs whose ident is "hang".
+const fakeWorker = `
+echo '{"ready": true}'
+while read -r line; do
+  case "$line" in
+    *'"ident":"crash"'*) exit 1;;
+    *'"ident":"hang"'*) sleep 3600;;
+    *'"timeout":'*) echo '{"rc": 254, "status": "timeout"}';;
+    *) echo '{"rc": 2, "status": "failed"}';;
+  esac
+done
+`
+
+func startPool(t *testing.T, command ...string) (*Pool, context.CancelFunc) {
+	p, err := New(Options{Size: 1, Command: command, StartTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { _ = p.Start(ctx) }()
+	return p, cancel
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Options{Size: 0})
+	assert.Error(t, err)
+
+	dir := t.TempDir()
+	p, err := New(Options{Size: 2, ScriptDir: dir})
+	assert.NoError(t, err)
+	assert.FileExists(t, dir+"/worker.py")
+	assert.Equal(t, []string{"python3", "-u", dir + "/worker.py"}, p.command)
+}
+
+func TestPoolRun(t *testing.T) {
+	p, cancel := startPool(t, "sh", "-c", fakeWorker)
+	defer cancel()
+
+	_, err := p.Run(context.Background(), Job{Ident: "1"})
+	assert.Equal(t, ErrUnavailable, err)
+
+	assert.Eventually(t, func() bool { return len(p.idle) == 1 }, 5*time.Second, 10*time.Millisecond)
+	res, err := p.Run(context.Background(), Job{Ident: "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, Result{RC: 2, Status: "failed"}, res)
+
+	// A worker that dies is replaced.
+	_, err = p.Run(context.Background(), Job{Ident: "crash"})
+	assert.Error(t, err)
+	assert.NotEqual(t, ErrUnavailable, err)
+	_, err = p.Run(context.Background(), Job{Ident: "2"})
+	assert.Equal(t, ErrUnavailable, err)
+	assert.Eventually(t, func() bool { return len(p.idle) == 1 }, 5*time.Second, 10*time.Millisecond)
+	_, err = p.Run(context.Background(), Job{Ident: "2"})
+	assert.NoError(t, err)
+}
+
+func TestPoolWorkerNotReady(t *testing.T) {
+	p, cancel := startPool(t, "sh", "-c", "echo not-json")
+	defer cancel()
+
+	time.Sleep(100 * time.Millisecond)
+	_, err := p.Run(context.Background(), Job{Ident: "1"})
+	assert.Equal(t, ErrUnavailable, err)
+}
+
+func TestPoolRunTimeout(t *testing.T) {
+	p, cancel := startPool(t, "sh", "-c", fakeWorker)
+	defer cancel()
+	assert.Eventually(t, func() bool { return len(p.idle) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// The deadline is passed to the worker, which reports the stopped job.
+	ctx, cancelRun := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelRun()
+	res, err := p.Run(ctx, Job{Ident: "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, Result{RC: 254, Status: "timeout"}, res)
+
+	// A worker that does not report a stopped job is killed and replaced.
+	defer func(d time.Duration) { stopGracePeriod = d }(stopGracePeriod)
+	stopGracePeriod = 10 * time.Millisecond
+	ctx, cancelRun = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelRun()
+	_, err = p.Run(ctx, Job{Ident: "hang"})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Eventually(t, func() bool { return len(p.idle) == 1 }, 5*time.Second, 10*time.Millisecond)
+	_, err = p.Run(context.Background(), Job{Ident: "2"})
+	assert.NoError(t, err)
+}
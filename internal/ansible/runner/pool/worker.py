@@ -0,0 +1,108 @@
+# Copyright 2021 The Operator-SDK Authors
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+"""Ansible worker of the ansible-operator worker pool.
+
+The worker imports ansible-runner once, then reads jobs from stdin as JSON
+lines and writes each job's result to stdout as a JSON line. Every job runs in
+a forked child, so that jobs share none of the state ansible-runner builds up.
+
+ansible-runner still starts ansible-playbook as a new process through pexpect,
+so the worker saves the startup of the ansible-runner command, but not the
+import of Ansible by ansible-playbook.
+"""
+
+import json
+import os
+import sys
+import traceback
+
+import ansible_runner
+
+
+def run_args(job):
+    args = {
+        'private_data_dir': job['private_data_dir'],
+        'ident': job['ident'],
+        'rotate_artifacts': job.get('rotate_artifacts', 0),
+        'quiet': True,
+    }
+    if job.get('verbosity'):
+        args['verbosity'] = job['verbosity']
+    if job.get('timeout'):
+        # ansible-runner stops ansible-playbook and reports the status
+        # "timeout" once the job's deadline passes.
+        args['timeout'] = job['timeout']
+    if job.get('playbook'):
+        args['playbook'] = job['playbook']
+    else:
+        args['role'] = job['role']
+        args['roles_path'] = job.get('roles_path')
+        args['hosts'] = job.get('hosts') or 'all'
+        args['role_skip_facts'] = job.get('role_skip_facts', False)
+    return args
+
+
+def run_child(job, out):
+    devnull = os.open(os.devnull, os.O_RDWR)
+    os.dup2(devnull, 0)
+    os.dup2(devnull, 1)
+    os.environ.update(job.get('envvars') or {})
+    try:
+        r = ansible_runner.run(**run_args(job))
+        result = {'rc': r.rc, 'status': r.status}
+    except Exception:
+        result = {'rc': -1, 'status': 'failed', 'error': traceback.format_exc()}
+    with os.fdopen(out, 'w') as f:
+        json.dump(result, f)
+
+
+def run_job(job):
+    r, w = os.pipe()
+    pid = os.fork()
+    if pid == 0:
+        os.close(r)
+        try:
+            run_child(job, w)
+        finally:
+            os._exit(0)
+    os.close(w)
+    with os.fdopen(r) as f:
+        data = f.read()
+    _, status = os.waitpid(pid, 0)
+    if not data:
+        return {'rc': -1, 'status': 'failed',
+                'error': 'job process exited with status {}'.format(status)}
+    return json.loads(data)
+
+
+def main():
+    out = sys.stdout
+    out.write(json.dumps({'ready': True}) + '\n')
+    out.flush()
+    for line in sys.stdin:
+        if not line.strip():
+            continue
+        try:
+            job = json.loads(line)
+            result = run_job(job)
+            result['ident'] = job.get('ident')
+        except Exception:
+            result = {'rc': -1, 'status': 'failed', 'error': traceback.format_exc()}
+        out.write(json.dumps(result) + '\n')
+        out.flush()
+
+
+if __name__ == '__main__':
+    main()
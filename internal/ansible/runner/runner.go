@@ -15,6 +15,8 @@
 package runner
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -24,6 +26,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -32,6 +35,7 @@ import (
 	"github.com/operator-framework/operator-sdk/internal/ansible/paramconv"
 	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
 	"github.com/operator-framework/operator-sdk/internal/ansible/runner/internal/inputdir"
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/pool"
 	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
 )
 
@@ -50,6 +54,10 @@ const (
 	AnsibleVerbosityAnnotation = "ansible.sdk.operatorframework.io/verbosity"
 
 	ansibleRunnerBin = "ansible-runner"
+
+	// Modes in which ansible-runner is executed.
+	modeExec = "exec"
+	modePool = "pool"
 )
 
 // Runner - a runnable that should take the parameters and name and namespace
@@ -119,10 +127,52 @@ func roleCmdFunc(path string) cmdFuncType {
 	}
 }
 
+type jobFuncType func(ident, inputDirPath string, maxArtifacts, verbosity int) pool.Job
+
+func playbookJobFunc(path string) jobFuncType {
+	return func(ident, inputDirPath string, maxArtifacts, verbosity int) pool.Job {
+		return pool.Job{
+			Ident:           ident,
+			PrivateDataDir:  inputDirPath,
+			Playbook:        path,
+			RotateArtifacts: maxArtifacts,
+			Verbosity:       verbosity,
+		}
+	}
+}
+
+func roleJobFunc(path string) jobFuncType {
+	rolePath, roleName := filepath.Split(path)
+	return func(ident, inputDirPath string, maxArtifacts, verbosity int) pool.Job {
+		return pool.Job{
+			Ident:           ident,
+			PrivateDataDir:  inputDirPath,
+			Role:            roleName,
+			RolesPath:       rolePath,
+			Hosts:           "localhost",
+			RoleSkipFacts:   os.Getenv("ANSIBLE_GATHERING") == "explicit",
+			RotateArtifacts: maxArtifacts,
+			Verbosity:       verbosity,
+		}
+	}
+}
+
+// Option configures a Runner.
+type Option func(*runner)
+
+// WithWorkerPool runs ansible-runner in the workers of p, rather than as a
+// command, whenever one of them is idle.
+func WithWorkerPool(p *pool.Pool) Option {
+	return func(r *runner) {
+		r.pool = p
+	}
+}
+
 // New - creates a Runner from a Watch struct
-func New(watch watches.Watch, runnerArgs string) (Runner, error) {
+func New(watch watches.Watch, runnerArgs string, opts ...Option) (Runner, error) {
 	var path string
 	var cmdFunc, finalizerCmdFunc cmdFuncType
+	var jobFunc, finalizerJobFunc jobFuncType
 
 	err := watch.Validate()
 	if err != nil {
@@ -134,9 +184,11 @@ func New(watch watches.Watch, runnerArgs string) (Runner, error) {
 	case watch.Playbook != "":
 		path = watch.Playbook
 		cmdFunc = playbookCmdFunc(path)
+		jobFunc = playbookJobFunc(path)
 	case watch.Role != "":
 		path = watch.Role
 		cmdFunc = roleCmdFunc(path)
+		jobFunc = roleJobFunc(path)
 	}
 
 	// handle finalizer
@@ -145,25 +197,34 @@ func New(watch watches.Watch, runnerArgs string) (Runner, error) {
 		finalizerCmdFunc = nil
 	case watch.Finalizer.Playbook != "":
 		finalizerCmdFunc = playbookCmdFunc(watch.Finalizer.Playbook)
+		finalizerJobFunc = playbookJobFunc(watch.Finalizer.Playbook)
 	case watch.Finalizer.Role != "":
 		finalizerCmdFunc = roleCmdFunc(watch.Finalizer.Role)
+		finalizerJobFunc = roleJobFunc(watch.Finalizer.Role)
 	default:
 		finalizerCmdFunc = cmdFunc
+		finalizerJobFunc = jobFunc
 	}
 
-	return &runner{
+	r := &runner{
 		Path:                path,
 		cmdFunc:             cmdFunc,
+		jobFunc:             jobFunc,
 		Vars:                watch.Vars,
 		Finalizer:           watch.Finalizer,
 		finalizerCmdFunc:    finalizerCmdFunc,
+		finalizerJobFunc:    finalizerJobFunc,
 		GVK:                 watch.GroupVersionKind,
 		maxRunnerArtifacts:  watch.MaxRunnerArtifacts,
 		ansibleVerbosity:    watch.AnsibleVerbosity,
 		ansibleArgs:         runnerArgs,
 		snakeCaseParameters: watch.SnakeCaseParameters,
 		markUnsafe:          watch.MarkUnsafe,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
 }
 
 // runner - implements the Runner interface for a GVK that's being watched.
@@ -174,6 +235,9 @@ type runner struct {
 	Vars                map[string]interface{}
 	cmdFunc             cmdFuncType // returns a Cmd that runs ansible-runner
 	finalizerCmdFunc    cmdFuncType
+	jobFunc             jobFuncType // returns a Job that runs ansible-runner in a pooled worker
+	finalizerJobFunc    jobFuncType
+	pool                *pool.Pool
 	maxRunnerArtifacts  int
 	ansibleVerbosity    int
 	snakeCaseParameters bool
@@ -206,8 +270,7 @@ func (r *runner) Run(ident string, u *unstructured.Unstructured, kubeconfig stri
 		return nil, err
 	}
 	inputDir := inputdir.InputDir{
-		Path: filepath.Join("/tmp/ansible-operator/runner/", r.GVK.Group, r.GVK.Version, r.GVK.Kind,
-			u.GetNamespace(), u.GetName()),
+		Path:       InputDirPath(r.GVK, u),
 		Parameters: r.makeParameters(u),
 		EnvVars: map[string]string{
 			"K8S_AUTH_KUBECONFIG": kubeconfig,
@@ -253,25 +316,13 @@ func (r *runner) Run(ident string, u *unstructured.Unstructured, kubeconfig stri
 	}
 
 	go func() {
-		var dc *exec.Cmd
-		if r.isFinalizerRun(u) {
+		finalizerRun := r.isFinalizerRun(u)
+		if finalizerRun {
 			logger.V(1).Info("Resource is marked for deletion, running finalizer",
 				"Finalizer", r.Finalizer.Name)
-			dc = r.finalizerCmdFunc(ident, inputDir.Path, maxArtifacts, verbosity)
-		} else {
-			dc = r.cmdFunc(ident, inputDir.Path, maxArtifacts, verbosity)
-		}
-		// Append current environment since setting dc.Env to anything other than nil overwrites current env
-		dc.Env = append(dc.Env, os.Environ()...)
-		dc.Env = append(dc.Env, fmt.Sprintf("K8S_AUTH_KUBECONFIG=%s", kubeconfig),
-			fmt.Sprintf("KUBECONFIG=%s", kubeconfig))
-
-		output, err := dc.CombinedOutput()
-		if err != nil {
-			logger.Error(err, string(output))
-		} else {
-			logger.Info("Ansible-runner exited successfully")
 		}
+		observeRun := metrics.RunTimer(r.GVK.String())
+		observeRun(r.execute(context.TODO(), logger, finalizerRun, ident, inputDir.Path, maxArtifacts, verbosity, kubeconfig))
 
 		receiver.Close()
 		err = <-errChan
@@ -306,6 +357,98 @@ func (r *runner) Run(ident string, u *unstructured.Unstructured, kubeconfig stri
 	}, nil
 }
 
+// InputDirPath returns the path of the input dir of the runs for u. The
+// artifacts of each run are kept in its artifacts directory, by the ident of
+// the run.
+func InputDirPath(gvk schema.GroupVersionKind, u *unstructured.Unstructured) string {
+	return filepath.Join("/tmp/ansible-operator/runner/", gvk.Group, gvk.Version, gvk.Kind,
+		u.GetNamespace(), u.GetName())
+}
+
+// execute runs ansible-runner in a pooled worker if one is idle, and as a
+// command otherwise or if the worker fails, and stops it once ctx is done. It
+// returns the mode ansible-runner was executed in.
+func (r *runner) execute(ctx context.Context, logger logr.Logger, finalizerRun bool, ident, inputDirPath string,
+	maxArtifacts, verbosity int, kubeconfig string) string {
+	if r.pool != nil {
+		jobFunc := r.jobFunc
+		if finalizerRun {
+			jobFunc = r.finalizerJobFunc
+		}
+		job := jobFunc(ident, inputDirPath, maxArtifacts, verbosity)
+		if kubeconfig != "" {
+			job.EnvVars = map[string]string{
+				"K8S_AUTH_KUBECONFIG": kubeconfig,
+				"KUBECONFIG":          kubeconfig,
+			}
+		}
+		res, err := r.pool.Run(ctx, job)
+		switch {
+		case errors.Is(err, pool.ErrUnavailable):
+			logger.V(1).Info("No ansible worker is idle, running ansible-runner command")
+		case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled):
+			logger.Error(err, "Ansible-runner was stopped in worker")
+			return modePool
+		case err != nil:
+			logger.Error(err, "Failed to run ansible-runner in worker, running ansible-runner command")
+		case res.Error != "":
+			logger.Error(errors.New(res.Error), "Ansible-runner failed in worker")
+			return modePool
+		case res.RC != 0:
+			logger.Error(fmt.Errorf("ansible-runner exited with status %q and rc %d", res.Status, res.RC),
+				"Ansible-runner failed in worker")
+			return modePool
+		default:
+			logger.Info("Ansible-runner exited successfully")
+			return modePool
+		}
+	}
+
+	var dc *exec.Cmd
+	if finalizerRun {
+		dc = r.finalizerCmdFunc(ident, inputDirPath, maxArtifacts, verbosity)
+	} else {
+		dc = r.cmdFunc(ident, inputDirPath, maxArtifacts, verbosity)
+	}
+	// Append current environment since setting dc.Env to anything other than nil overwrites current env
+	dc.Env = append(dc.Env, os.Environ()...)
+	if kubeconfig != "" {
+		dc.Env = append(dc.Env, fmt.Sprintf("K8S_AUTH_KUBECONFIG=%s", kubeconfig),
+			fmt.Sprintf("KUBECONFIG=%s", kubeconfig))
+	}
+
+	output, err := combinedOutput(ctx, dc)
+	if err != nil {
+		logger.Error(err, string(output))
+	} else {
+		logger.Info("Ansible-runner exited successfully")
+	}
+	return modeExec
+}
+
+// combinedOutput runs cmd like cmd.CombinedOutput, and kills it once ctx is
+// done. Killing ansible-runner closes the terminal that ansible-playbook runs
+// in, which stops ansible-playbook as well.
+func combinedOutput(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = cmd.Process.Kill()
+		case <-done:
+		}
+	}()
+	err := cmd.Wait()
+	return output.Bytes(), err
+}
+
 func (r *runner) isFinalizerRun(u *unstructured.Unstructured) bool {
 	finalizersSet := r.Finalizer != nil && u.GetFinalizers() != nil
 	// The resource is deleted and our finalizer is present, we need to run the finalizer
@@ -324,6 +467,7 @@ func (r *runner) isFinalizerRun(u *unstructured.Unstructured) bool {
 // { "ansible_operator_meta": {
 //      "name": <object_name>,
 //      "namespace": <object_namespace>,
+//      "api_version": <object_api_version>,
 //   },
 //   <cr_spec_fields_as_snake_case>,
 //   <watch vars>,
@@ -360,7 +504,7 @@ func (r *runner) makeParameters(u *unstructured.Unstructured) map[string]interfa
 		}
 	}
 
-	parameters["ansible_operator_meta"] = map[string]string{"namespace": u.GetNamespace(), "name": u.GetName()}
+	parameters["ansible_operator_meta"] = makeMeta(u)
 
 	objKey := escapeAnsibleKey(fmt.Sprintf("_%v_%v", r.GVK.Group, strings.ToLower(r.GVK.Kind)))
 	parameters[objKey] = u.Object
@@ -379,6 +523,12 @@ func (r *runner) makeParameters(u *unstructured.Unstructured) map[string]interfa
 	return parameters
 }
 
+// makeMeta returns the metadata of u that is passed to ansible as
+// ansible_operator_meta.
+func makeMeta(u *unstructured.Unstructured) map[string]string {
+	return map[string]string{"namespace": u.GetNamespace(), "name": u.GetName(), "api_version": u.GetAPIVersion()}
+}
+
 // markUnsafe recursively checks for string values and marks them unsafe.
 // for eg:
 //		spec:
@@ -15,15 +15,20 @@
 package runner
 
 import (
+	"context"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/pool"
 	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
 )
 
@@ -50,6 +55,28 @@ func checkCmdFunc(t *testing.T, cmdFunc cmdFuncType, playbook, role string, verb
 	}
 }
 
+func checkJobFunc(t *testing.T, jobFunc jobFuncType, playbook, role string, verbosity int) {
+	expectedJob := pool.Job{
+		Ident:           "test",
+		PrivateDataDir:  "/test/path",
+		RotateArtifacts: 1,
+		Verbosity:       verbosity,
+	}
+	switch {
+	case playbook != "":
+		expectedJob.Playbook = playbook
+	case role != "":
+		expectedJob.RolesPath, expectedJob.Role = filepath.Split(role)
+		expectedJob.Hosts = "localhost"
+	}
+
+	gotJob := jobFunc("test", "/test/path", 1, verbosity)
+
+	if !reflect.DeepEqual(expectedJob, gotJob) {
+		t.Fatalf("Unexpected job %+v expected job %+v", gotJob, expectedJob)
+	}
+}
+
 func TestNew(t *testing.T) {
 	cwd, err := os.Getwd()
 	if err != nil {
@@ -197,8 +224,9 @@ func TestNew(t *testing.T) {
 					testRunnerStruct.maxRunnerArtifacts, testWatch.MaxRunnerArtifacts)
 			}
 
-			// Check the cmdFunc
+			// Check the cmdFunc and jobFunc
 			checkCmdFunc(t, testRunnerStruct.cmdFunc, testWatch.Playbook, testWatch.Role, testWatch.AnsibleVerbosity)
+			checkJobFunc(t, testRunnerStruct.jobFunc, testWatch.Playbook, testWatch.Role, testWatch.AnsibleVerbosity)
 
 			// Check finalizer
 			if testRunnerStruct.Finalizer != testWatch.Finalizer {
@@ -215,10 +243,14 @@ func TestNew(t *testing.T) {
 				if len(testWatch.Finalizer.Vars) == 0 {
 					checkCmdFunc(t, testRunnerStruct.cmdFunc, testWatch.Finalizer.Playbook, testWatch.Finalizer.Role,
 						testWatch.AnsibleVerbosity)
+					checkJobFunc(t, testRunnerStruct.finalizerJobFunc, testWatch.Finalizer.Playbook,
+						testWatch.Finalizer.Role, testWatch.AnsibleVerbosity)
 				} else {
 					// when finalizer vars is set the finalizerCmdFunc should be the same as the cmdFunc
 					checkCmdFunc(t, testRunnerStruct.finalizerCmdFunc, testWatch.Playbook, testWatch.Role,
 						testWatch.AnsibleVerbosity)
+					checkJobFunc(t, testRunnerStruct.finalizerJobFunc, testWatch.Playbook, testWatch.Role,
+						testWatch.AnsibleVerbosity)
 				}
 			}
 		})
@@ -371,3 +403,42 @@ func TestMakeParameters(t *testing.T) {
 		}
 	}
 }
+
+func TestExecuteWorkerFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runner")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	// The worker marks that it received a job, and then fails.
+	marker := filepath.Join(dir, "job")
+	p, err := pool.New(pool.Options{Size: 1, Command: []string{"sh", "-c",
+		`echo '{"ready": true}'; read -r line; touch "$0"; exit 1`, marker}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = p.Start(ctx) }()
+
+	r := &runner{
+		pool:    p,
+		jobFunc: playbookJobFunc("site.yml"),
+		cmdFunc: func(string, string, int, int) *exec.Cmd { return exec.Command("true") },
+	}
+	// Jobs run by the command until the worker is ready, and by the command
+	// again once the worker fails.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if mode := r.execute(context.TODO(), logr.Discard(), false, "test", dir, 1, 0, ""); mode != modeExec {
+			t.Fatalf("Unexpected mode %v expected mode %v", mode, modeExec)
+		}
+		if _, err := os.Stat(marker); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Worker did not receive a job")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -38,7 +38,9 @@ import (
 var log = logf.Log.WithName("watches")
 
 // Watch - holds data used to create a mapping of GVK to ansible playbook or role.
-// The mapping is used to compose an ansible operator.
+// The mapping is used to compose an ansible operator. If Versions is set, they
+// are the served versions of the GVK, whose Version is the storage version
+// that is reconciled.
 type Watch struct {
 	GroupVersionKind            schema.GroupVersionKind   `yaml:",inline"`
 	Blacklist                   []schema.GroupVersionKind `yaml:"blacklist"`
@@ -54,6 +56,12 @@ type Watch struct {
 	SnakeCaseParameters         bool                      `yaml:"snakeCaseParameters"`
 	MarkUnsafe                  bool                      `yaml:"markUnsafe"`
 	Selector                    metav1.LabelSelector      `yaml:"selector"`
+	TaskResults                 int                       `yaml:"taskResults"`
+	KubernetesEvents            KubernetesEvents          `yaml:"kubernetesEvents"`
+	Impersonate                 *Impersonate              `yaml:"impersonate"`
+	Versions                    []string                  `yaml:"versions"`
+	Conversion                  *Conversion               `yaml:"conversion"`
+	Conditions                  []Condition               `yaml:"conditions"`
 
 	// Not configurable via watches.yaml
 	MaxConcurrentReconciles int `yaml:"-"`
@@ -68,6 +76,35 @@ type Finalizer struct {
 	Vars     map[string]interface{} `yaml:"vars"`
 }
 
+// KubernetesEvents - Kubernetes Events to emit on a CR for its runs.
+type KubernetesEvents struct {
+	FailedTasks  bool `yaml:"failedTasks"`
+	ChangedTasks bool `yaml:"changedTasks"`
+	Completion   bool `yaml:"completion"`
+}
+
+// Impersonate - ServiceAccount that the runs for a CR make requests as.
+// Namespace defaults to the namespace of the CR.
+type Impersonate struct {
+	ServiceAccount string `yaml:"serviceAccount"`
+	Namespace      string `yaml:"namespace"`
+}
+
+// Conversion - playbook that converts CRs between the served versions of a GVK
+// for a conversion webhook.
+type Conversion struct {
+	Playbook string                 `yaml:"playbook"`
+	Vars     map[string]interface{} `yaml:"vars"`
+}
+
+// Condition - custom condition of a CR that is derived from each run, from the
+// result of a Task, or from a Variable that the run sets with set_stats.
+type Condition struct {
+	Type     string `yaml:"type"`
+	Task     string `yaml:"task"`
+	Variable string `yaml:"variable"`
+}
+
 // Default values for optional fields on Watch
 var (
 	blacklistDefault                   = []schema.GroupVersionKind{}
@@ -133,6 +170,12 @@ type alias struct {
 	Blacklist                   []schema.GroupVersionKind `yaml:"blacklist,omitempty"`
 	Finalizer                   *Finalizer                `yaml:"finalizer"`
 	Selector                    tempLabelSelector         `yaml:"selector"`
+	TaskResults                 int                       `yaml:"taskResults"`
+	KubernetesEvents            KubernetesEvents          `yaml:"kubernetesEvents"`
+	Impersonate                 *Impersonate              `yaml:"impersonate"`
+	Versions                    []string                  `yaml:"versions"`
+	Conversion                  *Conversion               `yaml:"conversion"`
+	Conditions                  []Condition               `yaml:"conditions"`
 }
 
 // buildWatch will build Watch based on the values parsed from alias
@@ -178,6 +221,24 @@ func (w *Watch) setValuesFromAlias(tmp alias) error {
 	if err != nil {
 		return fmt.Errorf("invalid GVK: %s: %w", gvk, err)
 	}
+	if tmp.TaskResults < 0 {
+		return fmt.Errorf("invalid taskResults for GVK %s: must not be negative", gvk)
+	}
+	if tmp.TaskResults > 0 && !*tmp.ManageStatus {
+		return fmt.Errorf("invalid taskResults for GVK %s: task results are only recorded if manageStatus is true", gvk)
+	}
+	if tmp.Impersonate != nil && tmp.Impersonate.ServiceAccount == "" {
+		return fmt.Errorf("invalid impersonate for GVK %s: serviceAccount must be set", gvk)
+	}
+	if err := verifyVersions(gvk, tmp.Versions, tmp.Conversion); err != nil {
+		return fmt.Errorf("invalid versions for GVK %s: %w", gvk, err)
+	}
+	if len(tmp.Conditions) > 0 && !*tmp.ManageStatus {
+		return fmt.Errorf("invalid conditions for GVK %s: conditions are only set if manageStatus is true", gvk)
+	}
+	if err := verifyConditions(tmp.Conditions); err != nil {
+		return fmt.Errorf("invalid conditions for GVK %s: %w", gvk, err)
+	}
 
 	// Rewrite values to struct being unmarshalled
 	w.GroupVersionKind = gvk
@@ -195,6 +256,12 @@ func (w *Watch) setValuesFromAlias(tmp alias) error {
 	w.Finalizer = tmp.Finalizer
 	w.AnsibleVerbosity = getAnsibleVerbosity(gvk, ansibleVerbosityDefault)
 	w.Blacklist = tmp.Blacklist
+	w.TaskResults = tmp.TaskResults
+	w.KubernetesEvents = tmp.KubernetesEvents
+	w.Impersonate = tmp.Impersonate
+	w.Versions = tmp.Versions
+	w.Conversion = tmp.Conversion
+	w.Conditions = tmp.Conditions
 
 	wd, err := os.Getwd()
 	if err != nil {
@@ -221,6 +288,9 @@ func (w *Watch) addRolePlaybookPaths(rootDir string) {
 			}
 		}
 	}
+	if w.Conversion != nil && len(w.Conversion.Playbook) > 0 {
+		w.Conversion.Playbook = getFullPath(rootDir, w.Conversion.Playbook)
+	}
 	if w.Finalizer != nil && len(w.Finalizer.Role) > 0 {
 		possibleRolePaths := getPossibleRolePaths(rootDir, w.Finalizer.Role)
 		for _, possiblePath := range possibleRolePaths {
@@ -306,9 +376,29 @@ func (w *Watch) Validate() error {
 		}
 	}
 
+	if w.Conversion != nil {
+		err = verifyAnsiblePath(w.Conversion.Playbook, "")
+		if err != nil {
+			log.Error(err, fmt.Sprintf("Invalid conversion playbook for GVK: %v", w.GroupVersionKind.String()))
+			return err
+		}
+	}
+
 	return nil
 }
 
+// ServedGVKs returns the GVKs of all served versions of the watched GVK.
+func (w *Watch) ServedGVKs() []schema.GroupVersionKind {
+	if len(w.Versions) == 0 {
+		return []schema.GroupVersionKind{w.GroupVersionKind}
+	}
+	gvks := make([]schema.GroupVersionKind, len(w.Versions))
+	for i, version := range w.Versions {
+		gvks[i] = w.GroupVersionKind.GroupKind().WithVersion(version)
+	}
+	return gvks
+}
+
 // New - returns a Watch with sensible defaults.
 func New(gvk schema.GroupVersionKind, role, playbook string, vars map[string]interface{}, finalizer *Finalizer) *Watch {
 	return &Watch{
@@ -363,13 +453,14 @@ func Load(path string, maxReconciler, ansibleVerbosity int) ([]Watch, error) {
 
 	watchesMap := make(map[schema.GroupVersionKind]bool)
 	for _, watch := range watches {
-		// prevent dupes
-		if _, ok := watchesMap[watch.GroupVersionKind]; ok {
-			return nil, fmt.Errorf("duplicate GVK: %v", watch.GroupVersionKind.String())
+		// prevent dupes, also between the served versions of watches
+		for _, gvk := range watch.ServedGVKs() {
+			if _, ok := watchesMap[gvk]; ok {
+				return nil, fmt.Errorf("duplicate GVK: %v", gvk.String())
+			}
+			watchesMap[gvk] = true
 		}
 
-		watchesMap[watch.GroupVersionKind] = true
-
 		err = watch.Validate()
 		if err != nil {
 			log.Error(err, fmt.Sprintf("Watch with GVK %v failed validation", watch.GroupVersionKind.String()))
@@ -380,6 +471,55 @@ func Load(path string, maxReconciler, ansibleVerbosity int) ([]Watch, error) {
 	return watches, nil
 }
 
+// verifyVersions verifies that the served versions of a GVK include its
+// storage version, and that a conversion has a playbook and more than one
+// version to convert between.
+func verifyVersions(gvk schema.GroupVersionKind, versions []string, conversion *Conversion) error {
+	if len(versions) > 0 {
+		seen := map[string]bool{}
+		for _, version := range versions {
+			if version == "" {
+				return errors.New("versions must not be empty")
+			}
+			if seen[version] {
+				return fmt.Errorf("duplicate version %q", version)
+			}
+			seen[version] = true
+		}
+		if !seen[gvk.Version] {
+			return fmt.Errorf("versions must include the storage version %q", gvk.Version)
+		}
+	}
+	if conversion != nil {
+		if len(versions) < 2 {
+			return errors.New("conversion requires at least two versions")
+		}
+		if conversion.Playbook == "" {
+			return errors.New("conversion must have a playbook")
+		}
+	}
+	return nil
+}
+
+// verifyConditions verifies that each condition has a unique type that the
+// operator does not manage itself, and either a task or a variable.
+func verifyConditions(conditions []Condition) error {
+	types := map[string]bool{"Running": true, "Failure": true}
+	for _, c := range conditions {
+		if c.Type == "" {
+			return errors.New("condition must have a type")
+		}
+		if types[c.Type] {
+			return fmt.Errorf("duplicate or reserved condition type %q", c.Type)
+		}
+		types[c.Type] = true
+		if (c.Task == "") == (c.Variable == "") {
+			return fmt.Errorf("condition %q must have either a task or a variable", c.Type)
+		}
+	}
+	return nil
+}
+
 // verify that a given GroupVersionKind has a Version and Kind
 // A GVK without a group is valid. Certain scenarios may cause a GVK
 // without a group to fail in other ways later in the initialization
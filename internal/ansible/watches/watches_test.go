@@ -382,6 +382,57 @@ func TestLoad(t *testing.T) { //nolint:gocyclo
 			},
 			ManageStatus: true,
 		},
+		Watch{
+			GroupVersionKind: schema.GroupVersionKind{
+				Version: "v1alpha1",
+				Group:   "app.example.com",
+				Kind:    "TaskResultsTest",
+			},
+			Playbook:     validTemplate.ValidPlaybook,
+			ManageStatus: true,
+			TaskResults:  5,
+			KubernetesEvents: KubernetesEvents{
+				FailedTasks: true,
+				Completion:  true,
+			},
+		},
+		Watch{
+			GroupVersionKind: schema.GroupVersionKind{
+				Version: "v1alpha1",
+				Group:   "app.example.com",
+				Kind:    "ImpersonateTest",
+			},
+			Playbook:     validTemplate.ValidPlaybook,
+			ManageStatus: true,
+			Impersonate:  &Impersonate{ServiceAccount: "memcached-runner"},
+		},
+		Watch{
+			GroupVersionKind: schema.GroupVersionKind{
+				Version: "v1",
+				Group:   "app.example.com",
+				Kind:    "MultiVersionTest",
+			},
+			Playbook:     validTemplate.ValidPlaybook,
+			ManageStatus: true,
+			Versions:     []string{"v1alpha1", "v1"},
+			Conversion: &Conversion{
+				Playbook: validTemplate.ValidPlaybook,
+				Vars:     map[string]interface{}{"sentinel": "converting"},
+			},
+		},
+		Watch{
+			GroupVersionKind: schema.GroupVersionKind{
+				Version: "v1alpha1",
+				Group:   "app.example.com",
+				Kind:    "ConditionsTest",
+			},
+			Playbook:     validTemplate.ValidPlaybook,
+			ManageStatus: true,
+			Conditions: []Condition{
+				{Type: "Deployed", Task: "deploy memcached"},
+				{Type: "Ready", Variable: "ready"},
+			},
+		},
 	}
 
 	testCases := []struct {
@@ -399,6 +450,11 @@ func TestLoad(t *testing.T) { //nolint:gocyclo
 			path:        "testdata/duplicate_gvk.yaml",
 			shouldError: true,
 		},
+		{
+			name:        "error duplicate served version",
+			path:        "testdata/duplicate_served_version.yaml",
+			shouldError: true,
+		},
 		{
 			name:        "error no file",
 			path:        "testdata/please_don't_create_me_gvk.yaml",
@@ -563,6 +619,31 @@ func TestLoad(t *testing.T) { //nolint:gocyclo
 					}
 				}
 
+				if gotWatch.KubernetesEvents != expectedWatch.KubernetesEvents {
+					t.Fatalf("The GVK: %v unexpected kubernetes events: %+v expected kubernetes events: %+v", gvk,
+						gotWatch.KubernetesEvents, expectedWatch.KubernetesEvents)
+				}
+				if !reflect.DeepEqual(gotWatch.Versions, expectedWatch.Versions) {
+					t.Fatalf("The GVK: %v unexpected versions: %v expected versions: %v", gvk,
+						gotWatch.Versions, expectedWatch.Versions)
+				}
+				if !reflect.DeepEqual(gotWatch.Conversion, expectedWatch.Conversion) {
+					t.Fatalf("The GVK: %v unexpected conversion: %+v expected conversion: %+v", gvk,
+						gotWatch.Conversion, expectedWatch.Conversion)
+				}
+				if !reflect.DeepEqual(gotWatch.Conditions, expectedWatch.Conditions) {
+					t.Fatalf("The GVK: %v unexpected conditions: %+v expected conditions: %+v", gvk,
+						gotWatch.Conditions, expectedWatch.Conditions)
+				}
+				if !reflect.DeepEqual(gotWatch.Impersonate, expectedWatch.Impersonate) {
+					t.Fatalf("The GVK: %v unexpected impersonate: %+v expected impersonate: %+v", gvk,
+						gotWatch.Impersonate, expectedWatch.Impersonate)
+				}
+				if gotWatch.TaskResults != expectedWatch.TaskResults {
+					t.Fatalf("The GVK: %v unexpected task results: %v expected task results: %v", gvk,
+						gotWatch.TaskResults, expectedWatch.TaskResults)
+				}
+
 				if !reflect.DeepEqual(gotWatch.Selector, expectedWatch.Selector) {
 					t.Fatalf("Incorrect selector GVK %s:\n\tgot %s\n\texpected %s", gvk,
 						gotWatch.Selector, expectedWatch.Selector)
@@ -892,3 +973,206 @@ func TestGetPossibleRolePaths(t *testing.T) {
 		})
 	}
 }
+
+func TestTaskResults(t *testing.T) {
+	manageStatus, noManageStatus := true, false
+	testCases := []struct {
+		name        string
+		alias       alias
+		expected    int
+		shouldError bool
+	}{
+		{
+			name:     "disabled by default",
+			alias:    alias{},
+			expected: 0,
+		},
+		{
+			name:     "enabled",
+			alias:    alias{TaskResults: 3},
+			expected: 3,
+		},
+		{
+			name:        "negative",
+			alias:       alias{TaskResults: -1},
+			shouldError: true,
+		},
+		{
+			name:        "status not managed",
+			alias:       alias{TaskResults: 3, ManageStatus: &noManageStatus},
+			shouldError: true,
+		},
+		{
+			name:     "status managed",
+			alias:    alias{TaskResults: 3, ManageStatus: &manageStatus},
+			expected: 3,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.alias.Version, tc.alias.Kind = "v1alpha1", "Test"
+			w := Watch{}
+			err := w.setValuesFromAlias(tc.alias)
+			if tc.shouldError {
+				if err == nil {
+					t.Fatal("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if w.TaskResults != tc.expected {
+				t.Fatalf("Unexpected task results: %v expected: %v", w.TaskResults, tc.expected)
+			}
+		})
+	}
+}
+
+func TestImpersonate(t *testing.T) {
+	w := Watch{}
+	err := w.setValuesFromAlias(alias{Version: "v1alpha1", Kind: "Test", Impersonate: &Impersonate{Namespace: "default"}})
+	if err == nil {
+		t.Fatal("Expected error for impersonate without serviceAccount")
+	}
+	impersonate := &Impersonate{ServiceAccount: "runner", Namespace: "default"}
+	if err := w.setValuesFromAlias(alias{Version: "v1alpha1", Kind: "Test", Impersonate: impersonate}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(w.Impersonate, impersonate) {
+		t.Fatalf("Unexpected impersonate: %+v expected: %+v", w.Impersonate, impersonate)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	conversion := &Conversion{Playbook: "convert.yml"}
+	testCases := []struct {
+		name        string
+		alias       alias
+		expected    []schema.GroupVersionKind
+		shouldError bool
+	}{
+		{
+			name:     "storage version only",
+			alias:    alias{Version: "v1"},
+			expected: []schema.GroupVersionKind{{Version: "v1", Kind: "Test"}},
+		},
+		{
+			name:  "served versions",
+			alias: alias{Version: "v1", Versions: []string{"v1alpha1", "v1"}, Conversion: conversion},
+			expected: []schema.GroupVersionKind{
+				{Version: "v1alpha1", Kind: "Test"},
+				{Version: "v1", Kind: "Test"},
+			},
+		},
+		{
+			name:        "storage version not served",
+			alias:       alias{Version: "v1", Versions: []string{"v1alpha1", "v1beta1"}},
+			shouldError: true,
+		},
+		{
+			name:        "duplicate version",
+			alias:       alias{Version: "v1", Versions: []string{"v1", "v1"}},
+			shouldError: true,
+		},
+		{
+			name:        "conversion of a single version",
+			alias:       alias{Version: "v1", Conversion: conversion},
+			shouldError: true,
+		},
+		{
+			name:        "conversion without playbook",
+			alias:       alias{Version: "v1", Versions: []string{"v1alpha1", "v1"}, Conversion: &Conversion{}},
+			shouldError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.alias.Kind = "Test"
+			w := Watch{}
+			err := w.setValuesFromAlias(tc.alias)
+			if tc.shouldError {
+				if err == nil {
+					t.Fatal("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if gvks := w.ServedGVKs(); !reflect.DeepEqual(gvks, tc.expected) {
+				t.Fatalf("Unexpected served GVKs: %v expected: %v", gvks, tc.expected)
+			}
+		})
+	}
+}
+
+func TestConditions(t *testing.T) {
+	manageStatus := false
+	testCases := []struct {
+		name        string
+		alias       alias
+		shouldError bool
+	}{
+		{
+			name: "task and variable conditions",
+			alias: alias{Conditions: []Condition{
+				{Type: "Deployed", Task: "deploy"},
+				{Type: "Ready", Variable: "ready"},
+			}},
+		},
+		{
+			name:        "without manageStatus",
+			alias:       alias{ManageStatus: &manageStatus, Conditions: []Condition{{Type: "Ready", Variable: "ready"}}},
+			shouldError: true,
+		},
+		{
+			name:        "without type",
+			alias:       alias{Conditions: []Condition{{Variable: "ready"}}},
+			shouldError: true,
+		},
+		{
+			name: "duplicate type",
+			alias: alias{Conditions: []Condition{
+				{Type: "Ready", Task: "deploy"},
+				{Type: "Ready", Variable: "ready"},
+			}},
+			shouldError: true,
+		},
+		{
+			name:        "reserved type",
+			alias:       alias{Conditions: []Condition{{Type: "Running", Variable: "ready"}}},
+			shouldError: true,
+		},
+		{
+			name:        "task and variable",
+			alias:       alias{Conditions: []Condition{{Type: "Ready", Task: "deploy", Variable: "ready"}}},
+			shouldError: true,
+		},
+		{
+			name:        "neither task nor variable",
+			alias:       alias{Conditions: []Condition{{Type: "Ready"}}},
+			shouldError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.alias.Version = "v1alpha1"
+			tc.alias.Kind = "Test"
+			w := Watch{}
+			err := w.setValuesFromAlias(tc.alias)
+			if tc.shouldError {
+				if err == nil {
+					t.Fatal("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(w.Conditions, tc.alias.Conditions) {
+				t.Fatalf("Unexpected conditions: %+v expected: %+v", w.Conditions, tc.alias.Conditions)
+			}
+		})
+	}
+}
@@ -15,6 +15,7 @@
 package run
 
 import (
+	"crypto/tls"
 	"errors"
 	"flag"
 	"fmt"
@@ -36,11 +37,16 @@ import (
 	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	"github.com/operator-framework/operator-sdk/internal/ansible/controller"
+	ansiblestatus "github.com/operator-framework/operator-sdk/internal/ansible/controller/status"
+	"github.com/operator-framework/operator-sdk/internal/ansible/events"
 	"github.com/operator-framework/operator-sdk/internal/ansible/flags"
 	"github.com/operator-framework/operator-sdk/internal/ansible/metrics"
 	"github.com/operator-framework/operator-sdk/internal/ansible/proxy"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/auth"
 	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/controllermap"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/middleware"
 	"github.com/operator-framework/operator-sdk/internal/ansible/runner"
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/pool"
 	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
 	"github.com/operator-framework/operator-sdk/internal/clientbuilder"
 	"github.com/operator-framework/operator-sdk/internal/util/k8sutil"
@@ -49,6 +55,10 @@ import (
 
 var log = logf.Log.WithName("cmd")
 
+// proxyCAFile is where the CA certificate of the proxy is written for runs,
+// when the proxy is served over TLS.
+const proxyCAFile = "/tmp/ansible-operator/proxy-ca.crt"
+
 func printVersion() {
 	log.Info("Version",
 		"Go Version", runtime.Version(),
@@ -69,6 +79,10 @@ func NewCmd() *cobra.Command {
 		Short: "Run the operator",
 		Run: func(cmd *cobra.Command, _ []string) {
 			logf.SetLogger(zapf.New(zapf.UseFlagOptions(opts)))
+			if f.Replay != "" {
+				replay(f)
+				return
+			}
 			run(cmd, f)
 		},
 	}
@@ -186,8 +200,59 @@ func run(cmd *cobra.Command, f *flags.Flags) {
 		log.Error(err, "Failed to load watches.")
 		os.Exit(1)
 	}
+	var runnerOpts []runner.Option
+	if f.AnsibleWorkerPoolSize > 0 {
+		workerPool, err := pool.New(pool.Options{
+			Size:       f.AnsibleWorkerPoolSize,
+			JobTimeout: f.AnsibleWorkerTimeout,
+		})
+		if err != nil {
+			log.Error(err, "Failed to create ansible worker pool")
+			os.Exit(1)
+		}
+		if err := mgr.Add(workerPool); err != nil {
+			log.Error(err, "Failed to add ansible worker pool to manager")
+			os.Exit(1)
+		}
+		runnerOpts = append(runnerOpts, runner.WithWorkerPool(workerPool))
+	}
+	var (
+		proxyTokens *auth.Tokens
+		proxyTLS    *tls.Config
+	)
+	if f.SecureProxy {
+		proxyTokens = auth.NewTokens()
+		if proxyTLS, err = auth.GenerateTLSConfig(proxyCAFile); err != nil {
+			log.Error(err, "Failed to generate proxy certificates")
+			os.Exit(1)
+		}
+	}
 	for _, w := range watches {
-		runner, err := runner.New(w, f.AnsibleArgs)
+		var impersonateServiceAccount, impersonateNamespace string
+		if w.Impersonate != nil {
+			if !f.SecureProxy {
+				log.Error(fmt.Errorf("impersonation for GVK %v requires --secure-proxy", w.GroupVersionKind), "")
+				os.Exit(1)
+			}
+			impersonateServiceAccount, impersonateNamespace = w.Impersonate.ServiceAccount, w.Impersonate.Namespace
+		}
+		conditions := make([]ansiblestatus.ConditionRule, 0, len(w.Conditions))
+		for _, c := range w.Conditions {
+			conditions = append(conditions, ansiblestatus.ConditionRule{
+				Type:     ansiblestatus.ConditionType(c.Type),
+				Task:     c.Task,
+				Variable: c.Variable,
+			})
+		}
+		var converter runner.Converter
+		if w.Conversion != nil {
+			if converter, err = runner.NewConverter(w, f.AnsibleArgs, runnerOpts...); err != nil {
+				log.Error(err, "Failed to create converter")
+				os.Exit(1)
+			}
+		}
+
+		runner, err := runner.New(w, f.AnsibleArgs, runnerOpts...)
 		if err != nil {
 			log.Error(err, "Failed to create runner")
 			os.Exit(1)
@@ -201,18 +266,35 @@ func run(cmd *cobra.Command, f *flags.Flags) {
 			MaxConcurrentReconciles: w.MaxConcurrentReconciles,
 			ReconcilePeriod:         w.ReconcilePeriod,
 			Selector:                w.Selector,
+			TaskResults:             w.TaskResults,
+			KubernetesEvents: events.KubernetesEventOptions{
+				FailedTasks:  w.KubernetesEvents.FailedTasks,
+				ChangedTasks: w.KubernetesEvents.ChangedTasks,
+				Completion:   w.KubernetesEvents.Completion,
+			},
+			ProxyTokens:               proxyTokens,
+			ProxyCAFile:               proxyCAFile,
+			ImpersonateServiceAccount: impersonateServiceAccount,
+			ImpersonateNamespace:      impersonateNamespace,
+			Converter:                 converter,
+			Conditions:                conditions,
 		})
 		if ctr == nil {
 			log.Error(fmt.Errorf("failed to add controller for GVK %v", w.GroupVersionKind.String()), "")
 			os.Exit(1)
 		}
 
-		cMap.Store(w.GroupVersionKind, &controllermap.Contents{Controller: *ctr, //nolint:staticcheck
+		contents := &controllermap.Contents{Controller: *ctr, //nolint:staticcheck
 			WatchDependentResources:     w.WatchDependentResources,
 			WatchClusterScopedResources: w.WatchClusterScopedResources,
 			OwnerWatchMap:               controllermap.NewWatchMap(),
 			AnnotationWatchMap:          controllermap.NewWatchMap(),
-		}, w.Blacklist)
+		}
+		// The one controller of a watch reconciles CRs of all served versions,
+		// whichever version their dependent resources' owners have.
+		for _, gvk := range w.ServedGVKs() {
+			cMap.Store(gvk, contents, w.Blacklist)
+		}
 	}
 
 	// TODO(2.0.0): remove
@@ -233,6 +315,9 @@ func run(cmd *cobra.Command, f *flags.Flags) {
 		ControllerMap:     cMap,
 		OwnerInjection:    f.InjectOwnerRef,
 		WatchedNamespaces: strings.Split(namespace, ","),
+		Tokens:            proxyTokens,
+		TLSConfig:         proxyTLS,
+		Handler:           proxyHandlerChain(f),
 	})
 	if err != nil {
 		log.Error(err, "Error starting proxy.")
@@ -274,6 +359,20 @@ func exitIfUnsupported(options manager.Options) {
 	}
 }
 
+// proxyHandlerChain returns the handler chain of the middleware that the
+// manager config file configures for the proxy, if any.
+func proxyHandlerChain(f *flags.Flags) proxy.HandlerChain {
+	if f.ManagerConfigPath == "" {
+		return nil
+	}
+	config, err := middleware.LoadConfig(f.ManagerConfigPath)
+	if err != nil {
+		log.Error(err, "Failed to load the proxy middleware config")
+		os.Exit(1)
+	}
+	return config.Chain()
+}
+
 // getAnsibleDebugLog return the value from the ANSIBLE_DEBUG_LOGS it order to
 // print the full Ansible logs
 func getAnsibleDebugLog() bool {
@@ -0,0 +1,216 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/controller"
+	"github.com/operator-framework/operator-sdk/internal/ansible/events"
+	"github.com/operator-framework/operator-sdk/internal/ansible/flags"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/controllermap"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner"
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+)
+
+// replayVerbosity is the Ansible verbosity of replays.
+const replayVerbosity = 6
+
+// replay runs the role or playbook of a single CR once, without running the
+// operator, and prints the events of the run as a tree. The input directory
+// and artifacts of the run are kept for inspection. It exits with a non-zero
+// code if the run failed. The status of the CR is not updated.
+func replay(f *flags.Flags) {
+	namespace, name, err := parseReplayTarget(f.Replay)
+	if err != nil {
+		log.Error(err, "Invalid --replay")
+		os.Exit(1)
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		log.Error(err, "Failed to get config.")
+		os.Exit(1)
+	}
+	if err := setAnsibleEnvVars(f); err != nil {
+		log.Error(err, "Failed to set environment variable.")
+		os.Exit(1)
+	}
+
+	ws, err := watches.Load(f.WatchesFile, f.MaxConcurrentReconciles, f.AnsibleVerbosity)
+	if err != nil {
+		log.Error(err, "Failed to load watches.")
+		os.Exit(1)
+	}
+	w, err := replayWatch(ws, f.ReplayKind)
+	if err != nil {
+		log.Error(err, "Failed to find the watch to replay")
+		os.Exit(1)
+	}
+	if w.Impersonate != nil {
+		log.Info("Replays do not impersonate the ServiceAccount of the watch", "serviceAccount",
+			w.Impersonate.ServiceAccount)
+	}
+
+	// The manager is never started. It provides the REST mapper of the proxy
+	// and the controller that the proxy adds watches for dependent resources to,
+	// which never reconciles.
+	mgr, err := manager.New(cfg, manager.Options{MetricsBindAddress: "0"})
+	if err != nil {
+		log.Error(err, "Failed to create a new manager.")
+		os.Exit(1)
+	}
+
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(w.GroupVersionKind)
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	if err := mgr.GetAPIReader().Get(context.TODO(), key, u); err != nil {
+		log.Error(err, "Failed to get the custom resource to replay", "GVK", w.GroupVersionKind, "key", key)
+		os.Exit(1)
+	}
+	annotations := u.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[runner.AnsibleVerbosityAnnotation] = strconv.Itoa(replayVerbosity)
+	u.SetAnnotations(annotations)
+
+	r, err := runner.New(w, f.AnsibleArgs)
+	if err != nil {
+		log.Error(err, "Failed to create runner")
+		os.Exit(1)
+	}
+	ctr := controller.Add(mgr, controller.Options{
+		GVK:          w.GroupVersionKind,
+		Runner:       r,
+		ManageStatus: w.ManageStatus,
+		LoggingLevel: events.Nothing,
+	})
+	if ctr == nil {
+		log.Error(fmt.Errorf("failed to add controller for GVK %v", w.GroupVersionKind.String()), "")
+		os.Exit(1)
+	}
+	cMap := controllermap.NewControllerMap()
+	contents := &controllermap.Contents{Controller: *ctr, //nolint:staticcheck
+		WatchDependentResources:     w.WatchDependentResources,
+		WatchClusterScopedResources: w.WatchClusterScopedResources,
+		OwnerWatchMap:               controllermap.NewWatchMap(),
+		AnnotationWatchMap:          controllermap.NewWatchMap(),
+	}
+	for _, gvk := range w.ServedGVKs() {
+		cMap.Store(gvk, contents, w.Blacklist)
+	}
+
+	done := make(chan error, 1)
+	err = proxy.Run(done, proxy.Options{
+		Address:           "localhost",
+		Port:              8888,
+		KubeConfig:        cfg,
+		RESTMapper:        mgr.GetRESTMapper(),
+		ControllerMap:     cMap,
+		OwnerInjection:    f.InjectOwnerRef,
+		WatchedNamespaces: []string{metav1.NamespaceAll},
+		DisableCache:      true,
+		LogRequests:       true,
+		Handler:           proxyHandlerChain(f),
+	})
+	if err != nil {
+		log.Error(err, "Error starting proxy.")
+		os.Exit(1)
+	}
+
+	ownerRef := metav1.OwnerReference{
+		APIVersion: u.GetAPIVersion(),
+		Kind:       u.GetKind(),
+		Name:       u.GetName(),
+		UID:        u.GetUID(),
+	}
+	kc, err := kubeconfig.Create(ownerRef, "http://localhost:8888", u.GetNamespace())
+	if err != nil {
+		log.Error(err, "Unable to generate kubeconfig")
+		os.Exit(1)
+	}
+
+	ident := strconv.Itoa(rand.Int())
+	result, err := r.Run(ident, u, kc.Name())
+	if err != nil {
+		_ = os.Remove(kc.Name())
+		log.Error(err, "Failed to run the replay")
+		os.Exit(1)
+	}
+	tree := events.NewTreeEventHandler(os.Stdout)
+	failed := false
+	for e := range result.Events() {
+		tree.Handle(ident, u, e)
+		if e.Event == eventapi.EventRunnerOnFailed && !e.IgnoreError() && !e.Rescued() {
+			failed = true
+		}
+	}
+	if err := os.Remove(kc.Name()); err != nil {
+		log.Error(err, "Failed to remove generated kubeconfig file")
+	}
+
+	inputDir := runner.InputDirPath(w.GroupVersionKind, u)
+	fmt.Printf("\nInput directory: %s\nArtifacts: %s\n", inputDir, filepath.Join(inputDir, "artifacts", ident))
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// parseReplayTarget parses the <namespace>/<name> of a namespaced CR, or the
+// <name> of a cluster-scoped CR.
+func parseReplayTarget(target string) (namespace, name string, err error) {
+	parts := strings.Split(target, "/")
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		return "", parts[0], nil
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return parts[0], parts[1], nil
+	}
+	return "", "", fmt.Errorf("%q is not <namespace>/<name> or <name>", target)
+}
+
+// replayWatch returns the watch of kind, which may be empty if there is only
+// one watch.
+func replayWatch(ws []watches.Watch, kind string) (watches.Watch, error) {
+	if kind == "" {
+		if len(ws) != 1 {
+			return watches.Watch{}, errors.New("--replay-kind is required if there are several watches")
+		}
+		return ws[0], nil
+	}
+	for _, w := range ws {
+		if w.GroupVersionKind.Kind == kind {
+			return w, nil
+		}
+	}
+	return watches.Watch{}, fmt.Errorf("no watch of kind %q", kind)
+}
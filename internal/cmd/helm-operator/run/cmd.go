@@ -15,12 +15,14 @@
 package run
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -35,9 +37,11 @@ import (
 	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	"github.com/operator-framework/operator-sdk/internal/clientbuilder"
+	"github.com/operator-framework/operator-sdk/internal/helm/chartsource"
 	"github.com/operator-framework/operator-sdk/internal/helm/controller"
 	"github.com/operator-framework/operator-sdk/internal/helm/flags"
 	"github.com/operator-framework/operator-sdk/internal/helm/metrics"
+	"github.com/operator-framework/operator-sdk/internal/helm/postrender"
 	"github.com/operator-framework/operator-sdk/internal/helm/release"
 	"github.com/operator-framework/operator-sdk/internal/helm/watches"
 	"github.com/operator-framework/operator-sdk/internal/util/k8sutil"
@@ -46,6 +50,10 @@ import (
 
 var log = logf.Log.WithName("cmd")
 
+// defaultReleaseTimeout is the timeout of waiting release actions when a
+// watch does not set one. It matches the default of the helm CLI.
+const defaultReleaseTimeout = 5 * time.Minute
+
 func printVersion() {
 	log.Info("Version",
 		"Go Version", runtime.Version(),
@@ -174,17 +182,94 @@ func run(cmd *cobra.Command, f *flags.Flags) {
 		log.Error(err, "Failed to create new manager factories.")
 		os.Exit(1)
 	}
+	chartCache := chartsource.NewCache(f.ChartCacheDir)
 	for _, w := range ws {
-		// Register the controller with the factory.
-		err := controller.Add(mgr, controller.WatchOptions{
+		storageOpts := release.StorageOptions{
+			Driver:              f.ReleaseStorageDriver,
+			Namespace:           f.ReleaseStorageNamespace,
+			SQLConnectionString: f.ReleaseStorageSQLConnectionString,
+		}
+		if w.ReleaseStorage != nil {
+			if w.ReleaseStorage.Driver != "" {
+				storageOpts.Driver = w.ReleaseStorage.Driver
+			}
+			if w.ReleaseStorage.Namespace != "" {
+				storageOpts.Namespace = w.ReleaseStorage.Namespace
+			}
+		}
+		if err := storageOpts.Validate(); err != nil {
+			log.Error(err, "Invalid release storage", "apiVersion", w.GroupVersion(), "kind", w.Kind)
+			os.Exit(1)
+		}
+		factoryOpts := []release.ManagerFactoryOption{release.WithStorageOptions(storageOpts)}
+		if w.Drift != nil {
+			factoryOpts = append(factoryOpts, release.WithDriftPolicy(driftPolicy(*w.Drift)))
+		}
+		postRenderer, err := postrender.New(w.PostRenderers)
+		if err != nil {
+			log.Error(err, "Invalid post-renderers", "apiVersion", w.GroupVersion(), "kind", w.Kind)
+			os.Exit(1)
+		}
+		if postRenderer != nil {
+			digest, err := postrender.Digest(w.PostRenderers)
+			if err != nil {
+				log.Error(err, "Invalid post-renderers", "apiVersion", w.GroupVersion(), "kind", w.Kind)
+				os.Exit(1)
+			}
+			factoryOpts = append(factoryOpts, release.WithPostRenderer(postRenderer, digest))
+		}
+		releaseNames := release.ReleaseNameOptions{Hash: w.HashReleaseNames}
+		if w.ReleaseNameTemplate != "" {
+			if releaseNames.Template, err = watches.ParseReleaseNameTemplate(w.ReleaseNameTemplate); err != nil {
+				log.Error(err, "Invalid release name template", "apiVersion", w.GroupVersion(), "kind", w.Kind)
+				os.Exit(1)
+			}
+		}
+		factoryOpts = append(factoryOpts, release.WithReleaseNameOptions(releaseNames))
+
+		var (
+			factory    release.ManagerFactory
+			components []controller.Component
+		)
+		if len(w.Charts) == 0 {
+			if factory, err = newManagerFactory(mgr, chartCache, w.ChartDir, w.ChartSource, factoryOpts...); err != nil {
+				log.Error(err, "Failed to fetch chart", "apiVersion", w.GroupVersion(), "kind", w.Kind)
+				os.Exit(1)
+			}
+		}
+		for _, c := range w.Charts {
+			componentNames := releaseNames
+			componentNames.Suffix = c.ReleaseNameSuffix
+			componentOpts := append(append([]release.ManagerFactoryOption{}, factoryOpts...),
+				release.WithReleaseNameOptions(componentNames),
+				release.WithComponent(release.ComponentOptions{Name: c.Name, ValuesPath: valuesPath(c.ValuesPath)}))
+			componentFactory, err := newManagerFactory(mgr, chartCache, c.ChartDir, c.ChartSource, componentOpts...)
+			if err != nil {
+				log.Error(err, "Failed to fetch chart", "apiVersion", w.GroupVersion(), "kind", w.Kind, "chart", c.Name)
+				os.Exit(1)
+			}
+			components = append(components, controller.Component{Name: c.Name, ManagerFactory: componentFactory})
+		}
+
+		watchOpts := controller.WatchOptions{
 			Namespace:               namespace,
 			GVK:                     w.GroupVersionKind,
-			ManagerFactory:          release.NewManagerFactory(mgr, w.ChartDir),
+			ManagerFactory:          factory,
 			ReconcilePeriod:         f.ReconcilePeriod,
 			WatchDependentResources: *w.WatchDependentResources,
 			OverrideValues:          w.OverrideValues,
 			MaxConcurrentReconciles: f.MaxConcurrentReconciles,
-		})
+			RollbackOnFailure:       w.RollbackOnFailure,
+			Selector:                w.Selector,
+			NamespaceSelector:       w.NamespaceSelector,
+			StatusMappings:          w.StatusMappings,
+			ValidatingWebhook:       w.ValidatingWebhook,
+			Components:              components,
+		}
+		setReleaseOptions(&watchOpts, w)
+
+		// Register the controller with the factory.
+		err = controller.Add(mgr, watchOpts)
 		if err != nil {
 			log.Error(err, "Failed to add manager factory to controller.")
 			os.Exit(1)
@@ -218,3 +303,80 @@ func exitIfUnsupported(options manager.Options) {
 		os.Exit(1)
 	}
 }
+
+// setReleaseOptions sets the release action options configured by w on opts.
+func setReleaseOptions(opts *controller.WatchOptions, w watches.Watch) {
+	// Without a wait, a zero timeout leaves hooks without a deadline, as
+	// before these options existed.
+	var timeout time.Duration
+	if w.Timeout != nil {
+		timeout = w.Timeout.Duration
+	} else if w.Wait || w.Atomic {
+		timeout = defaultReleaseTimeout
+	}
+
+	opts.InstallOptions = []release.InstallOption{
+		release.WaitInstall(w.Wait),
+		release.TimeoutInstall(timeout),
+		release.AtomicInstall(w.Atomic),
+		release.DisableHooksInstall(w.DisableHooks),
+		release.SkipCRDsInstall(w.SkipCRDs),
+	}
+	opts.UpgradeOptions = []release.UpgradeOption{
+		release.WaitUpgrade(w.Wait),
+		release.TimeoutUpgrade(timeout),
+		release.AtomicUpgrade(w.Atomic),
+		release.DisableHooksUpgrade(w.DisableHooks),
+		release.SkipCRDsUpgrade(w.SkipCRDs),
+	}
+	opts.UninstallOptions = []release.UninstallOption{
+		release.TimeoutUninstall(timeout),
+		release.DisableHooksUninstall(w.DisableHooks),
+	}
+	opts.RollbackOptions = []release.RollbackOption{
+		release.WaitRollback(w.Wait || w.Atomic),
+		release.TimeoutRollback(timeout),
+		release.DisableHooksRollback(w.DisableHooks),
+	}
+	if w.MaxHistory > 0 {
+		opts.UpgradeOptions = append(opts.UpgradeOptions, release.MaxHistoryUpgrade(w.MaxHistory))
+		opts.RollbackOptions = append(opts.RollbackOptions, release.MaxHistoryRollback(w.MaxHistory))
+	}
+}
+
+// newManagerFactory returns a manager factory for the chart at chartDir, or
+// for the chart fetched from src if it is set.
+func newManagerFactory(mgr manager.Manager, chartCache *chartsource.Cache, chartDir string, src *watches.ChartSource,
+	opts ...release.ManagerFactoryOption) (release.ManagerFactory, error) {
+	if src == nil {
+		return release.NewManagerFactory(mgr, chartDir, opts...), nil
+	}
+	// Fetch the chart up front so that an unreachable or mismatched chart
+	// source fails fast.
+	if _, err := chartCache.Resolve(context.TODO(), *src); err != nil {
+		return nil, err
+	}
+	return release.NewManagerFactoryForChartSource(mgr, chartCache, *src, opts...), nil
+}
+
+// valuesPath splits the dot-separated values path of a composed chart.
+func valuesPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// driftPolicy converts a watch's drift policy to a release drift policy.
+func driftPolicy(p watches.DriftPolicy) release.DriftPolicy {
+	policy := release.DriftPolicy{ReportOnly: p.Mode == watches.DriftModeReport}
+	for _, rule := range p.Ignore {
+		policy.Ignore = append(policy.Ignore, release.DriftIgnoreRule{
+			Group: rule.Group,
+			Kind:  rule.Kind,
+			Name:  rule.Name,
+			Paths: rule.Paths,
+		})
+	}
+	return policy
+}
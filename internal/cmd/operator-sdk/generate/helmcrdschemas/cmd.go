@@ -0,0 +1,87 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmcrdschemas
+
+import (
+	"fmt"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/operator-framework/operator-sdk/internal/util/projutil"
+)
+
+const longHelp = `
+Running 'generate helm-crd-schemas' regenerates the OpenAPI v3 schemas of the specs of a Helm-based
+operator's CRDs from the charts in its watches file. Run it after updating a chart.
+
+A spec schema is converted from the chart's values.schema.json, or inferred from the types of
+the chart's values.yaml if the chart has no values schema. Only the spec schema of each CRD in
+'config/crd/bases' is rewritten; other parts of the CRD are left as is.
+`
+
+const examples = `
+  # Update the memcached chart, then regenerate CRD spec schemas.
+  $ operator-sdk generate helm-crd-schemas
+  Generated spec schema of cache.example.com/v1alpha1, Kind=Memcached in config/crd/bases/cache.example.com_memcacheds.yaml
+`
+
+type crdSchemasCmd struct {
+	watchesFile string
+	crdsDir     string
+	quiet       bool
+}
+
+// NewCmd returns the 'helm-crd-schemas' command.
+func NewCmd() *cobra.Command {
+	c := &crdSchemasCmd{}
+	cmd := &cobra.Command{
+		Use:     "helm-crd-schemas",
+		Short:   "Generates CRD spec schemas from the values of a Helm-based operator's charts",
+		Long:    longHelp,
+		Example: examples,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("command %s doesn't accept any arguments", cmd.CommandPath())
+			}
+
+			cfg, err := projutil.ReadConfig()
+			if err != nil {
+				return fmt.Errorf("error reading configuration: %v", err)
+			}
+			if projutil.PluginChainToOperatorType(cfg.GetPluginChain()) != projutil.OperatorTypeHelm {
+				return fmt.Errorf("command %s only supports Helm-based operators", cmd.CommandPath())
+			}
+
+			if err := c.run(); err != nil {
+				log.Fatalf("Error generating CRD spec schemas: %v", err)
+			}
+			return nil
+		},
+	}
+
+	c.addFlagsTo(cmd.Flags())
+
+	return cmd
+}
+
+func (c *crdSchemasCmd) addFlagsTo(fs *pflag.FlagSet) {
+	fs.StringVar(&c.watchesFile, "watches-file", "watches.yaml", "Path to the watches file")
+	fs.StringVar(&c.crdsDir, "crds-dir", filepath.Join("config", "crd", "bases"),
+		"Directory containing the CRD manifests to update")
+	fs.BoolVarP(&c.quiet, "quiet", "q", false, "Run in quiet mode")
+}
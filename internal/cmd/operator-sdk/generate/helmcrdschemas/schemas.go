@@ -0,0 +1,227 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmcrdschemas
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	yamlv3 "gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	"github.com/operator-framework/operator-sdk/internal/helm/watches"
+	"github.com/operator-framework/operator-sdk/internal/plugins/helm/v1/chartutil"
+)
+
+// run regenerates the spec schema of the CRD of each watch with a local chart.
+func (c crdSchemasCmd) run() error {
+	ws, err := watches.Load(c.watchesFile)
+	if err != nil {
+		return fmt.Errorf("error loading watches file: %v", err)
+	}
+	crdFiles, err := filepath.Glob(filepath.Join(c.crdsDir, "*.yaml"))
+	if err != nil {
+		return err
+	}
+
+	for _, w := range ws {
+		if w.ChartSource != nil {
+			log.Warnf("Skipping %s: charts pulled from a chart source are not supported", w.GroupVersionKind)
+			continue
+		}
+		if len(w.Charts) != 0 {
+			log.Warnf("Skipping %s: watches composed of several charts are not supported", w.GroupVersionKind)
+			continue
+		}
+		chrt, err := loader.Load(w.ChartDir)
+		if err != nil {
+			return fmt.Errorf("error loading chart %s: %v", w.ChartDir, err)
+		}
+		specSchema, err := chartutil.SpecSchema(chrt)
+		if err != nil {
+			return err
+		}
+
+		updated := false
+		for _, path := range crdFiles {
+			if updated, err = updateCRDFile(path, w.GroupVersionKind, specSchema); err != nil {
+				return fmt.Errorf("error updating %s: %v", path, err)
+			}
+			if updated {
+				if !c.quiet {
+					fmt.Printf("Generated spec schema of %s in %s\n", w.GroupVersionKind, path)
+				}
+				break
+			}
+		}
+		if !updated {
+			log.Warnf("No CRD found for %s in %s", w.GroupVersionKind, c.crdsDir)
+		}
+	}
+	return nil
+}
+
+// updateCRDFile replaces the spec schema of gvk in the CRD manifest at path.
+// It returns false if the manifest is not the CRD of gvk.
+func updateCRDFile(path string, gvk schema.GroupVersionKind, specSchema *apiextv1.JSONSchemaProps) (bool, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	b, updated, err := updateCRD(b, gvk, specSchema)
+	if err != nil || !updated {
+		return false, err
+	}
+	return true, ioutil.WriteFile(path, b, 0644)
+}
+
+// updateCRD replaces the spec schema of gvk in a CRD manifest, keeping the
+// spec's description. Only the lines of the spec schema are rewritten, so the
+// rest of the manifest keeps its formatting and comments. It returns false if
+// the manifest is not the CRD of gvk.
+func updateCRD(manifest []byte, gvk schema.GroupVersionKind, specSchema *apiextv1.JSONSchemaProps) ([]byte, bool, error) {
+	crd := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal(manifest, &crd.Object); err != nil {
+		return nil, false, err
+	}
+	fields, err := specSchemaFields(crd, gvk)
+	if err != nil || fields == nil {
+		return nil, false, err
+	}
+
+	doc := &yamlv3.Node{}
+	if err := yamlv3.Unmarshal(manifest, doc); err != nil {
+		return nil, false, err
+	}
+	key, value := findNode(doc, fields)
+	if key == nil {
+		return nil, false, fmt.Errorf("CRD %s has no spec schema at %s", crd.GetName(), strings.Join(fields, "."))
+	}
+
+	b, err := json.Marshal(specSchema)
+	if err != nil {
+		return nil, false, err
+	}
+	spec := map[string]interface{}{}
+	if err := json.Unmarshal(b, &spec); err != nil {
+		return nil, false, err
+	}
+	delete(spec, "description")
+	if _, desc := findNode(value, []string{"description"}); desc != nil && desc.Value != "" {
+		spec["description"] = desc.Value
+	}
+	if b, err = yaml.Marshal(spec); err != nil {
+		return nil, false, err
+	}
+
+	// Replace the key's line and the lines indented under it.
+	lines := strings.SplitAfter(string(manifest), "\n")
+	first, indent := key.Line-1, key.Column-1
+	last := first + 1
+	for ; last < len(lines); last++ {
+		text := strings.TrimLeft(lines[last], " ")
+		if strings.TrimSpace(text) != "" && len(lines[last])-len(text) <= indent {
+			break
+		}
+	}
+	var out strings.Builder
+	for _, line := range lines[:first] {
+		out.WriteString(line)
+	}
+	out.WriteString(lines[first][:indent] + "spec:\n")
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(string(b), "\n"), "\n") {
+		out.WriteString(strings.Repeat(" ", indent+2) + strings.TrimSuffix(line, "\n") + "\n")
+	}
+	for _, line := range lines[last:] {
+		out.WriteString(line)
+	}
+	return []byte(out.String()), true, nil
+}
+
+// specSchemaFields returns the path to the spec schema of gvk in crd, with
+// the indices of versions as strings. It returns nil if crd is not the CRD of
+// gvk.
+func specSchemaFields(crd *unstructured.Unstructured, gvk schema.GroupVersionKind) ([]string, error) {
+	if crd.GetKind() != "CustomResourceDefinition" {
+		return nil, nil
+	}
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	if group != gvk.Group || kind != gvk.Kind {
+		return nil, nil
+	}
+
+	versions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok || version["name"] != gvk.Version {
+			continue
+		}
+		// v1beta1 CRDs may share a schema between versions.
+		if _, ok, _ := unstructured.NestedMap(version, "schema", "openAPIV3Schema"); !ok {
+			if _, ok, _ := unstructured.NestedMap(crd.Object, "spec", "validation", "openAPIV3Schema"); ok {
+				return []string{"spec", "validation", "openAPIV3Schema", "properties", "spec"}, nil
+			}
+		}
+		return []string{"spec", "versions", strconv.Itoa(i), "schema", "openAPIV3Schema", "properties", "spec"}, nil
+	}
+	return nil, fmt.Errorf("CRD %s has no version %s", crd.GetName(), gvk.Version)
+}
+
+// findNode returns the key and value nodes at fields of a YAML node. Fields
+// of sequences are indices.
+func findNode(node *yamlv3.Node, fields []string) (key, value *yamlv3.Node) {
+	if node.Kind == yamlv3.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	value = node
+	for _, field := range fields {
+		switch value.Kind {
+		case yamlv3.MappingNode:
+			var next *yamlv3.Node
+			for i := 0; i+1 < len(value.Content); i += 2 {
+				if value.Content[i].Value == field {
+					key, next = value.Content[i], value.Content[i+1]
+					break
+				}
+			}
+			if next == nil {
+				return nil, nil
+			}
+			value = next
+		case yamlv3.SequenceNode:
+			i, err := strconv.Atoi(field)
+			if err != nil || i < 0 || i >= len(value.Content) {
+				return nil, nil
+			}
+			key, value = nil, value.Content[i]
+		default:
+			return nil, nil
+		}
+	}
+	return key, value
+}
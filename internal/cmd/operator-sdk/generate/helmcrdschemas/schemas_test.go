@@ -0,0 +1,166 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmcrdschemas
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+const v1CRD = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  # Generated by operator-sdk.
+  name: memcacheds.cache.example.com
+spec:
+  group: cache.example.com
+  names:
+    kind: Memcached
+  versions:
+  - name: v1alpha1
+    schema:
+      openAPIV3Schema:
+        properties:
+          spec:
+            description: Spec defines the desired state of Memcached
+            type: object
+            x-kubernetes-preserve-unknown-fields: true
+          status:
+            type: object
+            x-kubernetes-preserve-unknown-fields: true
+        type: object
+    served: true
+`
+
+const v1beta1CRD = `
+apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  # Generated by operator-sdk.
+  name: memcacheds.cache.example.com
+spec:
+  group: cache.example.com
+  names:
+    kind: Memcached
+  validation:
+    openAPIV3Schema:
+      properties:
+        spec:
+          description: Spec defines the desired state of Memcached
+          type: object
+          x-kubernetes-preserve-unknown-fields: true
+      type: object
+  versions:
+  - name: v1alpha1
+`
+
+func TestUpdateCRD(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Memcached"}
+	specSchema := &apiextv1.JSONSchemaProps{
+		Type:       "object",
+		Properties: map[string]apiextv1.JSONSchemaProps{"size": {Type: "integer"}},
+	}
+	expectSpec := map[string]interface{}{
+		"description": "Spec defines the desired state of Memcached",
+		"type":        "object",
+		"properties":  map[string]interface{}{"size": map[string]interface{}{"type": "integer"}},
+	}
+
+	testCases := []struct {
+		name         string
+		crd          string
+		gvk          schema.GroupVersionKind
+		specFields   []string
+		expectUpdate bool
+		expectKept   string
+		expectErr    bool
+	}{
+		{
+			name:         "v1",
+			crd:          v1CRD,
+			gvk:          gvk,
+			specFields:   []string{"schema", "openAPIV3Schema", "properties", "spec"},
+			expectUpdate: true,
+			expectKept: `
+          status:
+            type: object
+            x-kubernetes-preserve-unknown-fields: true
+        type: object
+    served: true
+`,
+		},
+		{
+			name:         "v1beta1 shared schema",
+			crd:          v1beta1CRD,
+			gvk:          gvk,
+			specFields:   []string{"spec", "validation", "openAPIV3Schema", "properties", "spec"},
+			expectUpdate: true,
+			expectKept: `
+      type: object
+  versions:
+  - name: v1alpha1
+`,
+		},
+		{
+			name: "other kind",
+			crd:  v1CRD,
+			gvk:  schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Other"},
+		},
+		{
+			name:      "missing version",
+			crd:       v1CRD,
+			gvk:       schema.GroupVersionKind{Group: "cache.example.com", Version: "v1", Kind: "Memcached"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			manifest, updated, err := updateCRD([]byte(tc.crd), tc.gvk, specSchema)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectUpdate, updated)
+			if !tc.expectUpdate {
+				return
+			}
+
+			crd := &unstructured.Unstructured{}
+			if !assert.NoError(t, yaml.Unmarshal(manifest, &crd.Object)) {
+				return
+			}
+			obj := crd.Object
+			if tc.specFields[0] == "schema" {
+				versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+				obj = versions[0].(map[string]interface{})
+			}
+			spec, _, err := unstructured.NestedMap(obj, tc.specFields...)
+			assert.NoError(t, err)
+			assert.Equal(t, expectSpec, spec)
+
+			// Only the spec schema is rewritten.
+			assert.Contains(t, string(manifest), "  # Generated by operator-sdk.\n")
+			assert.Contains(t, string(manifest), tc.expectKept)
+		})
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package chartsource fetches Helm charts referenced by a watch's chart
+// source from chart repositories and OCI registries into an on-disk cache.
+package chartsource
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/containerd/containerd/remotes"
+	"github.com/containerd/containerd/remotes/docker"
+	orascontent "github.com/deislabs/oras/pkg/content"
+	"github.com/deislabs/oras/pkg/oras"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/repo"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/operator-framework/operator-sdk/internal/helm/watches"
+)
+
+var log = logf.Log.WithName("helm.chartsource")
+
+const (
+	// ociScheme is an optional prefix of OCI chart references.
+	ociScheme = "oci://"
+
+	// Media types of the layer containing a chart archive in an OCI image.
+	helmChartContentLayerMediaType       = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	helmChartContentLayerMediaTypeLegacy = "application/tar+gzip"
+)
+
+// Cache fetches charts into a directory on disk and verifies their digests.
+type Cache struct {
+	dir      string
+	getters  getter.Providers
+	resolver remotes.Resolver
+
+	// locks serializes the resolves of each chart source, so that a slow
+	// fetch of one source does not block the others.
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewCache returns a Cache that stores chart archives in dir.
+func NewCache(dir string) *Cache {
+	return &Cache{
+		dir:      dir,
+		getters:  getter.All(cli.New()),
+		resolver: docker.NewResolver(docker.ResolverOptions{}),
+		locks:    map[string]*sync.Mutex{},
+	}
+}
+
+// Resolve returns the path of a chart archive for src. If an archive for src
+// is already cached it is returned, otherwise the chart is fetched. When src
+// sets a digest, the archive is only returned if its digest matches.
+func (c *Cache) Resolve(ctx context.Context, src watches.ChartSource) (string, error) {
+	key := cacheKey(src)
+	l := c.lock(key)
+	l.Lock()
+	defer l.Unlock()
+
+	path := filepath.Join(c.dir, key+".tgz")
+	if _, err := os.Stat(path); err == nil {
+		if err := VerifyFile(path, src.Digest); err == nil {
+			return path, nil
+		}
+		log.Info("Cached chart does not match digest, fetching chart again", "path", path)
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if src.OCI != "" {
+		data, err = c.fetchOCI(ctx, src.OCI)
+	} else {
+		data, err = c.fetchRepository(src)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := Verify(data, src.Digest); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return "", fmt.Errorf("failed to cache chart: %w", err)
+	}
+	log.Info("Fetched chart", "source", sourceString(src), "path", path)
+	return path, nil
+}
+
+// lock returns the lock of the chart source with key.
+func (c *Cache) lock(key string) *sync.Mutex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		c.locks[key] = l
+	}
+	return l
+}
+
+// fetchRepository downloads the chart archive for src from its repository,
+// verifying the archive against the digest recorded in the repository index.
+func (c *Cache) fetchRepository(src watches.ChartSource) ([]byte, error) {
+	cr, err := repo.NewChartRepository(&repo.Entry{
+		Name: cacheKey(src),
+		URL:  src.Repository,
+	}, c.getters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chart repository: %w", err)
+	}
+	cr.CachePath = filepath.Join(c.dir, "repository")
+	indexPath, err := cr.DownloadIndexFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to download repository index: %w", err)
+	}
+	defer os.Remove(indexPath)
+	index, err := repo.LoadIndexFile(indexPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load repository index: %w", err)
+	}
+	cv, err := index.Get(src.Name, src.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find chart %s-%s in repository %s: %w", src.Name, src.Version, src.Repository, err)
+	}
+	if len(cv.URLs) == 0 {
+		return nil, fmt.Errorf("chart %s-%s in repository %s has no downloadable URLs", src.Name, src.Version, src.Repository)
+	}
+	chartURL, err := repo.ResolveReferenceURL(src.Repository, cv.URLs[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve chart URL: %w", err)
+	}
+	g, err := c.getters.ByScheme(strings.SplitN(chartURL, "://", 2)[0])
+	if err != nil {
+		return nil, err
+	}
+	buf, err := g.Get(chartURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download chart %s: %w", chartURL, err)
+	}
+	data := buf.Bytes()
+	if cv.Digest != "" {
+		if err := Verify(data, "sha256:"+cv.Digest); err != nil {
+			return nil, fmt.Errorf("chart %s does not match repository index: %w", chartURL, err)
+		}
+	}
+	return data, nil
+}
+
+// fetchOCI pulls the chart archive layer of the OCI image at ref.
+func (c *Cache) fetchOCI(ctx context.Context, ref string) ([]byte, error) {
+	ref = strings.TrimPrefix(ref, ociScheme)
+	store := orascontent.NewMemoryStore()
+	_, layers, err := oras.Pull(ctx, c.resolver, ref, store,
+		oras.WithPullEmptyNameAllowed(),
+		oras.WithAllowedMediaType(helmChartContentLayerMediaType, helmChartContentLayerMediaTypeLegacy),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull chart %s: %w", ref, err)
+	}
+	for _, layer := range layers {
+		if _, data, ok := store.Get(layer); ok {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to pull chart %s: no chart content layer found", ref)
+}
+
+// Verify returns an error if data does not match digest. An empty digest
+// matches any data.
+func Verify(data []byte, digest string) error {
+	if digest == "" {
+		return nil
+	}
+	algo, expected := splitDigest(digest)
+	if algo != "sha256" {
+		return fmt.Errorf("unsupported digest algorithm %q", algo)
+	}
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); actual != strings.ToLower(expected) {
+		return fmt.Errorf("digest mismatch: expected sha256:%s, got sha256:%s", expected, actual)
+	}
+	return nil
+}
+
+// VerifyFile returns an error if the contents of the file at path do not
+// match digest.
+func VerifyFile(path, digest string) error {
+	if digest == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return Verify(data, digest)
+}
+
+func splitDigest(digest string) (string, string) {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 {
+		return "", digest
+	}
+	return parts[0], parts[1]
+}
+
+// cacheKey returns a file name unique to src.
+func cacheKey(src watches.ChartSource) string {
+	sum := sha256.Sum256([]byte(sourceString(src)))
+	return hex.EncodeToString(sum[:])
+}
+
+func sourceString(src watches.ChartSource) string {
+	if src.OCI != "" {
+		return ociScheme + strings.TrimPrefix(src.OCI, ociScheme)
+	}
+	return fmt.Sprintf("%s/%s-%s", strings.TrimSuffix(src.Repository, "/"), src.Name, src.Version)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to move chart archive into cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chartsource
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/operator-framework/operator-sdk/internal/helm/watches"
+)
+
+func TestVerify(t *testing.T) {
+	data := []byte("chart")
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+
+	assert.NoError(t, Verify(data, ""))
+	assert.NoError(t, Verify(data, digest))
+	assert.Error(t, Verify([]byte("other"), digest))
+	assert.Error(t, Verify(data, "md5:abc"))
+}
+
+func TestResolveRepository(t *testing.T) {
+	archive := []byte("chart archive")
+	sum := sha256.Sum256(archive)
+	digest := hex.EncodeToString(sum[:])
+
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/index.yaml":
+			fmt.Fprintf(w, `apiVersion: v1
+entries:
+  mychart:
+  - name: mychart
+    version: 1.2.3
+    digest: %s
+    urls:
+    - charts/mychart-1.2.3.tgz
+`, digest)
+		case "/charts/mychart-1.2.3.tgz":
+			_, _ = w.Write(archive)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "chartsource-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := NewCache(dir)
+	src := watches.ChartSource{Repository: srv.URL, Name: "mychart", Version: "1.2.3", Digest: "sha256:" + digest}
+
+	path, err := c.Resolve(context.TODO(), src)
+	if err != nil {
+		t.Fatalf("Expected no error; got error: %v", err)
+	}
+	actual, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, archive, actual)
+	assert.Equal(t, 2, requests)
+
+	// A second resolve is served from the cache.
+	cachedPath, err := c.Resolve(context.TODO(), src)
+	assert.NoError(t, err)
+	assert.Equal(t, path, cachedPath)
+	assert.Equal(t, 2, requests)
+
+	// A digest that does not match the fetched chart is rejected.
+	src.Digest = "sha256:0000"
+	_, err = c.Resolve(context.TODO(), src)
+	assert.Error(t, err)
+
+	// An unknown version is rejected.
+	_, err = c.Resolve(context.TODO(), watches.ChartSource{Repository: srv.URL, Name: "mychart", Version: "9.9.9"})
+	assert.Error(t, err)
+}
+
+func TestResolveSlowSource(t *testing.T) {
+	archive := []byte("chart archive")
+	fetching, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow/index.yaml":
+			close(fetching)
+			<-release
+			http.NotFound(w, r)
+		case "/fast/index.yaml":
+			fmt.Fprint(w, `apiVersion: v1
+entries:
+  mychart:
+  - name: mychart
+    version: 1.2.3
+    urls:
+    - mychart-1.2.3.tgz
+`)
+		case "/fast/mychart-1.2.3.tgz":
+			_, _ = w.Write(archive)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	dir, err := ioutil.TempDir("", "chartsource-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := NewCache(dir)
+
+	// A source that does not respond does not block the resolve of another.
+	go func() {
+		_, _ = c.Resolve(context.TODO(), watches.ChartSource{Repository: srv.URL + "/slow", Name: "mychart", Version: "1.2.3"})
+	}()
+	<-fetching
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Resolve(context.TODO(), watches.ChartSource{Repository: srv.URL + "/fast", Name: "mychart", Version: "1.2.3"})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Resolve was blocked by a slow chart source")
+	}
+}
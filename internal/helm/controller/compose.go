@@ -0,0 +1,485 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/operator-framework/operator-sdk/internal/helm/internal/types"
+	"github.com/operator-framework/operator-sdk/internal/helm/metrics"
+	"github.com/operator-framework/operator-sdk/internal/helm/release"
+)
+
+// componentWaitPeriod is the maximum time until the releases of a composed CR
+// are reconciled again while a release is not ready.
+const componentWaitPeriod = 10 * time.Second
+
+// Component is one of the charts a CR is composed of.
+type Component struct {
+	// Name identifies the component's release in the CR status.
+	Name           string
+	ManagerFactory release.ManagerFactory
+}
+
+// reconcileComposed reconciles a CR composed of the releases of several
+// charts. Releases are installed or upgraded in order, and a release is only
+// installed or upgraded once the releases before it are ready. They are
+// uninstalled in reverse order.
+func (r HelmOperatorReconciler) reconcileComposed(ctx context.Context, o *unstructured.Unstructured,
+	referencedValues []map[string]interface{}) (reconcile.Result, error) {
+	log := log.WithValues(
+		"namespace", o.GetNamespace(),
+		"name", o.GetName(),
+		"apiVersion", o.GetAPIVersion(),
+		"kind", o.GetKind(),
+	)
+
+	status := types.StatusFor(o)
+	managers := make([]release.Manager, len(r.Components))
+	for i, c := range r.Components {
+		manager, err := c.ManagerFactory.NewManager(o, r.OverrideValues, referencedValues...)
+		if err != nil {
+			log.Error(err, "Failed to get release manager", "chart", c.Name)
+			return reconcile.Result{}, err
+		}
+		managers[i] = manager
+	}
+
+	if o.GetDeletionTimestamp() != nil {
+		return r.uninstallComposed(ctx, o, status, managers)
+	}
+
+	status.SetCondition(types.HelmAppCondition{
+		Type:   types.ConditionInitialized,
+		Status: types.StatusTrue,
+	})
+
+	if annotation := unsupportedAnnotation(o); annotation != "" {
+		message := fmt.Sprintf("The %s annotation is not supported by custom resources composed of several charts. "+
+			"Remove it to resume reconciling.", annotation)
+		log.Info("Skipping reconciliation of resource with unsupported annotation", "annotation", annotation)
+		status.SetCondition(types.HelmAppCondition{
+			Type:    types.ConditionIrreconcilable,
+			Status:  types.StatusTrue,
+			Reason:  types.ReasonUnsupportedAnnotation,
+			Message: message,
+		})
+		err := r.updateResourceStatus(ctx, o, status)
+		return reconcile.Result{}, err
+	}
+
+	// Add the finalizer before installing the first release, so that no
+	// release is left behind if the CR is deleted before all are installed.
+	if !(controllerutil.ContainsFinalizer(o, uninstallFinalizer) ||
+		controllerutil.ContainsFinalizer(o, uninstallFinalizerLegacy)) {
+
+		log.V(1).Info("Adding finalizer", "finalizer", uninstallFinalizer)
+		controllerutil.AddFinalizer(o, uninstallFinalizer)
+		if err := r.updateResource(ctx, o); err != nil {
+			log.Info("Failed to add CR uninstall finalizer")
+			return reconcile.Result{}, err
+		}
+	}
+
+	// Keep the status of each chart's release in the order of the charts,
+	// followed by the status of the releases of charts removed from the
+	// watch, which are uninstalled once the releases of the charts are
+	// deployed.
+	releases := make([]types.HelmAppComponentRelease, len(r.Components), len(r.Components)+len(status.Releases))
+	for i, c := range r.Components {
+		if rs := status.ComponentRelease(c.Name); rs != nil {
+			releases[i] = *rs
+		}
+		releases[i].Name = c.Name
+		// A release being renamed keeps its previous name until it is
+		// upgraded.
+		if releases[i].ReleaseName == "" {
+			releases[i].ReleaseName = managers[i].ReleaseName()
+		}
+	}
+	for _, rs := range status.Releases {
+		if !r.hasComponent(rs.Name) {
+			releases = append(releases, rs)
+		}
+	}
+	status.Releases = releases
+
+	var (
+		allDrifted []release.DriftedResource
+		installed  = true
+		waiting    = false
+	)
+	for i, c := range r.Components {
+		rs := &status.Releases[i]
+		log := log.WithValues("chart", c.Name, "release", rs.ReleaseName)
+
+		d, err := r.reconcileComponent(ctx, o, managers[i], referencedValues)
+		allDrifted = append(allDrifted, d.drifted...)
+		reason, rel := d.reason, d.release
+		if err != nil {
+			log.Error(err, "Release failed")
+			rs.SetCondition(types.HelmAppCondition{
+				Type:    types.ConditionReleaseFailed,
+				Status:  types.StatusTrue,
+				Reason:  reason,
+				Message: err.Error(),
+			})
+			conditionType := types.ConditionReleaseFailed
+			if reason == types.ReasonReconcileError {
+				conditionType = types.ConditionIrreconcilable
+			}
+			status.SetCondition(types.HelmAppCondition{
+				Type:    conditionType,
+				Status:  types.StatusTrue,
+				Reason:  reason,
+				Message: fmt.Sprintf("Release of chart %q failed: %s", c.Name, err),
+			})
+			waitForRelease(status, i+1)
+			setComposedReady(status)
+			if err := r.updateResourceStatus(ctx, o, status); err != nil {
+				log.Error(err, "Failed to update status after release failure")
+			}
+			return reconcile.Result{}, err
+		}
+		rs.SetCondition(types.HelmAppCondition{
+			Type:   types.ConditionReleaseFailed,
+			Status: types.StatusFalse,
+		})
+
+		if r.releaseHook != nil {
+			if err := r.releaseHook(rel); err != nil {
+				log.Error(err, "Failed to run release hook")
+				return reconcile.Result{}, err
+			}
+		}
+
+		message := ""
+		if rel.Info != nil {
+			message = rel.Info.Notes
+		}
+		rs.SetCondition(types.HelmAppCondition{
+			Type:    types.ConditionDeployed,
+			Status:  types.StatusTrue,
+			Reason:  reason,
+			Message: message,
+		})
+		rs.ReleaseName = rel.Name
+		rs.Revision = rel.Version
+		if rel.Chart != nil && rel.Chart.Metadata != nil {
+			rs.Chart, rs.ChartVersion = rel.Chart.Metadata.Name, rel.Chart.Metadata.Version
+		}
+		if history, _, err := releaseHistory(managers[i]); err != nil {
+			log.Error(err, "Failed to get release history")
+		} else {
+			rs.History = history
+		}
+		installed = installed && rel.Version == 1
+
+		rs.SetCondition(componentReadyCondition(ctx, managers[i], rel))
+		if i < len(r.Components)-1 && rs.Condition(types.ConditionReady).Status != types.StatusTrue {
+			log.Info("Waiting for release to become ready")
+			waitForRelease(status, i+1)
+			waiting = true
+			break
+		}
+	}
+	r.setDrift(o, status, allDrifted)
+	if !waiting {
+		if err := r.uninstallRemovedReleases(ctx, o, status, managers); err != nil {
+			log.Error(err, "Failed to uninstall release of removed chart")
+			status.SetCondition(types.HelmAppCondition{
+				Type:    types.ConditionReleaseFailed,
+				Status:  types.StatusTrue,
+				Reason:  types.ReasonUninstallError,
+				Message: err.Error(),
+			})
+			setComposedReady(status)
+			if err := r.updateResourceStatus(ctx, o, status); err != nil {
+				log.Error(err, "Failed to update status after uninstall release failure")
+			}
+			return reconcile.Result{}, err
+		}
+	}
+	status.RemoveCondition(types.ConditionReleaseFailed)
+	status.RemoveCondition(types.ConditionIrreconcilable)
+
+	requeueAfter := r.ReconcilePeriod
+	if waiting {
+		if requeueAfter == 0 || requeueAfter > componentWaitPeriod {
+			requeueAfter = componentWaitPeriod
+		}
+	} else {
+		reason := types.ReasonUpgradeSuccessful
+		if installed {
+			reason = types.ReasonInstallSuccessful
+		}
+		status.SetCondition(types.HelmAppCondition{
+			Type:    types.ConditionDeployed,
+			Status:  types.StatusTrue,
+			Reason:  reason,
+			Message: fmt.Sprintf("Deployed the releases of %d charts.", len(status.Releases)),
+		})
+	}
+	setComposedReady(status)
+	err := r.updateResourceStatus(ctx, o, status)
+	return reconcile.Result{RequeueAfter: requeueAfter}, err
+}
+
+// reconcileComponent syncs and then installs, upgrades or reconciles the
+// release of one of the charts of a composed CR.
+func (r HelmOperatorReconciler) reconcileComponent(ctx context.Context, o *unstructured.Unstructured,
+	manager release.Manager, referencedValues []map[string]interface{}) (deployment, error) {
+	if err := manager.Sync(ctx); err != nil {
+		return deployment{reason: types.ReasonReconcileError}, err
+	}
+	return r.deployRelease(ctx, o, manager, true, referencedValues)
+}
+
+// hasComponent returns true if name is the name of one of the reconciler's
+// charts.
+func (r HelmOperatorReconciler) hasComponent(name string) bool {
+	for _, c := range r.Components {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// unsupportedAnnotation returns the first annotation of o that only applies to
+// CRs of a single chart, or an empty string.
+func unsupportedAnnotation(o *unstructured.Unstructured) string {
+	if hasAnnotation(helmDryRunAnnotation, o) {
+		return helmDryRunAnnotation
+	}
+	if _, ok := o.GetAnnotations()[helmRollbackAnnotation]; ok {
+		return helmRollbackAnnotation
+	}
+	return ""
+}
+
+// uninstallRemovedReleases uninstalls the releases of a composed CR's charts
+// that were removed from the watch, and removes them from its status.
+// Releases whose names are used by the releases of the watch's charts are only
+// removed from its status. It returns the first uninstall error; releases that
+// failed to uninstall are kept in the status.
+func (r HelmOperatorReconciler) uninstallRemovedReleases(ctx context.Context, o *unstructured.Unstructured,
+	status *types.HelmAppStatus, managers []release.Manager) error {
+	inUse := map[string]bool{}
+	for _, manager := range managers {
+		inUse[manager.ReleaseName()] = true
+	}
+
+	var firstErr error
+	releases := make([]types.HelmAppComponentRelease, 0, len(status.Releases))
+	for _, rs := range status.Releases {
+		if r.hasComponent(rs.Name) {
+			releases = append(releases, rs)
+			continue
+		}
+		if rs.ReleaseName == "" || inUse[rs.ReleaseName] || len(managers) == 0 {
+			continue
+		}
+		log := log.WithValues("namespace", o.GetNamespace(), "name", o.GetName(),
+			"chart", rs.Name, "release", rs.ReleaseName)
+		_, err := managers[0].UninstallReleaseNamed(ctx, rs.ReleaseName, r.UninstallOptions...)
+		switch {
+		case errors.Is(err, driver.ErrReleaseNotFound):
+			log.Info("Release of removed chart not found")
+		case err != nil:
+			if firstErr == nil {
+				firstErr = fmt.Errorf("uninstall of removed chart %q failed: %w", rs.Name, err)
+			}
+			releases = append(releases, rs)
+		default:
+			log.Info("Uninstalled release of removed chart")
+		}
+	}
+	status.Releases = releases
+	return firstErr
+}
+
+// componentReadyCondition returns the Ready condition of the release of one of
+// the charts of a composed CR.
+func componentReadyCondition(ctx context.Context, manager release.Manager, rel *rpb.Release) types.HelmAppCondition {
+	resources, err := manager.ReleaseResources(ctx, rel.Manifest)
+	if err != nil {
+		log.Error(err, "Failed to get release resources", "release", manager.ReleaseName())
+		return types.HelmAppCondition{
+			Type:    types.ConditionReady,
+			Status:  types.StatusUnknown,
+			Reason:  types.ReasonResourceStatusError,
+			Message: err.Error(),
+		}
+	}
+	return readyCondition(resources)
+}
+
+// waitForRelease marks the releases of a composed CR from index first on as
+// waiting for the releases before them to become ready. Their previously
+// deployed revisions are left as is.
+func waitForRelease(status *types.HelmAppStatus, first int) {
+	if first == 0 || first >= len(status.Releases) {
+		return
+	}
+	message := fmt.Sprintf("Waiting for the release of chart %q to become ready.", status.Releases[first-1].Name)
+	for i := first; i < len(status.Releases); i++ {
+		rs := &status.Releases[i]
+		if c := rs.Condition(types.ConditionDeployed); c != nil && c.Status == types.StatusTrue {
+			continue
+		}
+		rs.SetCondition(types.HelmAppCondition{
+			Type:    types.ConditionDeployed,
+			Status:  types.StatusFalse,
+			Reason:  types.ReasonWaitingForRelease,
+			Message: message,
+		})
+	}
+	status.SetCondition(types.HelmAppCondition{
+		Type:    types.ConditionDeployed,
+		Status:  types.StatusFalse,
+		Reason:  types.ReasonWaitingForRelease,
+		Message: message,
+	})
+}
+
+// setComposedReady sets the Ready condition of a composed CR from the Ready
+// conditions of its releases. The CR is ready once all of its releases are.
+func setComposedReady(status *types.HelmAppStatus) {
+	for _, rs := range status.Releases {
+		ready := rs.Condition(types.ConditionReady)
+		if ready == nil {
+			status.SetCondition(types.HelmAppCondition{
+				Type:    types.ConditionReady,
+				Status:  types.StatusFalse,
+				Reason:  types.ReasonWaitingForRelease,
+				Message: fmt.Sprintf("The release of chart %q is not deployed.", rs.Name),
+			})
+			return
+		}
+		if ready.Status != types.StatusTrue {
+			status.SetCondition(types.HelmAppCondition{
+				Type:    types.ConditionReady,
+				Status:  ready.Status,
+				Reason:  ready.Reason,
+				Message: fmt.Sprintf("The release of chart %q is not ready: %s", rs.Name, ready.Message),
+			})
+			return
+		}
+	}
+	status.SetCondition(types.HelmAppCondition{
+		Type:   types.ConditionReady,
+		Status: types.StatusTrue,
+		Reason: types.ReasonResourcesReady,
+	})
+}
+
+// uninstallComposed uninstalls the releases of a composed CR in the reverse
+// order of its charts, and then removes the CR's finalizer.
+func (r HelmOperatorReconciler) uninstallComposed(ctx context.Context, o *unstructured.Unstructured,
+	status *types.HelmAppStatus, managers []release.Manager) (reconcile.Result, error) {
+	log := log.WithValues(
+		"namespace", o.GetNamespace(),
+		"name", o.GetName(),
+		"apiVersion", o.GetAPIVersion(),
+		"kind", o.GetKind(),
+	)
+	if !(controllerutil.ContainsFinalizer(o, uninstallFinalizer) ||
+		controllerutil.ContainsFinalizer(o, uninstallFinalizerLegacy)) {
+
+		log.Info("Resource is terminated, skipping reconciliation")
+		return reconcile.Result{}, nil
+	}
+
+	if err := r.uninstallRemovedReleases(ctx, o, status, managers); err != nil {
+		log.Error(err, "Failed to uninstall release of removed chart")
+		status.SetCondition(types.HelmAppCondition{
+			Type:    types.ConditionReleaseFailed,
+			Status:  types.StatusTrue,
+			Reason:  types.ReasonUninstallError,
+			Message: err.Error(),
+		})
+		if err := r.updateResourceStatus(ctx, o, status); err != nil {
+			log.Error(err, "Failed to update status after uninstall release failure")
+		}
+		return reconcile.Result{}, err
+	}
+	for i := len(r.Components) - 1; i >= 0; i-- {
+		c, manager := r.Components[i], managers[i]
+		log := log.WithValues("chart", c.Name, "release", manager.ReleaseName())
+
+		_, err := manager.UninstallRelease(ctx, r.UninstallOptions...)
+		if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+			log.Error(err, "Failed to uninstall release")
+			status.SetCondition(types.HelmAppCondition{
+				Type:    types.ConditionReleaseFailed,
+				Status:  types.StatusTrue,
+				Reason:  types.ReasonUninstallError,
+				Message: fmt.Sprintf("Uninstall of chart %q failed: %s", c.Name, err),
+			})
+			if err := r.updateResourceStatus(ctx, o, status); err != nil {
+				log.Error(err, "Failed to update status after uninstall release failure")
+			}
+			return reconcile.Result{}, err
+		}
+		if errors.Is(err, driver.ErrReleaseNotFound) {
+			log.Info("Release not found")
+		} else {
+			log.Info("Uninstalled release")
+		}
+		if rs := status.ComponentRelease(c.Name); rs != nil {
+			rs.SetCondition(types.HelmAppCondition{
+				Type:   types.ConditionDeployed,
+				Status: types.StatusFalse,
+				Reason: types.ReasonUninstallSuccessful,
+			})
+		}
+	}
+	status.RemoveCondition(types.ConditionReleaseFailed)
+	status.SetCondition(types.HelmAppCondition{
+		Type:   types.ConditionDeployed,
+		Status: types.StatusFalse,
+		Reason: types.ReasonUninstallSuccessful,
+	})
+	status.Drift = nil
+	if err := r.updateResourceStatus(ctx, o, status); err != nil {
+		log.Info("Failed to update CR status")
+		return reconcile.Result{}, err
+	}
+
+	metrics.DeleteRelease(r.GVK.String(), o.GetNamespace(), o.GetName())
+
+	log.Info("Removing finalizer")
+	controllerutil.RemoveFinalizer(o, uninstallFinalizer)
+	controllerutil.RemoveFinalizer(o, uninstallFinalizerLegacy)
+	if err := r.updateResource(ctx, o); err != nil {
+		log.Info("Failed to remove CR uninstall finalizer")
+		return reconcile.Result{}, err
+	}
+	if err := r.waitForDeletion(ctx, o); err != nil {
+		log.Info("Failed waiting for CR deletion")
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
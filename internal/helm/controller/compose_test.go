@@ -0,0 +1,242 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rpb "helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/operator-framework/operator-sdk/internal/helm/internal/types"
+	"github.com/operator-framework/operator-sdk/internal/helm/release"
+)
+
+// fakeComponentManager is a release.Manager of a release whose Deployment is
+// ready once ready is set.
+type fakeComponentManager struct {
+	release.Manager
+	releaseName string
+	installed   bool
+	ready       bool
+	uninstalled *[]string
+}
+
+func (m *fakeComponentManager) ReleaseName() string        { return m.releaseName }
+func (m *fakeComponentManager) Sync(context.Context) error { return nil }
+func (m *fakeComponentManager) IsInstalled() bool          { return m.installed }
+func (m *fakeComponentManager) IsUpgradeRequired() bool    { return false }
+func (m *fakeComponentManager) newRelease() *rpb.Release {
+	return &rpb.Release{Name: m.releaseName, Version: 1}
+}
+
+func (m *fakeComponentManager) InstallRelease(context.Context, ...release.InstallOption) (*rpb.Release, error) {
+	m.installed = true
+	return m.newRelease(), nil
+}
+
+func (m *fakeComponentManager) ReconcileRelease(context.Context) (*rpb.Release, []release.DriftedResource, error) {
+	return m.newRelease(), nil, nil
+}
+
+func (m *fakeComponentManager) UninstallRelease(context.Context, ...release.UninstallOption) (*rpb.Release, error) {
+	*m.uninstalled = append(*m.uninstalled, m.releaseName)
+	m.installed = false
+	return m.newRelease(), nil
+}
+
+func (m *fakeComponentManager) UninstallReleaseNamed(_ context.Context, name string,
+	_ ...release.UninstallOption) (*rpb.Release, error) {
+	*m.uninstalled = append(*m.uninstalled, name)
+	return &rpb.Release{Name: name, Version: 1}, nil
+}
+
+func (m *fakeComponentManager) ReleaseHistory() ([]*rpb.Release, error) {
+	if !m.installed {
+		return nil, nil
+	}
+	rel := m.newRelease()
+	rel.Info = &rpb.Info{Status: rpb.StatusDeployed}
+	return []*rpb.Release{rel}, nil
+}
+
+func (m *fakeComponentManager) ReleaseResources(context.Context, string) ([]release.ReleaseResource, error) {
+	available := int64(0)
+	if m.ready {
+		available = 1
+	}
+	return []release.ReleaseResource{newReleaseResource("apps/v1", "Deployment", m.releaseName,
+		map[string]interface{}{"replicas": int64(1)},
+		map[string]interface{}{"replicas": int64(1), "updatedReplicas": int64(1), "availableReplicas": available}),
+	}, nil
+}
+
+// fakeComponentFactory is a release.ManagerFactory that returns m.
+type fakeComponentFactory struct {
+	release.ManagerFactory
+	m *fakeComponentManager
+}
+
+func (f *fakeComponentFactory) NewManager(*unstructured.Unstructured, map[string]string,
+	...map[string]interface{}) (release.Manager, error) {
+	return f.m, nil
+}
+
+// finalizingClient deletes terminating CRs once they have no finalizers, like
+// the API server.
+type finalizingClient struct {
+	client.Client
+}
+
+func (c finalizingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.Client.Update(ctx, obj, opts...); err != nil {
+		return err
+	}
+	if obj.GetDeletionTimestamp() != nil && len(obj.GetFinalizers()) == 0 {
+		return c.Client.Delete(ctx, obj)
+	}
+	return nil
+}
+
+func TestReconcileComposed(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "App"}
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+	metav1.AddToGroupVersion(scheme, gvk.GroupVersion())
+
+	cr := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{}}}
+	cr.SetGroupVersionKind(gvk)
+	cr.SetNamespace("ns")
+	cr.SetName("app")
+	cl := finalizingClient{fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr).Build()}
+
+	var uninstalled []string
+	db := &fakeComponentManager{releaseName: "app-db", uninstalled: &uninstalled}
+	web := &fakeComponentManager{releaseName: "app-web", uninstalled: &uninstalled}
+	r := HelmOperatorReconciler{
+		Client:          cl,
+		EventRecorder:   record.NewFakeRecorder(10),
+		GVK:             gvk,
+		ReconcilePeriod: time.Minute,
+		Components: []Component{
+			{Name: "db", ManagerFactory: &fakeComponentFactory{m: db}},
+			{Name: "web", ManagerFactory: &fakeComponentFactory{m: web}},
+		},
+	}
+	req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(cr)}
+	getStatus := func() *types.HelmAppStatus {
+		o := &unstructured.Unstructured{}
+		o.SetGroupVersionKind(gvk)
+		assert.NoError(t, cl.Get(context.TODO(), req.NamespacedName, o))
+		return types.StatusFor(o)
+	}
+	conditionStatus := func(conditions []types.HelmAppCondition, t types.HelmAppConditionType) types.ConditionStatus {
+		for _, c := range conditions {
+			if c.Type == t {
+				return c.Status
+			}
+		}
+		return ""
+	}
+
+	// The web release waits until the db release is ready.
+	result, err := r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, componentWaitPeriod, result.RequeueAfter)
+	assert.True(t, db.installed)
+	assert.False(t, web.installed)
+	status := getStatus()
+	if assert.Len(t, status.Releases, 2) {
+		assert.Equal(t, "app-db", status.Releases[0].ReleaseName)
+		assert.Equal(t, types.StatusTrue, status.Releases[0].Condition(types.ConditionDeployed).Status)
+		assert.Equal(t, types.StatusFalse, status.Releases[0].Condition(types.ConditionReady).Status)
+		assert.Equal(t, types.ReasonWaitingForRelease, status.Releases[1].Condition(types.ConditionDeployed).Reason)
+	}
+	assert.Equal(t, types.StatusFalse, conditionStatus(status.Conditions, types.ConditionDeployed))
+	assert.Equal(t, types.StatusFalse, conditionStatus(status.Conditions, types.ConditionReady))
+
+	db.ready = true
+	result, err = r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, result.RequeueAfter)
+	assert.True(t, web.installed)
+	status = getStatus()
+	assert.Equal(t, types.StatusTrue, conditionStatus(status.Conditions, types.ConditionDeployed))
+	assert.Equal(t, types.StatusFalse, conditionStatus(status.Conditions, types.ConditionReady))
+
+	web.ready = true
+	_, err = r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	status = getStatus()
+	assert.Equal(t, types.StatusTrue, conditionStatus(status.Conditions, types.ConditionReady))
+	assert.Equal(t, types.StatusTrue, status.Releases[1].Condition(types.ConditionReady).Status)
+
+	if assert.Len(t, status.Releases[0].History, 1) {
+		assert.Equal(t, 1, status.Releases[0].History[0].Revision)
+	}
+
+	// Dry-runs and rollbacks are not supported.
+	o := &unstructured.Unstructured{}
+	o.SetGroupVersionKind(gvk)
+	assert.NoError(t, cl.Get(context.TODO(), req.NamespacedName, o))
+	o.SetAnnotations(map[string]string{helmRollbackAnnotation: "1"})
+	assert.NoError(t, cl.Update(context.TODO(), o))
+	_, err = r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	status = getStatus()
+	assert.Equal(t, types.StatusTrue, conditionStatus(status.Conditions, types.ConditionIrreconcilable))
+	assert.NoError(t, cl.Get(context.TODO(), req.NamespacedName, o))
+	o.SetAnnotations(nil)
+	assert.NoError(t, cl.Update(context.TODO(), o))
+	_, err = r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	status = getStatus()
+	assert.Equal(t, types.ConditionStatus(""), conditionStatus(status.Conditions, types.ConditionIrreconcilable))
+
+	// The releases of charts removed from the watch are uninstalled.
+	cache := &fakeComponentManager{releaseName: "app-cache", uninstalled: &uninstalled}
+	r.Components = append(r.Components, Component{Name: "cache", ManagerFactory: &fakeComponentFactory{m: cache}})
+	cache.ready = true
+	_, err = r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	assert.True(t, cache.installed)
+	r.Components = r.Components[:2]
+	_, err = r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app-cache"}, uninstalled)
+	assert.Len(t, getStatus().Releases, 2)
+	uninstalled = nil
+
+	// Releases are uninstalled in reverse order.
+	o = &unstructured.Unstructured{}
+	o.SetGroupVersionKind(gvk)
+	assert.NoError(t, cl.Get(context.TODO(), req.NamespacedName, o))
+	now := metav1.Now()
+	o.SetDeletionTimestamp(&now)
+	assert.NoError(t, cl.Update(context.TODO(), o))
+	_, err = r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app-web", "app-db"}, uninstalled)
+}
@@ -15,6 +15,7 @@
 package controller
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"sync"
@@ -26,16 +27,21 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	crthandler "sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/yaml"
 
 	libhandler "github.com/operator-framework/operator-lib/handler"
 	"github.com/operator-framework/operator-lib/predicate"
 	"github.com/operator-framework/operator-sdk/internal/helm/release"
+	"github.com/operator-framework/operator-sdk/internal/helm/watches"
 	"github.com/operator-framework/operator-sdk/internal/util/k8sutil"
 )
 
@@ -51,12 +57,35 @@ type WatchOptions struct {
 	WatchDependentResources bool
 	OverrideValues          map[string]string
 	MaxConcurrentReconciles int
+	RollbackOnFailure       bool
+	InstallOptions          []release.InstallOption
+	UpgradeOptions          []release.UpgradeOption
+	UninstallOptions        []release.UninstallOption
+	RollbackOptions         []release.RollbackOption
+	Selector                metav1.LabelSelector
+	NamespaceSelector       metav1.LabelSelector
+	StatusMappings          []watches.StatusMapping
+	// ValidatingWebhook serves a validating admission webhook for the GVK at
+	// ValidatingWebhookPath(GVK).
+	ValidatingWebhook bool
+	// Components composes CRs of the releases of several charts, in order.
+	// If set, ManagerFactory is not used.
+	Components []Component
 }
 
 // Add creates a new helm operator controller and adds it to the manager
 func Add(mgr manager.Manager, options WatchOptions) error {
 	controllerName := fmt.Sprintf("%v-controller", strings.ToLower(options.GVK.Kind))
 
+	selector, err := metav1.LabelSelectorAsSelector(&options.Selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector: %w", err)
+	}
+	namespaceSelector, err := metav1.LabelSelectorAsSelector(&options.NamespaceSelector)
+	if err != nil {
+		return fmt.Errorf("invalid namespace selector: %w", err)
+	}
+
 	r := &HelmOperatorReconciler{
 		Client:          mgr.GetClient(),
 		EventRecorder:   mgr.GetEventRecorderFor(controllerName),
@@ -64,6 +93,20 @@ func Add(mgr manager.Manager, options WatchOptions) error {
 		ManagerFactory:  options.ManagerFactory,
 		ReconcilePeriod: options.ReconcilePeriod,
 		OverrideValues:  options.OverrideValues,
+
+		RollbackOnFailure: options.RollbackOnFailure,
+		InstallOptions:    options.InstallOptions,
+		UpgradeOptions:    options.UpgradeOptions,
+		UninstallOptions:  options.UninstallOptions,
+		RollbackOptions:   options.RollbackOptions,
+
+		Selector:          selector,
+		NamespaceSelector: namespaceSelector,
+
+		Components: options.Components,
+	}
+	if r.statusMappings, err = compileStatusMappings(options.StatusMappings); err != nil {
+		return err
 	}
 
 	// Register the GVK with the schema
@@ -80,13 +123,32 @@ func Add(mgr manager.Manager, options WatchOptions) error {
 
 	o := &unstructured.Unstructured{}
 	o.SetGroupVersionKind(options.GVK)
-	if err := c.Watch(&source.Kind{Type: o}, &libhandler.InstrumentedEnqueueRequestForObject{}); err != nil {
+	filterPredicate := resourceFilterPredicate{r: r}
+	if err := c.Watch(&source.Kind{Type: o}, &libhandler.InstrumentedEnqueueRequestForObject{}, filterPredicate); err != nil {
 		return err
 	}
+	if !namespaceSelector.Empty() {
+		if err := watchNamespaces(mgr, r, c); err != nil {
+			return err
+		}
+	}
 
 	if options.WatchDependentResources {
 		watchDependentResources(mgr, r, c)
 	}
+	watchValuesSources(mgr, r, c)
+
+	if options.ValidatingWebhook {
+		path := ValidatingWebhookPath(options.GVK)
+		mgr.GetWebhookServer().Register(path, &webhook.Admission{Handler: &crValidator{
+			managerFactory: options.ManagerFactory,
+			components:     options.Components,
+			overrideValues: options.OverrideValues,
+			reader:         mgr.GetAPIReader(),
+		}})
+		log.Info("Serving validating webhook", "apiVersion", options.GVK.GroupVersion(), "kind",
+			options.GVK.Kind, "path", path)
+	}
 
 	log.Info("Watching resource", "apiVersion", options.GVK.GroupVersion(), "kind",
 		options.GVK.Kind, "namespace", options.Namespace, "reconcilePeriod", options.ReconcilePeriod.String())
@@ -177,3 +239,47 @@ func watchDependentResources(mgr manager.Manager, r *HelmOperatorReconciler, c c
 	}
 	r.releaseHook = releaseHook
 }
+
+// watchValuesSources adds a hook to the HelmOperatorReconciler that adds a
+// watch for Secrets or ConfigMaps the first time a CR references one of that
+// kind in its values-from annotation. Changes to a referenced object requeue
+// the CRs that reference it. Watches are added lazily so that operators whose
+// CRs do not reference values do not need permission to watch these kinds.
+func watchValuesSources(mgr manager.Manager, r *HelmOperatorReconciler, c controller.Controller) {
+	var m sync.Mutex
+	watches := map[string]struct{}{}
+	r.valuesSourceHook = func(kind string) error {
+		m.Lock()
+		defer m.Unlock()
+		if _, ok := watches[kind]; ok {
+			return nil
+		}
+
+		mapFn := func(obj client.Object) []reconcile.Request {
+			crs := &unstructured.UnstructuredList{}
+			crs.SetGroupVersionKind(r.GVK.GroupVersion().WithKind(r.GVK.Kind + "List"))
+			if err := mgr.GetClient().List(context.TODO(), crs, client.InNamespace(obj.GetNamespace())); err != nil {
+				log.Error(err, "Failed to list resources referencing values", "kind", kind,
+					"namespace", obj.GetNamespace(), "name", obj.GetName())
+				return nil
+			}
+			var reqs []reconcile.Request
+			for i := range crs.Items {
+				if referencesObject(&crs.Items[i], kind, obj.GetName()) {
+					reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{
+						Namespace: crs.Items[i].GetNamespace(),
+						Name:      crs.Items[i].GetName(),
+					}})
+				}
+			}
+			return reqs
+		}
+		if err := c.Watch(&source.Kind{Type: valuesSourceObject(kind)}, crthandler.EnqueueRequestsFromMapFunc(mapFn)); err != nil {
+			return err
+		}
+		watches[kind] = struct{}{}
+		log.Info("Watching values source", "ownerApiVersion", r.GVK.GroupVersion(),
+			"ownerKind", r.GVK.Kind, "kind", kind)
+		return nil
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	crthandler "sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	crpredicate "sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+type resourceFilterPredicate struct {
+	crpredicate.Funcs
+	r *HelmOperatorReconciler
+}
+
+// Skips events of CRs that do not match the selectors defined in watches.yaml.
+// Events of CRs that are being deleted are never skipped, so that their
+// releases are uninstalled and their finalizers removed. If the namespace of a
+// CR cannot be read, the event is not skipped so that the reconciler retries.
+func (p resourceFilterPredicate) eventFilter(obj client.Object) bool {
+	if obj.GetDeletionTimestamp() != nil {
+		return true
+	}
+	selected, err := p.r.selects(context.TODO(), obj)
+	return selected || err != nil
+}
+
+// Predicate functions that call the EventFilter Function
+func (p resourceFilterPredicate) Update(e event.UpdateEvent) bool {
+	return p.eventFilter(e.ObjectNew)
+}
+
+func (p resourceFilterPredicate) Create(e event.CreateEvent) bool {
+	return p.eventFilter(e.Object)
+}
+
+func (p resourceFilterPredicate) Delete(e event.DeleteEvent) bool {
+	return p.eventFilter(e.Object)
+}
+
+func (p resourceFilterPredicate) Generic(e event.GenericEvent) bool {
+	return p.eventFilter(e.Object)
+}
+
+// watchNamespaces reads the namespaces of CRs from a cache of Namespaces, and
+// requeues the CRs in a namespace when its labels change, so that changes to
+// the labels take effect immediately. The cache is separate from the
+// manager's, which may be restricted to the watched namespaces.
+func watchNamespaces(mgr manager.Manager, r *HelmOperatorReconciler, c controller.Controller) error {
+	namespaces, err := cache.New(mgr.GetConfig(), cache.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+	if err != nil {
+		return err
+	}
+	if err := mgr.Add(namespaces); err != nil {
+		return err
+	}
+	r.namespaceReader = namespaces
+
+	mapFn := func(obj client.Object) []reconcile.Request {
+		crs := &unstructured.UnstructuredList{}
+		crs.SetGroupVersionKind(r.GVK.GroupVersion().WithKind(r.GVK.Kind + "List"))
+		if err := mgr.GetClient().List(context.TODO(), crs, client.InNamespace(obj.GetName())); err != nil {
+			log.Error(err, "Failed to list resources in namespace", "namespace", obj.GetName())
+			return nil
+		}
+		reqs := make([]reconcile.Request, 0, len(crs.Items))
+		for i := range crs.Items {
+			reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{
+				Namespace: crs.Items[i].GetNamespace(),
+				Name:      crs.Items[i].GetName(),
+			}})
+		}
+		return reqs
+	}
+	labelsChanged := crpredicate.Funcs{
+		CreateFunc: func(event.CreateEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return !labels.Equals(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels())
+		},
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+	}
+	return c.Watch(source.NewKindWithCache(&corev1.Namespace{}, namespaces),
+		crthandler.EnqueueRequestsFromMapFunc(mapFn), labelsChanged)
+}
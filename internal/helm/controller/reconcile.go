@@ -18,13 +18,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	rpb "helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/storage/driver"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/record"
@@ -35,6 +40,7 @@ import (
 
 	"github.com/operator-framework/operator-sdk/internal/helm/internal/diff"
 	"github.com/operator-framework/operator-sdk/internal/helm/internal/types"
+	"github.com/operator-framework/operator-sdk/internal/helm/metrics"
 	"github.com/operator-framework/operator-sdk/internal/helm/release"
 )
 
@@ -52,7 +58,32 @@ type HelmOperatorReconciler struct {
 	ManagerFactory  release.ManagerFactory
 	ReconcilePeriod time.Duration
 	OverrideValues  map[string]string
+	// RollbackOnFailure stops retrying a failed upgrade, which is rolled
+	// back to the last deployed revision, until the chart or values of the
+	// release change.
+	RollbackOnFailure bool
+	// InstallOptions, UpgradeOptions, UninstallOptions and RollbackOptions
+	// are applied to every install, upgrade, uninstall and rollback of a
+	// release, before any options set by the reconciler.
+	InstallOptions   []release.InstallOption
+	UpgradeOptions   []release.UpgradeOption
+	UninstallOptions []release.UninstallOption
+	RollbackOptions  []release.RollbackOption
+	// Selector and NamespaceSelector restrict reconciliation to CRs whose
+	// labels, and whose namespace's labels, match. Nil selectors match all
+	// CRs.
+	Selector          labels.Selector
+	NamespaceSelector labels.Selector
+	// Components composes CRs of the releases of several charts. If set,
+	// ManagerFactory is not used.
+	Components      []Component
+	namespaceReader client.Reader
 	releaseHook     ReleaseHookFunc
+	// valuesSourceHook is called with the kind of each Secret or ConfigMap
+	// referenced by a CR's values-from annotation.
+	valuesSourceHook func(kind string) error
+	// statusMappings copy fields of release resources into CR status.
+	statusMappings []statusMapping
 }
 
 const (
@@ -63,6 +94,13 @@ const (
 
 	helmUpgradeForceAnnotation  = "helm.sdk.operatorframework.io/upgrade-force"
 	helmUninstallWaitAnnotation = "helm.sdk.operatorframework.io/uninstall-wait"
+	helmDryRunAnnotation        = "helm.sdk.operatorframework.io/dry-run"
+	helmRollbackAnnotation      = "helm.sdk.operatorframework.io/rollback-revision"
+	helmValuesFromAnnotation    = "helm.sdk.operatorframework.io/values-from"
+
+	// maxPendingDiffSize is the maximum size of a dry-run diff recorded in
+	// a CR's status, to keep the CR well under the etcd object size limit.
+	maxPendingDiffSize = 64 * 1024
 )
 
 // Reconcile reconciles the requested resource by installing, updating, or
@@ -70,7 +108,20 @@ const (
 // release changes are necessary, Reconcile will create or patch the underlying
 // resources to match the expected release manifest.
 
-func (r HelmOperatorReconciler) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) { //nolint:gocyclo
+func (r HelmOperatorReconciler) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	timer := metrics.ReconcileTimer(r.GVK.String())
+	defer timer.ObserveDuration()
+
+	result, err := r.reconcile(ctx, request)
+	if err != nil {
+		metrics.ReconcileFailed(r.GVK.String())
+	} else {
+		metrics.ReconcileSucceeded(r.GVK.String())
+	}
+	return result, err
+}
+
+func (r HelmOperatorReconciler) reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) { //nolint:gocyclo
 	o := &unstructured.Unstructured{}
 	o.SetGroupVersionKind(r.GVK)
 	o.SetNamespace(request.Namespace)
@@ -92,7 +143,45 @@ func (r HelmOperatorReconciler) Reconcile(ctx context.Context, request reconcile
 		return reconcile.Result{}, err
 	}
 
-	manager, err := r.ManagerFactory.NewManager(o, r.OverrideValues)
+	// Events of dependent resources are not filtered by the watch's
+	// selectors, so CRs managed by another operator may still be requested.
+	// CRs that are being deleted are reconciled regardless, so that their
+	// releases are uninstalled and their finalizers removed.
+	if o.GetDeletionTimestamp() == nil {
+		selected, err := r.selects(ctx, o)
+		if err != nil {
+			log.Error(err, "Failed to match selectors")
+			return reconcile.Result{}, err
+		}
+		if !selected {
+			log.V(1).Info("Resource does not match selectors, skipping reconciliation")
+			return reconcile.Result{}, nil
+		}
+	}
+
+	referencedValues, err := r.referencedValues(ctx, o)
+	// Values are not needed to uninstall a release, and referenced objects
+	// may already be deleted along with the CR's namespace.
+	if err != nil && o.GetDeletionTimestamp() == nil {
+		log.Error(err, "Failed to get referenced values")
+		status := types.StatusFor(o)
+		status.SetCondition(types.HelmAppCondition{
+			Type:    types.ConditionIrreconcilable,
+			Status:  types.StatusTrue,
+			Reason:  types.ReasonValuesFromError,
+			Message: err.Error(),
+		})
+		if err := r.updateResourceStatus(ctx, o, status); err != nil {
+			log.Error(err, "Failed to update status after values failure")
+		}
+		return reconcile.Result{}, err
+	}
+
+	if len(r.Components) != 0 {
+		return r.reconcileComposed(ctx, o, referencedValues)
+	}
+
+	manager, err := r.ManagerFactory.NewManager(o, r.OverrideValues, referencedValues...)
 	if err != nil {
 		log.Error(err, "Failed to get release manager")
 		return reconcile.Result{}, err
@@ -109,7 +198,7 @@ func (r HelmOperatorReconciler) Reconcile(ctx context.Context, request reconcile
 			return reconcile.Result{}, nil
 		}
 
-		uninstalledRelease, err := manager.UninstallRelease(ctx)
+		uninstalledRelease, err := manager.UninstallRelease(ctx, r.UninstallOptions...)
 		if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
 			log.Error(err, "Failed to uninstall release")
 			status.SetCondition(types.HelmAppCondition{
@@ -140,6 +229,8 @@ func (r HelmOperatorReconciler) Reconcile(ctx context.Context, request reconcile
 					Reason: types.ReasonUninstallSuccessful,
 				})
 				status.DeployedRelease = nil
+				status.History = nil
+				status.Drift = nil
 			}
 		}
 		if wait {
@@ -176,6 +267,8 @@ func (r HelmOperatorReconciler) Reconcile(ctx context.Context, request reconcile
 			status.RemoveCondition(types.ConditionReleaseFailed)
 		}
 
+		metrics.DeleteRelease(r.GVK.String(), o.GetNamespace(), o.GetName())
+
 		log.Info("Removing finalizer")
 		controllerutil.RemoveFinalizer(o, uninstallFinalizer)
 		controllerutil.RemoveFinalizer(o, uninstallFinalizerLegacy)
@@ -216,67 +309,101 @@ func (r HelmOperatorReconciler) Reconcile(ctx context.Context, request reconcile
 	}
 	status.RemoveCondition(types.ConditionIrreconcilable)
 
-	if !manager.IsInstalled() {
-		for k, v := range r.OverrideValues {
-			r.EventRecorder.Eventf(o, "Warning", "OverrideValuesInUse",
-				"Chart value %q overridden to %q by operator's watches.yaml", k, v)
-		}
-		installedRelease, err := manager.InstallRelease(ctx)
-		if err != nil {
-			log.Error(err, "Release failed")
-			status.SetCondition(types.HelmAppCondition{
-				Type:    types.ConditionReleaseFailed,
-				Status:  types.StatusTrue,
-				Reason:  types.ReasonInstallError,
-				Message: err.Error(),
-			})
-			if err := r.updateResourceStatus(ctx, o, status); err != nil {
-				log.Error(err, "Failed to update status after install release failure")
+	if hasAnnotation(helmDryRunAnnotation, o) {
+		return r.dryRun(ctx, o, manager, status)
+	}
+	status.RemoveCondition(types.ConditionPendingChanges)
+	status.PendingRelease = nil
+
+	installed := manager.IsInstalled()
+	pinned, retryBlocked := false, false
+	if installed {
+		if !(controllerutil.ContainsFinalizer(o, uninstallFinalizer) ||
+			controllerutil.ContainsFinalizer(o, uninstallFinalizerLegacy)) {
+
+			log.V(1).Info("Adding finalizer", "finalizer", uninstallFinalizer)
+			controllerutil.AddFinalizer(o, uninstallFinalizer)
+			if err := r.updateResource(ctx, o); err != nil {
+				log.Info("Failed to add CR uninstall finalizer")
+				return reconcile.Result{}, err
 			}
-			return reconcile.Result{}, err
 		}
-		status.RemoveCondition(types.ConditionReleaseFailed)
 
-		log.V(1).Info("Adding finalizer", "finalizer", uninstallFinalizer)
-		controllerutil.AddFinalizer(o, uninstallFinalizer)
-		if err := r.updateResource(ctx, o); err != nil {
-			log.Info("Failed to add CR uninstall finalizer")
-			return reconcile.Result{}, err
+		// While a rollback is requested by annotation, the release is pinned
+		// to the rolled back revision and is not upgraded.
+		if revision, ok := rollbackRevision(o); ok {
+			if status.RollbackRevision != revision {
+				return r.rollbackRelease(ctx, o, manager, status, revision)
+			}
+			pinned = true
+		} else {
+			status.RollbackRevision = 0
 		}
 
-		if r.releaseHook != nil {
-			if err := r.releaseHook(installedRelease); err != nil {
-				log.Error(err, "Failed to run release hook")
+		// A failed upgrade is not retried until the chart or values change.
+		if r.RollbackOnFailure && status.FailedUpgradeDigest != "" {
+			digest, err := manager.Digest()
+			if err != nil {
+				log.Error(err, "Failed to get release digest")
 				return reconcile.Result{}, err
 			}
+			retryBlocked = digest == status.FailedUpgradeDigest
+		}
+		if !retryBlocked {
+			status.FailedUpgradeDigest = ""
 		}
+	}
 
-		log.Info("Installed release")
-		if log.V(0).Enabled() {
-			fmt.Println(diff.Generate("", installedRelease.Manifest))
+	// If a change is made to the CR spec that causes a release failure, a
+	// ConditionReleaseFailed is added to the status conditions. If that change
+	// is then reverted to its previous state, the operator will stop
+	// attempting the release and will resume reconciling. In this case, we
+	// need to remove the ConditionReleaseFailed because the failing release is
+	// no longer being attempted. A failed upgrade that is not retried keeps
+	// its condition.
+	if !retryBlocked {
+		status.RemoveCondition(types.ConditionReleaseFailed)
+	}
+
+	d, err := r.deployRelease(ctx, o, manager, !pinned && !retryBlocked, referencedValues)
+	if d.reconciled {
+		r.setDrift(o, status, d.drifted)
+	}
+	if err != nil {
+		condition := types.HelmAppCondition{
+			Type:    types.ConditionReleaseFailed,
+			Status:  types.StatusTrue,
+			Reason:  d.reason,
+			Message: err.Error(),
 		}
-		log.V(1).Info("Config values", "values", installedRelease.Config)
-		message := ""
-		if installedRelease.Info != nil {
-			message = installedRelease.Info.Notes
+		switch d.reason {
+		case types.ReasonReconcileError:
+			log.Error(err, "Failed to reconcile release")
+			condition.Type = types.ConditionIrreconcilable
+		case types.ReasonUpgradeError:
+			log.Error(err, "Release failed")
+			if r.RollbackOnFailure {
+				// The manager has already rolled the failed upgrade back.
+				var digestErr error
+				if status.FailedUpgradeDigest, digestErr = manager.Digest(); digestErr != nil {
+					log.Error(digestErr, "Failed to get release digest")
+				}
+				condition.Message += "; the upgrade is not retried until the chart or values change"
+				r.EventRecorder.Eventf(o, "Warning", "UpgradeRolledBack",
+					"Upgrade of release %q failed and was rolled back", manager.ReleaseName())
+			}
+		default:
+			log.Error(err, "Release failed")
 		}
-		status.SetCondition(types.HelmAppCondition{
-			Type:    types.ConditionDeployed,
-			Status:  types.StatusTrue,
-			Reason:  types.ReasonInstallSuccessful,
-			Message: message,
-		})
-		status.DeployedRelease = &types.HelmAppRelease{
-			Name:     installedRelease.Name,
-			Manifest: installedRelease.Manifest,
+		status.SetCondition(condition)
+		if err := r.updateResourceStatus(ctx, o, status); err != nil {
+			log.Error(err, "Failed to update status after release failure")
 		}
-		err = r.updateResourceStatus(ctx, o, status)
-		return reconcile.Result{RequeueAfter: r.ReconcilePeriod}, err
+		return reconcile.Result{}, err
 	}
+	status.RemoveCondition(types.ConditionIrreconcilable)
 
-	if !(controllerutil.ContainsFinalizer(o, uninstallFinalizer) ||
-		controllerutil.ContainsFinalizer(o, uninstallFinalizerLegacy)) {
-
+	if !installed {
 		log.V(1).Info("Adding finalizer", "finalizer", uninstallFinalizer)
 		controllerutil.AddFinalizer(o, uninstallFinalizer)
 		if err := r.updateResource(ctx, o); err != nil {
@@ -285,107 +412,399 @@ func (r HelmOperatorReconciler) Reconcile(ctx context.Context, request reconcile
 		}
 	}
 
-	if manager.IsUpgradeRequired() {
-		for k, v := range r.OverrideValues {
-			r.EventRecorder.Eventf(o, "Warning", "OverrideValuesInUse",
-				"Chart value %q overridden to %q by operator's watches.yaml", k, v)
-		}
-		force := hasAnnotation(helmUpgradeForceAnnotation, o)
-		previousRelease, upgradedRelease, err := manager.UpgradeRelease(ctx, release.ForceUpgrade(force))
-		if err != nil {
-			log.Error(err, "Release failed")
-			status.SetCondition(types.HelmAppCondition{
-				Type:    types.ConditionReleaseFailed,
-				Status:  types.StatusTrue,
-				Reason:  types.ReasonUpgradeError,
-				Message: err.Error(),
-			})
-			if err := r.updateResourceStatus(ctx, o, status); err != nil {
-				log.Error(err, "Failed to update status after sync release failure")
-			}
+	if r.releaseHook != nil {
+		if err := r.releaseHook(d.release); err != nil {
+			log.Error(err, "Failed to run release hook")
 			return reconcile.Result{}, err
 		}
-		status.RemoveCondition(types.ConditionReleaseFailed)
+	}
 
-		if r.releaseHook != nil {
-			if err := r.releaseHook(upgradedRelease); err != nil {
-				log.Error(err, "Failed to run release hook")
-				return reconcile.Result{}, err
-			}
-		}
+	reason := d.reason
+	if d.reconciled && (pinned || retryBlocked) {
+		reason = types.ReasonRollbackSuccessful
+	}
+	message := ""
+	if d.release.Info != nil {
+		message = d.release.Info.Notes
+	}
+	status.SetCondition(types.HelmAppCondition{
+		Type:    types.ConditionDeployed,
+		Status:  types.StatusTrue,
+		Reason:  reason,
+		Message: message,
+	})
+	status.DeployedRelease = &types.HelmAppRelease{
+		Name:     d.release.Name,
+		Manifest: d.release.Manifest,
+	}
+	r.setHistory(o, status, manager)
+	r.setResourceStatus(ctx, o, status, manager)
+	err = r.updateResourceStatus(ctx, o, status)
+	return reconcile.Result{RequeueAfter: r.ReconcilePeriod}, err
+}
+
+// deployment is the outcome of deployRelease.
+type deployment struct {
+	release *rpb.Release
+	// reconciled is true if the release was neither installed nor upgraded,
+	// and its resources were reconciled with its manifest instead.
+	reconciled bool
+	drifted    []release.DriftedResource
+	// reason is the reason of the release's Deployed condition, or of its
+	// failure.
+	reason types.HelmAppConditionReason
+}
 
+// deployRelease installs the release of manager if it is not installed,
+// upgrades it if upgrade is set and an upgrade is required, and otherwise
+// reconciles the release's resources with its manifest. It records the events,
+// logs and metrics of the release action. The values of releases are only
+// logged if the CR references none, since they may be read from Secrets.
+func (r HelmOperatorReconciler) deployRelease(ctx context.Context, o *unstructured.Unstructured, manager release.Manager,
+	upgrade bool, referencedValues []map[string]interface{}) (deployment, error) {
+	log := log.WithValues(
+		"namespace", o.GetNamespace(),
+		"name", o.GetName(),
+		"apiVersion", o.GetAPIVersion(),
+		"kind", o.GetKind(),
+		"release", manager.ReleaseName(),
+	)
+
+	switch {
+	case !manager.IsInstalled():
+		r.recordOverrideValues(o)
+		installedRelease, err := manager.InstallRelease(ctx, r.InstallOptions...)
+		if err != nil {
+			return deployment{reason: types.ReasonInstallError}, err
+		}
+		log.Info("Installed release")
+		if log.V(0).Enabled() {
+			fmt.Println(diff.Generate("", installedRelease.Manifest))
+		}
+		if len(referencedValues) == 0 {
+			log.V(1).Info("Config values", "values", installedRelease.Config)
+		}
+		return deployment{release: installedRelease, reason: types.ReasonInstallSuccessful}, nil
+	case upgrade && manager.IsUpgradeRequired():
+		r.recordOverrideValues(o)
+		force := hasAnnotation(helmUpgradeForceAnnotation, o)
+		previousRelease, upgradedRelease, err := manager.UpgradeRelease(ctx, r.upgradeOptions(release.ForceUpgrade(force))...)
+		if err != nil {
+			return deployment{reason: types.ReasonUpgradeError}, err
+		}
 		log.Info("Upgraded release", "force", force)
+		metrics.ReleaseDiffSize(r.GVK.String(), len(diff.GeneratePlain(previousRelease.Manifest, upgradedRelease.Manifest)))
 		if log.V(0).Enabled() {
 			fmt.Println(diff.Generate(previousRelease.Manifest, upgradedRelease.Manifest))
 		}
-		log.V(1).Info("Config values", "values", upgradedRelease.Config)
-		message := ""
-		if upgradedRelease.Info != nil {
-			message = upgradedRelease.Info.Notes
+		if len(referencedValues) == 0 {
+			log.V(1).Info("Config values", "values", upgradedRelease.Config)
 		}
-		status.SetCondition(types.HelmAppCondition{
-			Type:    types.ConditionDeployed,
-			Status:  types.StatusTrue,
-			Reason:  types.ReasonUpgradeSuccessful,
-			Message: message,
-		})
-		status.DeployedRelease = &types.HelmAppRelease{
-			Name:     upgradedRelease.Name,
-			Manifest: upgradedRelease.Manifest,
+		return deployment{release: upgradedRelease, reason: types.ReasonUpgradeSuccessful}, nil
+	default:
+		expectedRelease, drifted, err := manager.ReconcileRelease(ctx)
+		d := deployment{release: expectedRelease, reconciled: true, drifted: drifted}
+		if err != nil {
+			d.reason = types.ReasonReconcileError
+			return d, err
 		}
-		err = r.updateResourceStatus(ctx, o, status)
-		return reconcile.Result{RequeueAfter: r.ReconcilePeriod}, err
+		log.Info("Reconciled release")
+		d.reason = types.ReasonUpgradeSuccessful
+		if expectedRelease.Version == 1 {
+			d.reason = types.ReasonInstallSuccessful
+		}
+		return d, nil
 	}
+}
 
-	// If a change is made to the CR spec that causes a release failure, a
-	// ConditionReleaseFailed is added to the status conditions. If that change
-	// is then reverted to its previous state, the operator will stop
-	// attempting the release and will resume reconciling. In this case, we
-	// need to remove the ConditionReleaseFailed because the failing release is
-	// no longer being attempted.
-	status.RemoveCondition(types.ConditionReleaseFailed)
+// recordOverrideValues records a warning event on the CR for each chart value
+// overridden by the watch.
+func (r HelmOperatorReconciler) recordOverrideValues(o *unstructured.Unstructured) {
+	for k, v := range r.OverrideValues {
+		r.EventRecorder.Eventf(o, "Warning", "OverrideValuesInUse",
+			"Chart value %q overridden to %q by operator's watches.yaml", k, v)
+	}
+}
 
-	expectedRelease, err := manager.ReconcileRelease(ctx)
+// rollbackRelease rolls the release back to revision, as requested by the
+// CR's rollback annotation.
+func (r HelmOperatorReconciler) rollbackRelease(ctx context.Context, o *unstructured.Unstructured, manager release.Manager,
+	status *types.HelmAppStatus, revision int) (reconcile.Result, error) {
+	log := log.WithValues(
+		"namespace", o.GetNamespace(),
+		"name", o.GetName(),
+		"apiVersion", o.GetAPIVersion(),
+		"kind", o.GetKind(),
+		"release", manager.ReleaseName(),
+		"revision", revision,
+	)
+
+	rolledBackRelease, err := manager.RollbackRelease(ctx, r.rollbackOptions(release.RollbackToRevision(revision))...)
 	if err != nil {
-		log.Error(err, "Failed to reconcile release")
+		log.Error(err, "Rollback failed")
 		status.SetCondition(types.HelmAppCondition{
-			Type:    types.ConditionIrreconcilable,
+			Type:    types.ConditionReleaseFailed,
 			Status:  types.StatusTrue,
-			Reason:  types.ReasonReconcileError,
+			Reason:  types.ReasonRollbackError,
 			Message: err.Error(),
 		})
 		if err := r.updateResourceStatus(ctx, o, status); err != nil {
-			log.Error(err, "Failed to update status after reconcile release failure")
+			log.Error(err, "Failed to update status after rollback release failure")
 		}
 		return reconcile.Result{}, err
 	}
-	status.RemoveCondition(types.ConditionIrreconcilable)
+	status.RemoveCondition(types.ConditionReleaseFailed)
 
 	if r.releaseHook != nil {
-		if err := r.releaseHook(expectedRelease); err != nil {
+		if err := r.releaseHook(rolledBackRelease); err != nil {
 			log.Error(err, "Failed to run release hook")
 			return reconcile.Result{}, err
 		}
 	}
 
-	log.Info("Reconciled release")
-	reason := types.ReasonUpgradeSuccessful
-	if expectedRelease.Version == 1 {
-		reason = types.ReasonInstallSuccessful
+	log.Info("Rolled back release")
+	status.SetCondition(types.HelmAppCondition{
+		Type:   types.ConditionDeployed,
+		Status: types.StatusTrue,
+		Reason: types.ReasonRollbackSuccessful,
+		Message: fmt.Sprintf("Rolled back to revision %d. Remove the %s annotation to resume upgrades.",
+			revision, helmRollbackAnnotation),
+	})
+	status.DeployedRelease = &types.HelmAppRelease{
+		Name:     rolledBackRelease.Name,
+		Manifest: rolledBackRelease.Manifest,
 	}
-	message := ""
-	if expectedRelease.Info != nil {
-		message = expectedRelease.Info.Notes
+	status.RollbackRevision = revision
+	r.setHistory(o, status, manager)
+	r.setResourceStatus(ctx, o, status, manager)
+	err = r.updateResourceStatus(ctx, o, status)
+	return reconcile.Result{RequeueAfter: r.ReconcilePeriod}, err
+}
+
+// setHistory records the stored revisions of the release in status, and its
+// deployed revision in metrics.
+func (r HelmOperatorReconciler) setHistory(o *unstructured.Unstructured, status *types.HelmAppStatus, manager release.Manager) {
+	history, deployed, err := releaseHistory(manager)
+	if err != nil {
+		log.Error(err, "Failed to get release history", "release", manager.ReleaseName())
+		return
+	}
+	status.History = history
+	if deployed != nil {
+		r.recordDeployedRelease(o, deployed)
+	}
+}
+
+// releaseHistory returns the stored revisions of the release of manager,
+// newest first, and its latest deployed revision.
+func releaseHistory(manager release.Manager) ([]types.HelmAppReleaseRevision, *rpb.Release, error) {
+	history, err := manager.ReleaseHistory()
+	if err != nil {
+		return nil, nil, err
+	}
+	var deployed *rpb.Release
+	revisions := make([]types.HelmAppReleaseRevision, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		rel := history[i]
+		if deployed == nil && rel.Info != nil && rel.Info.Status == rpb.StatusDeployed {
+			deployed = rel
+		}
+		rev := types.HelmAppReleaseRevision{Revision: rel.Version}
+		if rel.Chart != nil && rel.Chart.Metadata != nil {
+			rev.ChartVersion = rel.Chart.Metadata.Version
+		}
+		if rel.Info != nil {
+			rev.Status = rel.Info.Status.String()
+			rev.Description = rel.Info.Description
+			rev.Updated = metav1.NewTime(rel.Info.LastDeployed.Time)
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, deployed, nil
+}
+
+// recordDeployedRelease records the revision and chart of the CR's deployed
+// release in metrics.
+func (r HelmOperatorReconciler) recordDeployedRelease(o *unstructured.Unstructured, rel *rpb.Release) {
+	var chart, version string
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		chart, version = rel.Chart.Metadata.Name, rel.Chart.Metadata.Version
+	}
+	metrics.ReleaseDeployed(r.GVK.String(), o.GetNamespace(), o.GetName(), rel.Version, chart, version)
+}
+
+// installOptions returns the reconciler's install options followed by opts.
+func (r HelmOperatorReconciler) installOptions(opts ...release.InstallOption) []release.InstallOption {
+	return append(append([]release.InstallOption{}, r.InstallOptions...), opts...)
+}
+
+// upgradeOptions returns the reconciler's upgrade options followed by opts.
+func (r HelmOperatorReconciler) upgradeOptions(opts ...release.UpgradeOption) []release.UpgradeOption {
+	return append(append([]release.UpgradeOption{}, r.UpgradeOptions...), opts...)
+}
+
+// rollbackOptions returns the reconciler's rollback options followed by opts.
+func (r HelmOperatorReconciler) rollbackOptions(opts ...release.RollbackOption) []release.RollbackOption {
+	return append(append([]release.RollbackOption{}, r.RollbackOptions...), opts...)
+}
+
+// maxDriftedResources is the maximum number of drifted resources listed in a
+// CR's status.
+const maxDriftedResources = 20
+
+// setDrift records the drifted resources of a release in the CR's status and
+// in metrics. The drift count and time only change when the drifted resources
+// do, so that drift that persists, such as reported drift, does not change
+// the status on every reconcile, which would requeue the CR.
+func (r HelmOperatorReconciler) setDrift(o *unstructured.Unstructured, status *types.HelmAppStatus,
+	drifted []release.DriftedResource) {
+	healed := true
+	for _, d := range drifted {
+		healed = healed && d.Healed
+	}
+	metrics.ReleaseDrift(r.GVK.String(), o.GetNamespace(), o.GetName(), len(drifted), healed)
+
+	if len(drifted) == 0 {
+		status.SetCondition(types.HelmAppCondition{
+			Type:   types.ConditionDrifted,
+			Status: types.StatusFalse,
+			Reason: types.ReasonNoDrift,
+		})
+		if status.Drift != nil {
+			status.Drift.Resources = nil
+		}
+		return
+	}
+
+	if status.Drift == nil {
+		status.Drift = &types.HelmAppDrift{}
+	}
+	var resources []types.HelmAppDriftedResource
+	var names []string
+	for i, d := range drifted {
+		if i == maxDriftedResources {
+			names = append(names, fmt.Sprintf("and %d more", len(drifted)-i))
+			break
+		}
+		names = append(names, fmt.Sprintf("%s/%s", d.Kind, d.Name))
+		resources = append(resources, types.HelmAppDriftedResource{
+			APIVersion: d.APIVersion,
+			Kind:       d.Kind,
+			Namespace:  d.Namespace,
+			Name:       d.Name,
+			Missing:    d.Missing,
+			Paths:      d.Paths,
+		})
+	}
+	if !reflect.DeepEqual(resources, status.Drift.Resources) {
+		now := metav1.Now()
+		status.Drift.Count++
+		status.Drift.LastDriftTime = &now
+		status.Drift.Resources = resources
+	}
+
+	reason, verb := types.ReasonDriftHealed, "Healed"
+	if !healed {
+		reason, verb = types.ReasonDriftDetected, "Detected"
 	}
 	status.SetCondition(types.HelmAppCondition{
-		Type:    types.ConditionDeployed,
+		Type:    types.ConditionDrifted,
 		Status:  types.StatusTrue,
 		Reason:  reason,
-		Message: message,
+		Message: fmt.Sprintf("%s drift of %d resource(s): %s", verb, len(drifted), strings.Join(names, ", ")),
 	})
-	status.DeployedRelease = &types.HelmAppRelease{
-		Name:     expectedRelease.Name,
-		Manifest: expectedRelease.Manifest,
+}
+
+// rollbackRevision returns the revision requested by the CR's rollback
+// annotation, if it is set to a valid revision.
+func rollbackRevision(o *unstructured.Unstructured) (int, bool) {
+	revStr := o.GetAnnotations()[helmRollbackAnnotation]
+	if revStr == "" {
+		return 0, false
+	}
+	rev, err := strconv.Atoi(revStr)
+	if err != nil || rev < 1 {
+		log.Info("Could not parse annotation as a revision",
+			"annotation", helmRollbackAnnotation, "value informed", revStr)
+		return 0, false
+	}
+	return rev, true
+}
+
+// dryRun renders the install or upgrade the CR would cause and records the
+// resulting diff in the CR's status without applying anything.
+func (r HelmOperatorReconciler) dryRun(ctx context.Context, o *unstructured.Unstructured, manager release.Manager,
+	status *types.HelmAppStatus) (reconcile.Result, error) {
+	log := log.WithValues(
+		"namespace", o.GetNamespace(),
+		"name", o.GetName(),
+		"apiVersion", o.GetAPIVersion(),
+		"kind", o.GetKind(),
+		"release", manager.ReleaseName(),
+	)
+
+	var (
+		reason  types.HelmAppConditionReason
+		diffStr string
+		err     error
+	)
+	switch {
+	case !manager.IsInstalled():
+		var rendered *rpb.Release
+		rendered, err = manager.InstallRelease(ctx, r.installOptions(release.DryRunInstall(true))...)
+		if err == nil {
+			reason = types.ReasonDryRunInstall
+			diffStr = diff.GeneratePlain("", rendered.Manifest)
+		}
+	case manager.IsUpgradeRequired():
+		force := hasAnnotation(helmUpgradeForceAnnotation, o)
+		var previous, rendered *rpb.Release
+		previous, rendered, err = manager.UpgradeRelease(ctx, r.upgradeOptions(release.ForceUpgrade(force), release.DryRunUpgrade(true))...)
+		if err == nil {
+			reason = types.ReasonDryRunUpgrade
+			diffStr = diff.GeneratePlain(previous.Manifest, rendered.Manifest)
+		}
+	default:
+		reason = types.ReasonDryRunNoChanges
+	}
+	if err != nil {
+		log.Error(err, "Dry-run failed")
+		status.SetCondition(types.HelmAppCondition{
+			Type:    types.ConditionPendingChanges,
+			Status:  types.StatusUnknown,
+			Reason:  types.ReasonDryRunError,
+			Message: err.Error(),
+		})
+		status.PendingRelease = nil
+		if err := r.updateResourceStatus(ctx, o, status); err != nil {
+			log.Error(err, "Failed to update status after dry-run failure")
+		}
+		return reconcile.Result{}, err
+	}
+
+	if reason == types.ReasonDryRunNoChanges {
+		log.Info("Dry-run found no pending changes")
+		status.SetCondition(types.HelmAppCondition{
+			Type:   types.ConditionPendingChanges,
+			Status: types.StatusFalse,
+			Reason: reason,
+		})
+		status.PendingRelease = nil
+	} else {
+		log.Info("Dry-run found pending changes", "reason", reason)
+		if len(diffStr) > maxPendingDiffSize {
+			diffStr = diffStr[:maxPendingDiffSize] + "\n... diff truncated ...\n"
+		}
+		status.SetCondition(types.HelmAppCondition{
+			Type:    types.ConditionPendingChanges,
+			Status:  types.StatusTrue,
+			Reason:  reason,
+			Message: fmt.Sprintf("Remove the %s annotation to apply the pending changes.", helmDryRunAnnotation),
+		})
+		status.PendingRelease = &types.HelmAppPendingRelease{
+			Name: manager.ReleaseName(),
+			Diff: diffStr,
+		}
 	}
 	err = r.updateResourceStatus(ctx, o, status)
 	return reconcile.Result{RequeueAfter: r.ReconcilePeriod}, err
@@ -408,6 +827,27 @@ func hasAnnotation(anno string, o *unstructured.Unstructured) bool {
 	return value
 }
 
+// selects returns true if the reconciler's selectors match o and o's
+// namespace.
+func (r HelmOperatorReconciler) selects(ctx context.Context, o client.Object) (bool, error) {
+	if r.Selector != nil && !r.Selector.Matches(labels.Set(o.GetLabels())) {
+		return false, nil
+	}
+	if r.NamespaceSelector == nil || r.NamespaceSelector.Empty() {
+		return true, nil
+	}
+
+	reader := r.namespaceReader
+	if reader == nil {
+		reader = r.Client
+	}
+	ns := &corev1.Namespace{}
+	if err := reader.Get(ctx, client.ObjectKey{Name: o.GetNamespace()}, ns); err != nil {
+		return false, fmt.Errorf("failed to get namespace %q to match namespace selector: %w", o.GetNamespace(), err)
+	}
+	return r.NamespaceSelector.Matches(labels.Set(ns.GetLabels())), nil
+}
+
 func (r HelmOperatorReconciler) updateResource(ctx context.Context, o client.Object) error {
 	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
 		return r.Client.Update(ctx, o)
@@ -415,8 +855,14 @@ func (r HelmOperatorReconciler) updateResource(ctx context.Context, o client.Obj
 }
 
 func (r HelmOperatorReconciler) updateResourceStatus(ctx context.Context, o *unstructured.Unstructured, status *types.HelmAppStatus) error {
+	// The status is stored as a map, which includes the fields mapped from
+	// release resources.
+	statusMap, err := status.ToMap()
+	if err != nil {
+		return fmt.Errorf("failed to convert status: %w", err)
+	}
 	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
-		o.Object["status"] = status
+		o.Object["status"] = statusMap
 		return r.Client.Status().Update(ctx, o)
 	})
 }
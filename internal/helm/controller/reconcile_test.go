@@ -15,10 +15,26 @@
 package controller
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/operator-framework/operator-sdk/internal/helm/internal/types"
+	"github.com/operator-framework/operator-sdk/internal/helm/release"
+	"github.com/operator-framework/operator-sdk/internal/helm/watches"
 )
 
 func TestHasAnnotation(t *testing.T) {
@@ -131,6 +147,40 @@ func TestHasAnnotation(t *testing.T) {
 	}
 }
 
+func TestRollbackRevision(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       map[string]interface{}
+		expectedRev int
+		expectedOK  bool
+	}{
+		{
+			name:        "valid revision",
+			input:       map[string]interface{}{"helm.sdk.operatorframework.io/rollback-revision": "3"},
+			expectedRev: 3,
+			expectedOK:  true,
+		},
+		{
+			name:  "annotation not set",
+			input: map[string]interface{}{},
+		},
+		{
+			name:  "invalid revision",
+			input: map[string]interface{}{"helm.sdk.operatorframework.io/rollback-revision": "latest"},
+		},
+		{
+			name:  "non-positive revision",
+			input: map[string]interface{}{"helm.sdk.operatorframework.io/rollback-revision": "0"},
+		},
+	}
+
+	for _, test := range tests {
+		rev, ok := rollbackRevision(annotations(test.input))
+		assert.Equal(t, test.expectedRev, rev, test.name)
+		assert.Equal(t, test.expectedOK, ok, test.name)
+	}
+}
+
 func annotations(m map[string]interface{}) *unstructured.Unstructured {
 	return &unstructured.Unstructured{
 		Object: map[string]interface{}{
@@ -140,3 +190,167 @@ func annotations(m map[string]interface{}) *unstructured.Unstructured {
 		},
 	}
 }
+
+func TestSelects(t *testing.T) {
+	canary := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "canary", Labels: map[string]string{"env": "canary"}}}
+	prod := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: map[string]string{"env": "prod"}}}
+	cl := fake.NewClientBuilder().WithObjects(canary, prod).Build()
+
+	newCR := func(namespace string, lbls map[string]string) *unstructured.Unstructured {
+		o := &unstructured.Unstructured{}
+		o.SetNamespace(namespace)
+		o.SetName("cr")
+		o.SetLabels(lbls)
+		return o
+	}
+
+	shardA := labels.SelectorFromSet(labels.Set{"shard": "a"})
+	canaryNS := labels.SelectorFromSet(labels.Set{"env": "canary"})
+	tests := []struct {
+		name              string
+		selector          labels.Selector
+		namespaceSelector labels.Selector
+		cr                *unstructured.Unstructured
+		expected          bool
+		expectErr         bool
+	}{
+		{
+			name:     "no selectors",
+			cr:       newCR("prod", nil),
+			expected: true,
+		},
+		{
+			name:     "matching labels",
+			selector: shardA,
+			cr:       newCR("prod", map[string]string{"shard": "a"}),
+			expected: true,
+		},
+		{
+			name:     "non-matching labels",
+			selector: shardA,
+			cr:       newCR("prod", map[string]string{"shard": "b"}),
+			expected: false,
+		},
+		{
+			name:              "matching namespace",
+			namespaceSelector: canaryNS,
+			cr:                newCR("canary", nil),
+			expected:          true,
+		},
+		{
+			name:              "non-matching namespace",
+			namespaceSelector: canaryNS,
+			cr:                newCR("prod", nil),
+			expected:          false,
+		},
+		{
+			name:              "missing namespace",
+			namespaceSelector: canaryNS,
+			cr:                newCR("missing", nil),
+			expected:          false,
+			expectErr:         true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := HelmOperatorReconciler{Client: cl, Selector: tc.selector, NamespaceSelector: tc.namespaceSelector}
+			selected, err := r.selects(context.TODO(), tc.cr)
+			assert.Equal(t, tc.expectErr, err != nil)
+			assert.Equal(t, tc.expected, selected)
+		})
+	}
+
+	// Events of deleted CRs and of CRs whose namespace cannot be read are not
+	// filtered, so that the reconciler uninstalls their releases or retries.
+	p := resourceFilterPredicate{r: &HelmOperatorReconciler{Client: cl, NamespaceSelector: canaryNS}}
+	assert.False(t, p.Create(event.CreateEvent{Object: newCR("prod", nil)}))
+	assert.True(t, p.Create(event.CreateEvent{Object: newCR("missing", nil)}))
+	deleted := newCR("prod", nil)
+	deleted.SetDeletionTimestamp(&metav1.Time{Time: time.Now()})
+	assert.True(t, p.Update(event.UpdateEvent{ObjectOld: newCR("prod", nil), ObjectNew: deleted}))
+}
+
+func TestSetDrift(t *testing.T) {
+	r := HelmOperatorReconciler{GVK: schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Nginx"}}
+	o := &unstructured.Unstructured{}
+	o.SetNamespace("ns")
+	o.SetName("cr")
+	status := &types.HelmAppStatus{}
+
+	r.setDrift(o, status, nil)
+	assert.Nil(t, status.Drift)
+	assert.Equal(t, types.ReasonNoDrift, status.Conditions[0].Reason)
+
+	r.setDrift(o, status, []release.DriftedResource{
+		{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "ns", Name: "web", Paths: []string{"/spec/replicas"}, Healed: true},
+	})
+	assert.Equal(t, types.StatusTrue, status.Conditions[0].Status)
+	assert.Equal(t, types.ReasonDriftHealed, status.Conditions[0].Reason)
+	assert.Equal(t, "Healed drift of 1 resource(s): Deployment/web", status.Conditions[0].Message)
+	assert.Equal(t, int64(1), status.Drift.Count)
+	assert.NotNil(t, status.Drift.LastDriftTime)
+	assert.Equal(t, []types.HelmAppDriftedResource{
+		{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "ns", Name: "web", Paths: []string{"/spec/replicas"}},
+	}, status.Drift.Resources)
+
+	r.setDrift(o, status, []release.DriftedResource{
+		{APIVersion: "v1", Kind: "Service", Namespace: "ns", Name: "web", Missing: true},
+	})
+	assert.Equal(t, types.ReasonDriftDetected, status.Conditions[0].Reason)
+	assert.Equal(t, int64(2), status.Drift.Count)
+
+	// Drift of the same resources that persists is not counted again.
+	lastDriftTime := status.Drift.LastDriftTime
+	conditions := append([]types.HelmAppCondition{}, status.Conditions...)
+	r.setDrift(o, status, []release.DriftedResource{
+		{APIVersion: "v1", Kind: "Service", Namespace: "ns", Name: "web", Missing: true},
+	})
+	assert.Equal(t, int64(2), status.Drift.Count)
+	assert.Same(t, lastDriftTime, status.Drift.LastDriftTime)
+	assert.Equal(t, conditions, status.Conditions)
+
+	r.setDrift(o, status, nil)
+	assert.Equal(t, types.StatusFalse, status.Conditions[0].Status)
+	assert.Empty(t, status.Drift.Resources)
+	assert.Equal(t, int64(2), status.Drift.Count)
+}
+
+func TestReconcileStatusMappings(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "App"}
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+	metav1.AddToGroupVersion(scheme, gvk.GroupVersion())
+
+	cr := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{}}}
+	cr.SetGroupVersionKind(gvk)
+	cr.SetNamespace("ns")
+	cr.SetName("app")
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cr).Build()
+
+	mappings, err := compileStatusMappings([]watches.StatusMapping{
+		{Field: "web.replicas", Kind: "Deployment", JSONPath: "{.status.replicas}"},
+	})
+	assert.NoError(t, err)
+	r := HelmOperatorReconciler{
+		Client:          cl,
+		EventRecorder:   record.NewFakeRecorder(10),
+		GVK:             gvk,
+		ManagerFactory:  &fakeComponentFactory{m: &fakeComponentManager{releaseName: "app"}},
+		ReconcilePeriod: time.Minute,
+		statusMappings:  mappings,
+	}
+	req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(cr)}
+	_, err = r.Reconcile(context.TODO(), req)
+	assert.NoError(t, err)
+
+	// The mapped fields are stored in the status of the CR.
+	o := &unstructured.Unstructured{}
+	o.SetGroupVersionKind(gvk)
+	assert.NoError(t, cl.Get(context.TODO(), req.NamespacedName, o))
+	replicas, found, err := unstructured.NestedInt64(o.Object, "status", "web", "replicas")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(1), replicas)
+	assert.NotNil(t, types.StatusFor(o).DeployedRelease)
+}
@@ -0,0 +1,445 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/jsonpath"
+
+	"github.com/operator-framework/operator-sdk/internal/helm/internal/types"
+	"github.com/operator-framework/operator-sdk/internal/helm/release"
+	"github.com/operator-framework/operator-sdk/internal/helm/watches"
+)
+
+// maxUnreadyResources is the maximum number of unready resources listed in the
+// message of a CR's Ready condition.
+const maxUnreadyResources = 10
+
+// health is the kstatus-style health of a release resource.
+type health string
+
+const (
+	healthCurrent    health = "Current"
+	healthInProgress health = "InProgress"
+	healthFailed     health = "Failed"
+	healthNotFound   health = "NotFound"
+)
+
+// setResourceStatus sets the CR's Ready condition from the health of the
+// deployed release's resources, and copies the fields selected by the watch's
+// status mappings into the CR's status.
+func (r HelmOperatorReconciler) setResourceStatus(ctx context.Context, o *unstructured.Unstructured,
+	status *types.HelmAppStatus, manager release.Manager) {
+	if status.DeployedRelease == nil {
+		return
+	}
+	resources, err := manager.ReleaseResources(ctx, status.DeployedRelease.Manifest)
+	if err != nil {
+		log.Error(err, "Failed to get release resources", "namespace", o.GetNamespace(), "name", o.GetName())
+		status.SetCondition(types.HelmAppCondition{
+			Type:    types.ConditionReady,
+			Status:  types.StatusUnknown,
+			Reason:  types.ReasonResourceStatusError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	status.SetCondition(readyCondition(resources))
+
+	if len(r.statusMappings) == 0 {
+		status.Mapped = nil
+		return
+	}
+	mapped, err := mapStatus(r.statusMappings, resources, status.DeployedRelease.Name, o.GetNamespace())
+	if err != nil {
+		// Keep the previously mapped fields rather than dropping them.
+		log.Error(err, "Failed to map release resource status", "namespace", o.GetNamespace(), "name", o.GetName())
+		return
+	}
+	status.Mapped = mapped
+}
+
+// statusMapping is a status mapping whose name template and JSONPath
+// expression are parsed.
+type statusMapping struct {
+	watches.StatusMapping
+	field    []string
+	name     *template.Template
+	jsonPath *jsonpath.JSONPath
+}
+
+// compileStatusMappings parses the name templates and JSONPath expressions of
+// mappings, so that they are not parsed on every reconcile.
+func compileStatusMappings(mappings []watches.StatusMapping) ([]statusMapping, error) {
+	compiled := make([]statusMapping, 0, len(mappings))
+	for _, m := range mappings {
+		tmpl, err := watches.ParseStatusMappingName(m.Name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name of status mapping %q: %w", m.Field, err)
+		}
+		jp, err := watches.ParseStatusMappingJSONPath(m.JSONPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jsonPath of status mapping %q: %w", m.Field, err)
+		}
+		compiled = append(compiled, statusMapping{
+			StatusMapping: m,
+			field:         strings.Split(m.Field, "."),
+			name:          tmpl,
+			jsonPath:      jp,
+		})
+	}
+	return compiled, nil
+}
+
+// mapStatus returns the status fields selected by mappings from resources.
+// Fields whose resource or value does not exist are not set.
+func mapStatus(mappings []statusMapping, resources []release.ReleaseResource,
+	releaseName, namespace string) (map[string]interface{}, error) {
+	var data watches.StatusMappingNameData
+	data.Release.Name = releaseName
+	data.Release.Namespace = namespace
+
+	mapped := map[string]interface{}{}
+	for _, m := range mappings {
+		var name strings.Builder
+		if err := m.name.Execute(&name, data); err != nil {
+			return nil, fmt.Errorf("invalid name of status mapping %q: %w", m.Field, err)
+		}
+
+		obj := findResource(resources, m.APIVersion, m.Kind, name.String())
+		if obj == nil {
+			continue
+		}
+		// JSONPaths keep state while finding results, so evaluate a copy since
+		// mappings are shared by concurrent reconciles.
+		jp := *m.jsonPath
+		results, err := jp.FindResults(obj.Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate status mapping %q: %w", m.Field, err)
+		}
+		var values []interface{}
+		for _, rs := range results {
+			for _, v := range rs {
+				values = append(values, v.Interface())
+			}
+		}
+
+		var value interface{}
+		switch len(values) {
+		case 0:
+			continue
+		case 1:
+			value = values[0]
+		default:
+			value = values
+		}
+		// Copy the value, since it may be part of the resource.
+		if err := unstructured.SetNestedField(mapped, deepCopyValue(value), m.field...); err != nil {
+			return nil, fmt.Errorf("failed to set status field %q: %w", m.Field, err)
+		}
+	}
+	return mapped, nil
+}
+
+// findResource returns the first existing resource with the given kind, and
+// with the given API version and name if they are set.
+func findResource(resources []release.ReleaseResource, apiVersion, kind, name string) *unstructured.Unstructured {
+	for _, res := range resources {
+		if res.Object == nil || res.Kind != kind {
+			continue
+		}
+		if (apiVersion == "" || res.APIVersion == apiVersion) && (name == "" || res.Name == name) {
+			return res.Object
+		}
+	}
+	return nil
+}
+
+// deepCopyValue copies a value of an unstructured object, converting integers
+// to int64 as unstructured objects expect.
+func deepCopyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = deepCopyValue(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = deepCopyValue(e)
+		}
+		return out
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	default:
+		return v
+	}
+}
+
+// readyCondition returns the Ready condition of a CR whose release has the
+// given resources. The CR is ready when all of its resources are.
+func readyCondition(resources []release.ReleaseResource) types.HelmAppCondition {
+	var unready []string
+	failed := false
+	for _, res := range resources {
+		h, message := resourceHealth(res)
+		if h == healthCurrent {
+			continue
+		}
+		if h == healthFailed {
+			failed = true
+		}
+		unready = append(unready, fmt.Sprintf("%s/%s: %s", res.Kind, res.Name, message))
+	}
+
+	if len(unready) == 0 {
+		return types.HelmAppCondition{
+			Type:    types.ConditionReady,
+			Status:  types.StatusTrue,
+			Reason:  types.ReasonResourcesReady,
+			Message: fmt.Sprintf("%d resource(s) ready", len(resources)),
+		}
+	}
+	reason := types.ReasonResourcesNotReady
+	if failed {
+		reason = types.ReasonResourcesFailed
+	}
+	message := fmt.Sprintf("%d of %d resource(s) not ready: ", len(unready), len(resources))
+	if len(unready) > maxUnreadyResources {
+		message += strings.Join(unready[:maxUnreadyResources], "; ") +
+			fmt.Sprintf("; and %d more", len(unready)-maxUnreadyResources)
+	} else {
+		message += strings.Join(unready, "; ")
+	}
+	return types.HelmAppCondition{
+		Type:    types.ConditionReady,
+		Status:  types.StatusFalse,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+var (
+	deploymentGK  = schema.GroupKind{Group: "apps", Kind: "Deployment"}
+	statefulSetGK = schema.GroupKind{Group: "apps", Kind: "StatefulSet"}
+	daemonSetGK   = schema.GroupKind{Group: "apps", Kind: "DaemonSet"}
+	replicaSetGK  = schema.GroupKind{Group: "apps", Kind: "ReplicaSet"}
+	jobGK         = schema.GroupKind{Group: "batch", Kind: "Job"}
+	podGK         = schema.GroupKind{Kind: "Pod"}
+	pvcGK         = schema.GroupKind{Kind: "PersistentVolumeClaim"}
+	serviceGK     = schema.GroupKind{Kind: "Service"}
+	crdGK         = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+)
+
+// resourceHealth returns the health of a release resource and, unless it is
+// current, a message describing why. It follows the rules of kstatus: built-in
+// workloads are checked by kind, and other resources by their observed
+// generation and Ready, Reconciling and Stalled conditions.
+func resourceHealth(res release.ReleaseResource) (health, string) {
+	u := res.Object
+	if u == nil {
+		return healthNotFound, "not found"
+	}
+	if u.GetDeletionTimestamp() != nil {
+		return healthInProgress, "being deleted"
+	}
+	if observed, found, _ := unstructured.NestedInt64(u.Object, "status", "observedGeneration"); found &&
+		observed < u.GetGeneration() {
+		return healthInProgress, "latest generation not yet observed"
+	}
+
+	switch u.GroupVersionKind().GroupKind() {
+	case deploymentGK:
+		return deploymentHealth(u)
+	case statefulSetGK:
+		return statefulSetHealth(u)
+	case daemonSetGK:
+		return daemonSetHealth(u)
+	case replicaSetGK:
+		return replicaSetHealth(u)
+	case jobGK:
+		return jobHealth(u)
+	case podGK:
+		return podHealth(u)
+	case pvcGK:
+		if phase := nestedString(u, "status", "phase"); phase != "Bound" {
+			return healthInProgress, fmt.Sprintf("phase is %q", phase)
+		}
+		return healthCurrent, ""
+	case serviceGK:
+		if nestedString(u, "spec", "type") == "LoadBalancer" {
+			ingress, _, _ := unstructured.NestedSlice(u.Object, "status", "loadBalancer", "ingress")
+			if len(ingress) == 0 {
+				return healthInProgress, "load balancer not yet provisioned"
+			}
+		}
+		return healthCurrent, ""
+	case crdGK:
+		if c, ok := condition(u, "NamesAccepted"); ok && c.status == "False" {
+			return healthFailed, fmt.Sprintf("names not accepted: %s", c.message)
+		}
+		if c, ok := condition(u, "Established"); !ok || c.status != "True" {
+			return healthInProgress, "not yet established"
+		}
+		return healthCurrent, ""
+	}
+	return conditionsHealth(u)
+}
+
+func deploymentHealth(u *unstructured.Unstructured) (health, string) {
+	if c, ok := condition(u, "Progressing"); ok && c.reason == "ProgressDeadlineExceeded" {
+		return healthFailed, "progress deadline exceeded"
+	}
+	replicas := nestedInt64(u, 1, "spec", "replicas")
+	updated := nestedInt64(u, 0, "status", "updatedReplicas")
+	total := nestedInt64(u, 0, "status", "replicas")
+	available := nestedInt64(u, 0, "status", "availableReplicas")
+	switch {
+	case updated < replicas:
+		return healthInProgress, fmt.Sprintf("%d of %d replicas updated", updated, replicas)
+	case total > updated:
+		return healthInProgress, fmt.Sprintf("%d old replicas pending termination", total-updated)
+	case available < updated:
+		return healthInProgress, fmt.Sprintf("%d of %d updated replicas available", available, updated)
+	}
+	return healthCurrent, ""
+}
+
+func statefulSetHealth(u *unstructured.Unstructured) (health, string) {
+	replicas := nestedInt64(u, 1, "spec", "replicas")
+	ready := nestedInt64(u, 0, "status", "readyReplicas")
+	if ready < replicas {
+		return healthInProgress, fmt.Sprintf("%d of %d replicas ready", ready, replicas)
+	}
+	if nestedString(u, "spec", "updateStrategy", "type") == "OnDelete" {
+		return healthCurrent, ""
+	}
+	partition := nestedInt64(u, 0, "spec", "updateStrategy", "rollingUpdate", "partition")
+	updated := nestedInt64(u, 0, "status", "updatedReplicas")
+	if updated < replicas-partition {
+		return healthInProgress, fmt.Sprintf("%d of %d replicas updated", updated, replicas-partition)
+	}
+	if partition == 0 && nestedString(u, "status", "currentRevision") != nestedString(u, "status", "updateRevision") {
+		return healthInProgress, "rolling update in progress"
+	}
+	return healthCurrent, ""
+}
+
+func daemonSetHealth(u *unstructured.Unstructured) (health, string) {
+	desired := nestedInt64(u, 0, "status", "desiredNumberScheduled")
+	updated := nestedInt64(u, 0, "status", "updatedNumberScheduled")
+	available := nestedInt64(u, 0, "status", "numberAvailable")
+	switch {
+	case updated < desired:
+		return healthInProgress, fmt.Sprintf("%d of %d pods updated", updated, desired)
+	case available < desired:
+		return healthInProgress, fmt.Sprintf("%d of %d pods available", available, desired)
+	}
+	return healthCurrent, ""
+}
+
+func replicaSetHealth(u *unstructured.Unstructured) (health, string) {
+	replicas := nestedInt64(u, 1, "spec", "replicas")
+	available := nestedInt64(u, 0, "status", "availableReplicas")
+	if available < replicas {
+		return healthInProgress, fmt.Sprintf("%d of %d replicas available", available, replicas)
+	}
+	return healthCurrent, ""
+}
+
+func jobHealth(u *unstructured.Unstructured) (health, string) {
+	if c, ok := condition(u, "Failed"); ok && c.status == "True" {
+		return healthFailed, fmt.Sprintf("failed: %s", c.message)
+	}
+	if c, ok := condition(u, "Complete"); ok && c.status == "True" {
+		return healthCurrent, ""
+	}
+	return healthInProgress, "not yet complete"
+}
+
+func podHealth(u *unstructured.Unstructured) (health, string) {
+	switch phase := nestedString(u, "status", "phase"); phase {
+	case "Succeeded":
+		return healthCurrent, ""
+	case "Failed":
+		return healthFailed, fmt.Sprintf("failed: %s", nestedString(u, "status", "message"))
+	case "Running":
+		if c, ok := condition(u, "Ready"); ok && c.status == "True" {
+			return healthCurrent, ""
+		}
+		return healthInProgress, "not yet ready"
+	default:
+		return healthInProgress, fmt.Sprintf("phase is %q", phase)
+	}
+}
+
+func conditionsHealth(u *unstructured.Unstructured) (health, string) {
+	if c, ok := condition(u, "Stalled"); ok && c.status == "True" {
+		return healthFailed, fmt.Sprintf("stalled: %s", c.message)
+	}
+	if c, ok := condition(u, "Reconciling"); ok && c.status == "True" {
+		return healthInProgress, fmt.Sprintf("reconciling: %s", c.message)
+	}
+	if c, ok := condition(u, "Ready"); ok && c.status == "False" {
+		return healthInProgress, fmt.Sprintf("not ready: %s", c.message)
+	}
+	return healthCurrent, ""
+}
+
+type resourceCondition struct {
+	status  string
+	reason  string
+	message string
+}
+
+// condition returns the status.conditions entry of type t of u.
+func condition(u *unstructured.Unstructured, t string) (resourceCondition, bool) {
+	conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	for _, c := range conditions {
+		m, ok := c.(map[string]interface{})
+		if !ok || m["type"] != t {
+			continue
+		}
+		rc := resourceCondition{}
+		rc.status, _ = m["status"].(string)
+		rc.reason, _ = m["reason"].(string)
+		rc.message, _ = m["message"].(string)
+		return rc, true
+	}
+	return resourceCondition{}, false
+}
+
+func nestedInt64(u *unstructured.Unstructured, def int64, fields ...string) int64 {
+	if v, found, err := unstructured.NestedInt64(u.Object, fields...); found && err == nil {
+		return v
+	}
+	return def
+}
+
+func nestedString(u *unstructured.Unstructured, fields ...string) string {
+	v, _, _ := unstructured.NestedString(u.Object, fields...)
+	return v
+}
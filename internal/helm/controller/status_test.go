@@ -0,0 +1,196 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/operator-framework/operator-sdk/internal/helm/internal/types"
+	"github.com/operator-framework/operator-sdk/internal/helm/release"
+	"github.com/operator-framework/operator-sdk/internal/helm/watches"
+)
+
+func newReleaseResource(apiVersion, kind, name string, spec, status map[string]interface{}) release.ReleaseResource {
+	obj := map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": "ns"},
+	}
+	if spec != nil {
+		obj["spec"] = spec
+	}
+	if status != nil {
+		obj["status"] = status
+	}
+	return release.ReleaseResource{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  "ns",
+		Name:       name,
+		Object:     &unstructured.Unstructured{Object: obj},
+	}
+}
+
+func TestResourceHealth(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource release.ReleaseResource
+		expected health
+	}{
+		{
+			name:     "missing",
+			resource: release.ReleaseResource{Kind: "ConfigMap", Name: "cm"},
+			expected: healthNotFound,
+		},
+		{
+			name:     "configmap",
+			resource: newReleaseResource("v1", "ConfigMap", "cm", nil, nil),
+			expected: healthCurrent,
+		},
+		{
+			name: "available deployment",
+			resource: newReleaseResource("apps/v1", "Deployment", "web",
+				map[string]interface{}{"replicas": int64(2)},
+				map[string]interface{}{"replicas": int64(2), "updatedReplicas": int64(2), "availableReplicas": int64(2)}),
+			expected: healthCurrent,
+		},
+		{
+			name: "rolling deployment",
+			resource: newReleaseResource("apps/v1", "Deployment", "web",
+				map[string]interface{}{"replicas": int64(2)},
+				map[string]interface{}{"replicas": int64(3), "updatedReplicas": int64(2), "availableReplicas": int64(2)}),
+			expected: healthInProgress,
+		},
+		{
+			name: "stuck deployment",
+			resource: newReleaseResource("apps/v1", "Deployment", "web", nil,
+				map[string]interface{}{"conditions": []interface{}{
+					map[string]interface{}{"type": "Progressing", "status": "False", "reason": "ProgressDeadlineExceeded"},
+				}}),
+			expected: healthFailed,
+		},
+		{
+			name: "unobserved generation",
+			resource: func() release.ReleaseResource {
+				res := newReleaseResource("apps/v1", "DaemonSet", "agent", nil,
+					map[string]interface{}{"observedGeneration": int64(1)})
+				res.Object.SetGeneration(2)
+				return res
+			}(),
+			expected: healthInProgress,
+		},
+		{
+			name: "partitioned statefulset",
+			resource: newReleaseResource("apps/v1", "StatefulSet", "db",
+				map[string]interface{}{"replicas": int64(3), "updateStrategy": map[string]interface{}{
+					"type": "RollingUpdate", "rollingUpdate": map[string]interface{}{"partition": int64(2)},
+				}},
+				map[string]interface{}{"readyReplicas": int64(3), "updatedReplicas": int64(1),
+					"currentRevision": "db-1", "updateRevision": "db-2"}),
+			expected: healthCurrent,
+		},
+		{
+			name: "failed job",
+			resource: newReleaseResource("batch/v1", "Job", "migrate", nil,
+				map[string]interface{}{"conditions": []interface{}{
+					map[string]interface{}{"type": "Failed", "status": "True", "message": "BackoffLimitExceeded"},
+				}}),
+			expected: healthFailed,
+		},
+		{
+			name: "pending load balancer",
+			resource: newReleaseResource("v1", "Service", "web",
+				map[string]interface{}{"type": "LoadBalancer"}, map[string]interface{}{}),
+			expected: healthInProgress,
+		},
+		{
+			name: "unready custom resource",
+			resource: newReleaseResource("example.com/v1", "Database", "db", nil,
+				map[string]interface{}{"conditions": []interface{}{
+					map[string]interface{}{"type": "Ready", "status": "False", "message": "provisioning"},
+				}}),
+			expected: healthInProgress,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h, _ := resourceHealth(tc.resource)
+			assert.Equal(t, tc.expected, h)
+		})
+	}
+}
+
+func TestReadyCondition(t *testing.T) {
+	ready := newReleaseResource("v1", "ConfigMap", "cm", nil, nil)
+	c := readyCondition([]release.ReleaseResource{ready})
+	assert.Equal(t, types.StatusTrue, c.Status)
+	assert.Equal(t, types.ReasonResourcesReady, c.Reason)
+
+	missing := release.ReleaseResource{Kind: "Secret", Name: "creds"}
+	c = readyCondition([]release.ReleaseResource{ready, missing})
+	assert.Equal(t, types.StatusFalse, c.Status)
+	assert.Equal(t, types.ReasonResourcesNotReady, c.Reason)
+	assert.Equal(t, "1 of 2 resource(s) not ready: Secret/creds: not found", c.Message)
+
+	failed := newReleaseResource("v1", "Pod", "test", nil, map[string]interface{}{"phase": "Failed"})
+	c = readyCondition([]release.ReleaseResource{missing, failed})
+	assert.Equal(t, types.ReasonResourcesFailed, c.Reason)
+}
+
+func TestMapStatus(t *testing.T) {
+	resources := []release.ReleaseResource{
+		newReleaseResource("apps/v1", "Deployment", "other", nil, map[string]interface{}{"readyReplicas": int64(1)}),
+		newReleaseResource("apps/v1", "Deployment", "rel-web", nil, map[string]interface{}{"readyReplicas": int64(3)}),
+		newReleaseResource("v1", "Service", "rel-web", nil, map[string]interface{}{
+			"loadBalancer": map[string]interface{}{"ingress": []interface{}{
+				map[string]interface{}{"ip": "10.0.0.1"},
+				map[string]interface{}{"ip": "10.0.0.2"},
+			}},
+		}),
+	}
+	mappings := []watches.StatusMapping{
+		{Field: "web.readyReplicas", Kind: "Deployment", Name: "{{ .Release.Name }}-web", JSONPath: ".status.readyReplicas"},
+		{Field: "firstReadyReplicas", APIVersion: "apps/v1", Kind: "Deployment", JSONPath: "{.status.readyReplicas}"},
+		{Field: "web.ips", Kind: "Service", JSONPath: "{.status.loadBalancer.ingress[*].ip}"},
+		{Field: "missingField", Kind: "Service", JSONPath: "{.status.nothing}"},
+		{Field: "missingResource", Kind: "Ingress", JSONPath: "{.status}"},
+	}
+
+	compiled, err := compileStatusMappings(mappings)
+	assert.NoError(t, err)
+	mapped, err := mapStatus(compiled, resources, "rel", "ns")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"web": map[string]interface{}{
+			"readyReplicas": int64(3),
+			"ips":           []interface{}{"10.0.0.1", "10.0.0.2"},
+		},
+		"firstReadyReplicas": int64(1),
+	}, mapped)
+}
+
+func TestCompileStatusMappings(t *testing.T) {
+	_, err := compileStatusMappings([]watches.StatusMapping{
+		{Field: "invalid", Kind: "Deployment", Name: "{{ .Release.Name", JSONPath: ".status"},
+	})
+	assert.Error(t, err)
+	_, err = compileStatusMappings([]watches.StatusMapping{
+		{Field: "invalid", Kind: "Deployment", JSONPath: "{.status"},
+	})
+	assert.Error(t, err)
+}
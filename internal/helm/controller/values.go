@@ -0,0 +1,169 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	valuesSourceSecret    = "secret"
+	valuesSourceConfigMap = "configmap"
+
+	// defaultValuesKey is the key read from a referenced Secret or ConfigMap
+	// when a reference does not name one.
+	defaultValuesKey = "values.yaml"
+)
+
+// valuesReference is a reference to a key of a Secret or ConfigMap in a CR's
+// namespace containing chart values, parsed from the CR's values-from
+// annotation.
+type valuesReference struct {
+	Kind string
+	Name string
+	Key  string
+}
+
+func (ref valuesReference) String() string {
+	return fmt.Sprintf("%s/%s:%s", ref.Kind, ref.Name, ref.Key)
+}
+
+// parseValuesFrom parses the CR's values-from annotation, a comma-separated
+// list of references of the form "<secret|configmap>/<name>[:<key>]".
+func parseValuesFrom(o *unstructured.Unstructured) ([]valuesReference, error) {
+	anno := o.GetAnnotations()[helmValuesFromAnnotation]
+	if strings.TrimSpace(anno) == "" {
+		return nil, nil
+	}
+
+	var refs []valuesReference
+	for _, s := range strings.Split(anno, ",") {
+		s = strings.TrimSpace(s)
+		kindName := strings.SplitN(s, "/", 2)
+		if len(kindName) != 2 || kindName[1] == "" {
+			return nil, fmt.Errorf("invalid %s reference %q: must be of the form <secret|configmap>/<name>[:<key>]",
+				helmValuesFromAnnotation, s)
+		}
+		ref := valuesReference{Kind: strings.ToLower(kindName[0]), Name: kindName[1], Key: defaultValuesKey}
+		if i := strings.Index(ref.Name, ":"); i >= 0 {
+			ref.Name, ref.Key = ref.Name[:i], ref.Name[i+1:]
+			if ref.Name == "" || ref.Key == "" {
+				return nil, fmt.Errorf("invalid %s reference %q: name and key must not be empty",
+					helmValuesFromAnnotation, s)
+			}
+		}
+		if ref.Kind != valuesSourceSecret && ref.Kind != valuesSourceConfigMap {
+			return nil, fmt.Errorf("invalid %s reference %q: kind must be secret or configmap",
+				helmValuesFromAnnotation, s)
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// referencesObject returns true if the CR's values-from annotation references
+// the Secret or ConfigMap with the given name.
+func referencesObject(o *unstructured.Unstructured, kind, name string) bool {
+	refs, err := parseValuesFrom(o)
+	if err != nil {
+		return false
+	}
+	for _, ref := range refs {
+		if ref.Kind == kind && ref.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// referencedValues returns the chart values in the Secrets and ConfigMaps
+// referenced by the CR, in the order they are referenced.
+func (r HelmOperatorReconciler) referencedValues(ctx context.Context, o *unstructured.Unstructured) ([]map[string]interface{}, error) {
+	return readReferencedValues(ctx, r.Client, o, r.valuesSourceHook)
+}
+
+// readReferencedValues reads the chart values referenced by the CR with c. If
+// hook is not nil, it is called with the kind of each reference before it is
+// read.
+func readReferencedValues(ctx context.Context, c client.Reader, o *unstructured.Unstructured,
+	hook func(kind string) error) ([]map[string]interface{}, error) {
+	refs, err := parseValuesFrom(o)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]map[string]interface{}, 0, len(refs))
+	for _, ref := range refs {
+		if hook != nil {
+			if err := hook(ref.Kind); err != nil {
+				return nil, fmt.Errorf("failed to watch %s values: %w", ref.Kind, err)
+			}
+		}
+
+		data, err := valuesData(ctx, c, o.GetNamespace(), ref)
+		if err != nil {
+			return nil, err
+		}
+		v, err := chartutil.ReadValues(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse values in %s: %w", ref, err)
+		}
+		values = append(values, v.AsMap())
+	}
+	return values, nil
+}
+
+// valuesData returns the data of the key referenced by ref.
+func valuesData(ctx context.Context, c client.Reader, namespace string, ref valuesReference) ([]byte, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+	switch ref.Kind {
+	case valuesSourceSecret:
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, key, secret); err != nil {
+			return nil, fmt.Errorf("failed to get values from %s: %w", ref, err)
+		}
+		if data, ok := secret.Data[ref.Key]; ok {
+			return data, nil
+		}
+	case valuesSourceConfigMap:
+		cm := &corev1.ConfigMap{}
+		if err := c.Get(ctx, key, cm); err != nil {
+			return nil, fmt.Errorf("failed to get values from %s: %w", ref, err)
+		}
+		if data, ok := cm.Data[ref.Key]; ok {
+			return []byte(data), nil
+		}
+		if data, ok := cm.BinaryData[ref.Key]; ok {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to get values from %s: key not found", ref)
+}
+
+// valuesSourceObject returns an empty object of the kind of a values source.
+func valuesSourceObject(kind string) client.Object {
+	if kind == valuesSourceSecret {
+		return &corev1.Secret{}
+	}
+	return &corev1.ConfigMap{}
+}
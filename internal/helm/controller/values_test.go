@@ -0,0 +1,132 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newValuesFromCR(valuesFrom string) *unstructured.Unstructured {
+	o := &unstructured.Unstructured{}
+	o.SetNamespace("ns")
+	o.SetName("cr")
+	if valuesFrom != "" {
+		o.SetAnnotations(map[string]string{helmValuesFromAnnotation: valuesFrom})
+	}
+	return o
+}
+
+func TestParseValuesFrom(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		expected  []valuesReference
+		expectErr bool
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name:  "default key",
+			input: "secret/creds",
+			expected: []valuesReference{
+				{Kind: "secret", Name: "creds", Key: "values.yaml"},
+			},
+		},
+		{
+			name:  "multiple references with keys",
+			input: "ConfigMap/common:base.yaml, secret/creds:db.yaml",
+			expected: []valuesReference{
+				{Kind: "configmap", Name: "common", Key: "base.yaml"},
+				{Kind: "secret", Name: "creds", Key: "db.yaml"},
+			},
+		},
+		{
+			name:      "missing name",
+			input:     "secret/",
+			expectErr: true,
+		},
+		{
+			name:      "missing key",
+			input:     "secret/creds:",
+			expectErr: true,
+		},
+		{
+			name:      "unsupported kind",
+			input:     "deployment/creds",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			refs, err := parseValuesFrom(newValuesFromCR(tc.input))
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, refs)
+		})
+	}
+}
+
+func TestReferencesObject(t *testing.T) {
+	o := newValuesFromCR("configmap/common, secret/creds:db.yaml")
+	assert.True(t, referencesObject(o, "secret", "creds"))
+	assert.True(t, referencesObject(o, "configmap", "common"))
+	assert.False(t, referencesObject(o, "configmap", "creds"))
+	assert.False(t, referencesObject(newValuesFromCR(""), "secret", "creds"))
+}
+
+func TestReferencedValues(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "creds"},
+		Data:       map[string][]byte{"db.yaml": []byte("db:\n  password: s3cr3t\n")},
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "common"},
+		Data:       map[string]string{"values.yaml": "db:\n  host: db.example.com\n"},
+	}
+	var hooked []string
+	r := HelmOperatorReconciler{
+		Client: fake.NewClientBuilder().WithObjects(secret, cm).Build(),
+		valuesSourceHook: func(kind string) error {
+			hooked = append(hooked, kind)
+			return nil
+		},
+	}
+
+	values, err := r.referencedValues(context.TODO(), newValuesFromCR("configmap/common,secret/creds:db.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{
+		{"db": map[string]interface{}{"host": "db.example.com"}},
+		{"db": map[string]interface{}{"password": "s3cr3t"}},
+	}, values)
+	assert.Equal(t, []string{"configmap", "secret"}, hooked)
+
+	_, err = r.referencedValues(context.TODO(), newValuesFromCR("secret/creds"))
+	assert.Error(t, err, "missing key")
+
+	_, err = r.referencedValues(context.TODO(), newValuesFromCR("secret/missing"))
+	assert.Error(t, err, "missing secret")
+}
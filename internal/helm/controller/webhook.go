@@ -0,0 +1,107 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/operator-framework/operator-sdk/internal/helm/release"
+)
+
+// ValidatingWebhookPath returns the path the validating webhook of a GVK is
+// served at, following the convention of kubebuilder-generated webhooks.
+func ValidatingWebhookPath(gvk schema.GroupVersionKind) string {
+	return fmt.Sprintf("/validate-%s-%s-%s", strings.ReplaceAll(gvk.Group, ".", "-"), gvk.Version,
+		strings.ToLower(gvk.Kind))
+}
+
+// crValidator is an admission handler that rejects CRs whose release would
+// fail to install or upgrade because its values do not match the chart's
+// values schema, or because its release name collides with another release.
+type crValidator struct {
+	managerFactory release.ManagerFactory
+	// components are validated instead of managerFactory for CRs composed
+	// of several charts.
+	components     []Component
+	overrideValues map[string]string
+	// reader reads the Secrets and ConfigMaps referenced by CRs' values-from
+	// annotations.
+	reader client.Reader
+}
+
+var _ admission.Handler = &crValidator{}
+
+func (v *crValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	o := &unstructured.Unstructured{}
+	if err := o.UnmarshalJSON(req.Object.Raw); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	// Never block the removal of finalizers, or other updates of CRs that
+	// are being deleted.
+	if o.GetDeletionTimestamp() != nil {
+		return admission.Allowed("")
+	}
+	if req.Operation == admissionv1.Update {
+		old := &unstructured.Unstructured{}
+		if err := old.UnmarshalJSON(req.OldObject.Raw); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		// Only validate changes to the release's values, so that the
+		// operator's own updates, ex. of finalizers, are never rejected.
+		if !valuesChanged(old, o) {
+			return admission.Allowed("")
+		}
+	}
+
+	referencedValues, err := readReferencedValues(ctx, v.reader, o, nil)
+	if err != nil {
+		// The referenced values may be created after the CR. Their errors
+		// are reported in the CR's status on reconcile.
+		return admission.Allowed("").WithWarnings(fmt.Sprintf("values were not validated: %v", err))
+	}
+	if len(v.components) == 0 {
+		if err := v.managerFactory.Validate(o, v.overrideValues, referencedValues...); err != nil {
+			return admission.Denied(err.Error())
+		}
+		return admission.Allowed("")
+	}
+	for _, c := range v.components {
+		if err := c.ManagerFactory.Validate(o, v.overrideValues, referencedValues...); err != nil {
+			return admission.Denied(fmt.Sprintf("chart %q: %v", c.Name, err))
+		}
+	}
+	return admission.Allowed("")
+}
+
+// valuesChanged returns true if the spec or the values-from annotation of a CR
+// changed.
+func valuesChanged(old, o *unstructured.Unstructured) bool {
+	return !equality.Semantic.DeepEqual(old.Object["spec"], o.Object["spec"]) ||
+		old.GetAnnotations()[helmValuesFromAnnotation] != o.GetAnnotations()[helmValuesFromAnnotation]
+}
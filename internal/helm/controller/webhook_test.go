@@ -0,0 +1,134 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/operator-framework/operator-sdk/internal/helm/release"
+)
+
+// validatingManagerFactory is a release.ManagerFactory that rejects CRs whose
+// spec sets invalid to true.
+type validatingManagerFactory struct {
+	release.ManagerFactory
+}
+
+func (validatingManagerFactory) Validate(cr *unstructured.Unstructured, _ map[string]string,
+	referencedValues ...map[string]interface{}) error {
+	invalid, _, _ := unstructured.NestedBool(cr.Object, "spec", "invalid")
+	for _, v := range referencedValues {
+		if v["invalid"] == true {
+			invalid = true
+		}
+	}
+	if invalid {
+		return errors.New("invalid values")
+	}
+	return nil
+}
+
+func newWebhookRequest(t *testing.T, op admissionv1.Operation, o, old *unstructured.Unstructured) admission.Request {
+	req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{Operation: op}}
+	var err error
+	if o != nil {
+		req.Object.Raw, err = o.MarshalJSON()
+		assert.NoError(t, err)
+	}
+	if old != nil {
+		req.OldObject.Raw, err = old.MarshalJSON()
+		assert.NoError(t, err)
+	}
+	return req
+}
+
+func newWebhookCR(invalid bool) *unstructured.Unstructured {
+	o := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Nginx",
+		"spec":       map[string]interface{}{"invalid": invalid},
+	}}
+	o.SetNamespace("ns")
+	o.SetName("web")
+	return o
+}
+
+func TestValidatingWebhookPath(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Memcached"}
+	assert.Equal(t, "/validate-cache-example-com-v1alpha1-memcached", ValidatingWebhookPath(gvk))
+}
+
+func TestCRValidatorHandle(t *testing.T) {
+	v := &crValidator{
+		managerFactory: validatingManagerFactory{},
+		reader:         fake.NewClientBuilder().Build(),
+	}
+	ctx := context.TODO()
+
+	resp := v.Handle(ctx, newWebhookRequest(t, admissionv1.Create, newWebhookCR(false), nil))
+	assert.True(t, resp.Allowed)
+
+	resp = v.Handle(ctx, newWebhookRequest(t, admissionv1.Create, newWebhookCR(true), nil))
+	assert.False(t, resp.Allowed)
+	assert.EqualValues(t, "invalid values", resp.Result.Reason)
+
+	resp = v.Handle(ctx, newWebhookRequest(t, admissionv1.Update, newWebhookCR(true), newWebhookCR(false)))
+	assert.False(t, resp.Allowed, "spec changed")
+
+	unchanged := newWebhookCR(true)
+	unchanged.SetFinalizers([]string{uninstallFinalizer})
+	resp = v.Handle(ctx, newWebhookRequest(t, admissionv1.Update, unchanged, newWebhookCR(true)))
+	assert.True(t, resp.Allowed, "spec unchanged")
+
+	deleted := newWebhookCR(true)
+	now := metav1.Now()
+	deleted.SetDeletionTimestamp(&now)
+	resp = v.Handle(ctx, newWebhookRequest(t, admissionv1.Update, deleted, newWebhookCR(false)))
+	assert.True(t, resp.Allowed, "being deleted")
+
+	missingValues := newWebhookCR(false)
+	missingValues.SetAnnotations(map[string]string{helmValuesFromAnnotation: "secret/missing"})
+	resp = v.Handle(ctx, newWebhookRequest(t, admissionv1.Create, missingValues, nil))
+	assert.True(t, resp.Allowed, "missing referenced values")
+	assert.Len(t, resp.Warnings, 1)
+}
+
+func TestCRValidatorHandleComponents(t *testing.T) {
+	v := &crValidator{
+		components: []Component{
+			{Name: "db", ManagerFactory: validatingManagerFactory{}},
+			{Name: "web", ManagerFactory: validatingManagerFactory{}},
+		},
+		reader: fake.NewClientBuilder().Build(),
+	}
+	ctx := context.TODO()
+
+	resp := v.Handle(ctx, newWebhookRequest(t, admissionv1.Create, newWebhookCR(false), nil))
+	assert.True(t, resp.Allowed)
+
+	resp = v.Handle(ctx, newWebhookRequest(t, admissionv1.Create, newWebhookCR(true), nil))
+	assert.False(t, resp.Allowed)
+	assert.EqualValues(t, `chart "db": invalid values`, resp.Result.Reason)
+}
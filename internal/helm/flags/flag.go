@@ -15,6 +15,8 @@
 package flags
 
 import (
+	"os"
+	"path/filepath"
 	"runtime"
 	"time"
 
@@ -33,6 +35,12 @@ type Flags struct {
 	LeaderElectionNamespace string
 	MaxConcurrentReconciles int
 	ProbeAddr               string
+	ChartCacheDir           string
+
+	// Release storage options, which may be overridden per watch.
+	ReleaseStorageDriver              string
+	ReleaseStorageNamespace           string
+	ReleaseStorageSQLConnectionString string
 
 	// Path to a controller-runtime componentconfig file.
 	// If this is empty, use default values.
@@ -53,6 +61,27 @@ func (f *Flags) AddTo(flagSet *pflag.FlagSet) {
 		"./watches.yaml",
 		"Path to the watches file to use",
 	)
+	flagSet.StringVar(&f.ChartCacheDir,
+		"chart-cache-dir",
+		filepath.Join(os.TempDir(), "helm-operator", "charts"),
+		"Directory in which charts fetched from a watch's chartSource are cached",
+	)
+	flagSet.StringVar(&f.ReleaseStorageDriver,
+		"release-storage-driver",
+		"secret",
+		"Driver used to store release history. One of: secret, configmap, memory, sql",
+	)
+	flagSet.StringVar(&f.ReleaseStorageNamespace,
+		"release-storage-namespace",
+		"",
+		"Namespace in which release history is stored. If empty, release history is stored"+
+			" in the namespace of each custom resource",
+	)
+	flagSet.StringVar(&f.ReleaseStorageSQLConnectionString,
+		"release-storage-sql-connection-string",
+		"",
+		"Connection string of the PostgreSQL database used by the sql release storage driver",
+	)
 
 	// Controller flags.
 	flagSet.DurationVar(&f.ReconcilePeriod,
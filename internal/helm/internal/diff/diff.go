@@ -24,6 +24,16 @@ import (
 
 // Generate generates a diff between a and b, in color.
 func Generate(a, b string) string {
+	return generate(a, b, true)
+}
+
+// GeneratePlain generates a diff between a and b without color, suitable
+// for storing in a resource's status.
+func GeneratePlain(a, b string) string {
+	return generate(a, b, false)
+}
+
+func generate(a, b string, color bool) string {
 	dmp := diffmatchpatch.New()
 
 	wSrc, wDst, warray := dmp.DiffLinesToRunes(a, b)
@@ -35,13 +45,21 @@ func Generate(a, b string) string {
 
 		switch diff.Type {
 		case diffmatchpatch.DiffInsert:
-			_, _ = buff.WriteString("\x1b[32m")
+			if color {
+				_, _ = buff.WriteString("\x1b[32m")
+			}
 			_, _ = buff.WriteString(prefixLines(text, "+"))
-			_, _ = buff.WriteString("\x1b[0m")
+			if color {
+				_, _ = buff.WriteString("\x1b[0m")
+			}
 		case diffmatchpatch.DiffDelete:
-			_, _ = buff.WriteString("\x1b[31m")
+			if color {
+				_, _ = buff.WriteString("\x1b[31m")
+			}
 			_, _ = buff.WriteString(prefixLines(text, "-"))
-			_, _ = buff.WriteString("\x1b[0m")
+			if color {
+				_, _ = buff.WriteString("\x1b[0m")
+			}
 		case diffmatchpatch.DiffEqual:
 			_, _ = buff.WriteString(prefixLines(text, " "))
 		}
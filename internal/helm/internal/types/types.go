@@ -16,6 +16,8 @@ package types
 
 import (
 	"encoding/json"
+	"reflect"
+	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -55,11 +57,67 @@ type HelmAppRelease struct {
 	Manifest string `json:"manifest,omitempty"`
 }
 
+// HelmAppReleaseRevision describes a stored revision of a release.
+type HelmAppReleaseRevision struct {
+	Revision     int         `json:"revision"`
+	Status       string      `json:"status,omitempty"`
+	ChartVersion string      `json:"chartVersion,omitempty"`
+	Description  string      `json:"description,omitempty"`
+	Updated      metav1.Time `json:"updated,omitempty"`
+}
+
+// HelmAppPendingRelease describes the changes a dry-run reconcile would
+// apply to a release.
+type HelmAppPendingRelease struct {
+	Name string `json:"name,omitempty"`
+	Diff string `json:"diff,omitempty"`
+}
+
+// HelmAppDrift describes release resources that were found to differ from the
+// release manifest.
+type HelmAppDrift struct {
+	// Resources are the resources that differed on the last reconcile.
+	Resources []HelmAppDriftedResource `json:"resources,omitempty"`
+	// Count is the number of times drift of different resources was detected.
+	Count int64 `json:"count,omitempty"`
+	// LastDriftTime is the last time drift of different resources was detected.
+	LastDriftTime *metav1.Time `json:"lastDriftTime,omitempty"`
+}
+
+// HelmAppDriftedResource describes a release resource that differs from the
+// release manifest.
+type HelmAppDriftedResource struct {
+	APIVersion string   `json:"apiVersion"`
+	Kind       string   `json:"kind"`
+	Namespace  string   `json:"namespace,omitempty"`
+	Name       string   `json:"name"`
+	Missing    bool     `json:"missing,omitempty"`
+	Paths      []string `json:"paths,omitempty"`
+}
+
+// HelmAppComponentRelease describes the release of one of the charts a CR is
+// composed of.
+type HelmAppComponentRelease struct {
+	// Name is the name of the chart in the watch.
+	Name         string             `json:"name"`
+	ReleaseName  string             `json:"releaseName,omitempty"`
+	Chart        string             `json:"chart,omitempty"`
+	ChartVersion string             `json:"chartVersion,omitempty"`
+	Revision     int                `json:"revision,omitempty"`
+	Conditions   []HelmAppCondition `json:"conditions,omitempty"`
+
+	// History contains the stored revisions of the release, newest first.
+	History []HelmAppReleaseRevision `json:"history,omitempty"`
+}
+
 const (
 	ConditionInitialized    HelmAppConditionType = "Initialized"
 	ConditionDeployed       HelmAppConditionType = "Deployed"
 	ConditionReleaseFailed  HelmAppConditionType = "ReleaseFailed"
 	ConditionIrreconcilable HelmAppConditionType = "Irreconcilable"
+	ConditionPendingChanges HelmAppConditionType = "PendingChanges"
+	ConditionDrifted        HelmAppConditionType = "Drifted"
+	ConditionReady          HelmAppConditionType = "Ready"
 
 	StatusTrue    ConditionStatus = "True"
 	StatusFalse   ConditionStatus = "False"
@@ -72,11 +130,72 @@ const (
 	ReasonUpgradeError        HelmAppConditionReason = "UpgradeError"
 	ReasonReconcileError      HelmAppConditionReason = "ReconcileError"
 	ReasonUninstallError      HelmAppConditionReason = "UninstallError"
+	ReasonRollbackSuccessful  HelmAppConditionReason = "RollbackSuccessful"
+	ReasonRollbackError       HelmAppConditionReason = "RollbackError"
+	ReasonDryRunInstall       HelmAppConditionReason = "DryRunInstall"
+	ReasonDryRunUpgrade       HelmAppConditionReason = "DryRunUpgrade"
+	ReasonDryRunNoChanges     HelmAppConditionReason = "DryRunNoChanges"
+	ReasonDryRunError         HelmAppConditionReason = "DryRunError"
+	ReasonValuesFromError     HelmAppConditionReason = "ValuesFromError"
+	ReasonDriftHealed         HelmAppConditionReason = "DriftHealed"
+	ReasonDriftDetected       HelmAppConditionReason = "DriftDetected"
+	ReasonNoDrift             HelmAppConditionReason = "NoDrift"
+	ReasonResourcesReady      HelmAppConditionReason = "ResourcesReady"
+	ReasonResourcesNotReady   HelmAppConditionReason = "ResourcesNotReady"
+	ReasonResourcesFailed     HelmAppConditionReason = "ResourcesFailed"
+	ReasonResourceStatusError HelmAppConditionReason = "ResourceStatusError"
+	ReasonWaitingForRelease   HelmAppConditionReason = "WaitingForRelease"
+
+	// ReasonUnsupportedAnnotation is the reason a CR composed of several
+	// charts is irreconcilable when it sets an annotation that applies to a
+	// single release.
+	ReasonUnsupportedAnnotation HelmAppConditionReason = "UnsupportedAnnotation"
 )
 
 type HelmAppStatus struct {
-	Conditions      []HelmAppCondition `json:"conditions"`
-	DeployedRelease *HelmAppRelease    `json:"deployedRelease,omitempty"`
+	Conditions      []HelmAppCondition     `json:"conditions"`
+	DeployedRelease *HelmAppRelease        `json:"deployedRelease,omitempty"`
+	PendingRelease  *HelmAppPendingRelease `json:"pendingRelease,omitempty"`
+
+	// History contains the stored revisions of the release, newest first.
+	History []HelmAppReleaseRevision `json:"history,omitempty"`
+	// RollbackRevision is the revision the release was last rolled back to
+	// at the request of the custom resource's rollback annotation.
+	RollbackRevision int `json:"rollbackRevision,omitempty"`
+	// FailedUpgradeDigest is the digest of the chart and values of the last
+	// failed upgrade, which is not retried until they change when the watch
+	// sets rollbackOnFailure.
+	FailedUpgradeDigest string `json:"failedUpgradeDigest,omitempty"`
+	// Drift describes release resources that were found to differ from the
+	// release manifest.
+	Drift *HelmAppDrift `json:"drift,omitempty"`
+	// Releases describes the release of each chart of a CR composed of
+	// several charts, in the order they are installed.
+	Releases []HelmAppComponentRelease `json:"releases,omitempty"`
+
+	// Mapped contains the status fields copied from release resources by a
+	// watch's status mappings. They are stored alongside the fields above.
+	Mapped map[string]interface{} `json:"-"`
+}
+
+// reservedStatusFields are the JSON names of the HelmAppStatus fields.
+var reservedStatusFields = func() map[string]struct{} {
+	fields := map[string]struct{}{}
+	t := reflect.TypeOf(HelmAppStatus{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = struct{}{}
+		}
+	}
+	return fields
+}()
+
+// IsReservedStatusField returns true if name is the name of a status field set
+// by the operator, which status mappings must not overwrite.
+func IsReservedStatusField(name string) bool {
+	_, ok := reservedStatusFields[name]
+	return ok
 }
 
 func (s *HelmAppStatus) ToMap() (map[string]interface{}, error) {
@@ -88,6 +207,11 @@ func (s *HelmAppStatus) ToMap() (map[string]interface{}, error) {
 	if err := json.Unmarshal(jsonObj, &out); err != nil {
 		return nil, err
 	}
+	for k, v := range s.Mapped {
+		if !IsReservedStatusField(k) {
+			out[k] = v
+		}
+	}
 	return out, nil
 }
 
@@ -95,24 +219,28 @@ func (s *HelmAppStatus) ToMap() (map[string]interface{}, error) {
 // exists, it will be replaced. SetCondition does not update the resource in
 // the cluster.
 func (s *HelmAppStatus) SetCondition(condition HelmAppCondition) *HelmAppStatus {
+	s.Conditions = setCondition(s.Conditions, condition)
+	return s
+}
+
+func setCondition(conditions []HelmAppCondition, condition HelmAppCondition) []HelmAppCondition {
 	now := metav1.Now()
-	for i := range s.Conditions {
-		if s.Conditions[i].Type == condition.Type {
-			if s.Conditions[i].Status != condition.Status {
+	for i := range conditions {
+		if conditions[i].Type == condition.Type {
+			if conditions[i].Status != condition.Status {
 				condition.LastTransitionTime = now
 			} else {
-				condition.LastTransitionTime = s.Conditions[i].LastTransitionTime
+				condition.LastTransitionTime = conditions[i].LastTransitionTime
 			}
-			s.Conditions[i] = condition
-			return s
+			conditions[i] = condition
+			return conditions
 		}
 	}
 
 	// If the condition does not exist,
 	// initialize the lastTransitionTime
 	condition.LastTransitionTime = now
-	s.Conditions = append(s.Conditions, condition)
-	return s
+	return append(conditions, condition)
 }
 
 // RemoveCondition removes the condition with the passed condition type from
@@ -129,6 +257,35 @@ func (s *HelmAppStatus) RemoveCondition(conditionType HelmAppConditionType) *Hel
 	return s
 }
 
+// ComponentRelease returns the status of the release of the named chart of a
+// CR composed of several charts, or nil if the status has none.
+func (s *HelmAppStatus) ComponentRelease(name string) *HelmAppComponentRelease {
+	for i := range s.Releases {
+		if s.Releases[i].Name == name {
+			return &s.Releases[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition sets a condition on the release status. If the condition
+// already exists, it will be replaced.
+func (r *HelmAppComponentRelease) SetCondition(condition HelmAppCondition) *HelmAppComponentRelease {
+	r.Conditions = setCondition(r.Conditions, condition)
+	return r
+}
+
+// Condition returns the condition of the release status with the passed
+// condition type, or nil if it is not present.
+func (r *HelmAppComponentRelease) Condition(conditionType HelmAppConditionType) *HelmAppCondition {
+	for i := range r.Conditions {
+		if r.Conditions[i].Type == conditionType {
+			return &r.Conditions[i]
+		}
+	}
+	return nil
+}
+
 // StatusFor safely returns a typed status block from a custom resource.
 func StatusFor(cr *unstructured.Unstructured) *HelmAppStatus {
 	switch s := cr.Object["status"].(type) {
@@ -139,6 +296,15 @@ func StatusFor(cr *unstructured.Unstructured) *HelmAppStatus {
 		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(s, &status); err != nil {
 			return &HelmAppStatus{}
 		}
+		for k, v := range s {
+			if IsReservedStatusField(k) {
+				continue
+			}
+			if status.Mapped == nil {
+				status.Mapped = map[string]interface{}{}
+			}
+			status.Mapped[k] = v
+		}
 		return status
 	default:
 		return &HelmAppStatus{}
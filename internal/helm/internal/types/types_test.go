@@ -86,6 +86,31 @@ func TestStatusForFilledRaw(t *testing.T) {
 	assert.Equal(t, "SomeRelease", status.DeployedRelease.Name)
 }
 
+func TestStatusMappedFields(t *testing.T) {
+	status := newTestStatus()
+	status.Mapped = map[string]interface{}{
+		"readyReplicas":   int64(2),
+		"deployedRelease": "ignored",
+	}
+	m, err := status.ToMap()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), m["readyReplicas"])
+	assert.Equal(t, map[string]interface{}{"name": "SomeRelease"}, m["deployedRelease"])
+
+	resource := newTestResource()
+	resource.Object["status"] = m
+	actual := StatusFor(resource)
+	assert.Equal(t, map[string]interface{}{"readyReplicas": int64(2)}, actual.Mapped)
+	assert.Equal(t, "SomeRelease", actual.DeployedRelease.Name)
+}
+
+func TestIsReservedStatusField(t *testing.T) {
+	assert.True(t, IsReservedStatusField("conditions"))
+	assert.True(t, IsReservedStatusField("drift"))
+	assert.False(t, IsReservedStatusField("Mapped"))
+	assert.False(t, IsReservedStatusField("readyReplicas"))
+}
+
 func newTestResource() *unstructured.Unstructured {
 	return &unstructured.Unstructured{
 		Object: map[string]interface{}{
@@ -15,7 +15,12 @@
 package metrics
 
 import (
+	"fmt"
+	"sync"
+
 	"github.com/prometheus/client_golang/prometheus"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	sdkVersion "github.com/operator-framework/operator-sdk/internal/version"
 )
@@ -36,9 +41,211 @@ var (
 			},
 		},
 	)
+
+	reconcileResults = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: subsystem,
+			Name:      "reconcile_result",
+			Help:      "Gauge of reconciles and their results.",
+		},
+		[]string{
+			"GVK",
+			"result",
+		})
+
+	reconciles = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: subsystem,
+			Name:      "reconciles",
+			Help:      "How long in seconds a reconcile takes.",
+		},
+		[]string{
+			"GVK",
+		})
+
+	releaseActions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: subsystem,
+			Name:      "release_actions_total",
+			Help:      "Number of release installs, upgrades, rollbacks and uninstalls, and their results.",
+		},
+		[]string{
+			"GVK",
+			"action",
+			"result",
+		})
+
+	releaseRevisions = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: subsystem,
+			Name:      "release_revision",
+			Help:      "Deployed revision of the release of each custom resource.",
+		},
+		[]string{
+			"GVK",
+			"namespace",
+			"name",
+		})
+
+	releaseCharts = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: subsystem,
+			Name:      "release_chart_info",
+			Help:      "Chart and chart version of the deployed release of each custom resource.",
+		},
+		[]string{
+			"GVK",
+			"namespace",
+			"name",
+			"chart",
+			"version",
+		})
+
+	releaseDiffSizes = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: subsystem,
+			Name:      "release_diff_size_bytes",
+			Help:      "Size in bytes of the manifest diff of release upgrades.",
+			Buckets:   prometheus.ExponentialBuckets(256, 4, 8),
+		},
+		[]string{
+			"GVK",
+		})
+
+	driftedResources = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: subsystem,
+			Name:      "release_drifted_resources",
+			Help:      "Number of release resources that differed from the release manifest on the last reconcile.",
+		},
+		[]string{
+			"GVK",
+			"namespace",
+			"name",
+		})
+
+	driftDetections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: subsystem,
+			Name:      "release_drift_detections_total",
+			Help:      "Number of reconciles on which release resources differed from the release manifest.",
+		},
+		[]string{
+			"GVK",
+			"action",
+		})
+
+	// releaseChartLabels holds the chart labels of each release's chart
+	// info series, so that the series can be removed when the chart or its
+	// version changes.
+	releaseChartLabels   = map[[3]string][2]string{}
+	releaseChartLabelsMu sync.Mutex
 )
 
+func init() {
+	metrics.Registry.MustRegister(reconcileResults)
+	metrics.Registry.MustRegister(reconciles)
+	metrics.Registry.MustRegister(releaseActions)
+	metrics.Registry.MustRegister(releaseRevisions)
+	metrics.Registry.MustRegister(releaseCharts)
+	metrics.Registry.MustRegister(releaseDiffSizes)
+	metrics.Registry.MustRegister(driftedResources)
+	metrics.Registry.MustRegister(driftDetections)
+}
+
+// We will never want to panic our app because of metric saving.
+// Therefore, we will recover our panics here and error log them
+// for later diagnosis but will never fail the app.
+func recoverMetricPanic() {
+	if r := recover(); r != nil {
+		logf.Log.WithName("metrics").Error(fmt.Errorf("%v", r),
+			"Recovering from metric function")
+	}
+}
+
 func RegisterBuildInfo(r prometheus.Registerer) {
 	buildInfo.Set(1)
 	r.MustRegister(buildInfo)
 }
+
+func ReconcileSucceeded(gvk string) {
+	defer recoverMetricPanic()
+	reconcileResults.WithLabelValues(gvk, "succeeded").Inc()
+}
+
+func ReconcileFailed(gvk string) {
+	defer recoverMetricPanic()
+	reconcileResults.WithLabelValues(gvk, "failed").Inc()
+}
+
+func ReconcileTimer(gvk string) *prometheus.Timer {
+	defer recoverMetricPanic()
+	return prometheus.NewTimer(prometheus.ObserverFunc(func(duration float64) {
+		reconciles.WithLabelValues(gvk).Observe(duration)
+	}))
+}
+
+// ReleaseAction counts a release action, ex. "install" or "upgrade", and
+// whether it succeeded or failed.
+func ReleaseAction(gvk, action string, succeeded bool) {
+	defer recoverMetricPanic()
+	result := "failed"
+	if succeeded {
+		result = "succeeded"
+	}
+	releaseActions.WithLabelValues(gvk, action, result).Inc()
+}
+
+// ReleaseDeployed records the deployed revision and chart of the release of a
+// custom resource.
+func ReleaseDeployed(gvk, namespace, name string, revision int, chart, version string) {
+	defer recoverMetricPanic()
+	releaseRevisions.WithLabelValues(gvk, namespace, name).Set(float64(revision))
+
+	key := [3]string{gvk, namespace, name}
+	releaseChartLabelsMu.Lock()
+	defer releaseChartLabelsMu.Unlock()
+	if prev, ok := releaseChartLabels[key]; ok && prev != [2]string{chart, version} {
+		releaseCharts.DeleteLabelValues(gvk, namespace, name, prev[0], prev[1])
+	}
+	releaseChartLabels[key] = [2]string{chart, version}
+	releaseCharts.WithLabelValues(gvk, namespace, name, chart, version).Set(1)
+}
+
+// ReleaseDiffSize records the size of the manifest diff of a release upgrade.
+func ReleaseDiffSize(gvk string, size int) {
+	defer recoverMetricPanic()
+	releaseDiffSizes.WithLabelValues(gvk).Observe(float64(size))
+}
+
+// ReleaseDrift records the number of drifted resources of a release. If any
+// resources drifted, it also counts a drift detection, which was either
+// "healed" or "reported".
+func ReleaseDrift(gvk, namespace, name string, drifted int, healed bool) {
+	defer recoverMetricPanic()
+	driftedResources.WithLabelValues(gvk, namespace, name).Set(float64(drifted))
+	if drifted == 0 {
+		return
+	}
+	action := "reported"
+	if healed {
+		action = "healed"
+	}
+	driftDetections.WithLabelValues(gvk, action).Inc()
+}
+
+// DeleteRelease removes the metrics of the uninstalled release of a custom
+// resource.
+func DeleteRelease(gvk, namespace, name string) {
+	defer recoverMetricPanic()
+	releaseRevisions.DeleteLabelValues(gvk, namespace, name)
+	driftedResources.DeleteLabelValues(gvk, namespace, name)
+
+	key := [3]string{gvk, namespace, name}
+	releaseChartLabelsMu.Lock()
+	defer releaseChartLabelsMu.Unlock()
+	if prev, ok := releaseChartLabels[key]; ok {
+		releaseCharts.DeleteLabelValues(gvk, namespace, name, prev[0], prev[1])
+		delete(releaseChartLabels, key)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseDeployed(t *testing.T) {
+	const gvk = "example.com/v1, Kind=Nginx"
+	ReleaseDeployed(gvk, "ns", "cr", 1, "nginx", "0.1.0")
+	ReleaseDeployed(gvk, "ns", "cr", 2, "nginx", "0.2.0")
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(releaseRevisions.WithLabelValues(gvk, "ns", "cr")))
+	expected := `
+# HELP helm_operator_release_chart_info Chart and chart version of the deployed release of each custom resource.
+# TYPE helm_operator_release_chart_info gauge
+helm_operator_release_chart_info{GVK="example.com/v1, Kind=Nginx",chart="nginx",name="cr",namespace="ns",version="0.2.0"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(releaseCharts, strings.NewReader(expected)))
+
+	DeleteRelease(gvk, "ns", "cr")
+	assert.NoError(t, testutil.CollectAndCompare(releaseCharts, strings.NewReader("")))
+	assert.NoError(t, testutil.CollectAndCompare(releaseRevisions, strings.NewReader("")))
+}
+
+func TestReleaseAction(t *testing.T) {
+	const gvk = "example.com/v1, Kind=Memcached"
+	ReleaseAction(gvk, "install", true)
+	ReleaseAction(gvk, "upgrade", false)
+	ReleaseAction(gvk, "upgrade", false)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(releaseActions.WithLabelValues(gvk, "install", "succeeded")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(releaseActions.WithLabelValues(gvk, "upgrade", "failed")))
+}
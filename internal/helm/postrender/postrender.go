@@ -0,0 +1,154 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package postrender builds Helm post-renderers from a watch's post-renderer
+// configuration, which modify the manifests rendered from a chart before
+// they are applied.
+package postrender
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/postrender"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+
+	"github.com/operator-framework/operator-sdk/internal/helm/watches"
+)
+
+const (
+	// manifestsFile is the file containing the rendered manifests in a
+	// kustomization's in-memory file system.
+	manifestsFile = "manifests.yaml"
+	// kustomizationDir is the directory of a kustomization's in-memory file
+	// system containing the kustomization and rendered manifests.
+	kustomizationDir = "/post-render"
+)
+
+// New returns a post-renderer that applies each of prs in order. It returns
+// nil if prs is empty.
+func New(prs []watches.PostRenderer) (postrender.PostRenderer, error) {
+	var chain chainRenderer
+	for i, pr := range prs {
+		r, err := newRenderer(pr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid post-renderer %d: %w", i, err)
+		}
+		chain = append(chain, r)
+	}
+	if len(chain) == 0 {
+		return nil, nil
+	}
+	return chain, nil
+}
+
+// Digest returns a digest of the configuration of prs, which changes when the
+// post-renderers would modify manifests differently. It returns an empty
+// string if prs is empty. Changes to the executables of exec post-renderers
+// are not detected.
+func Digest(prs []watches.PostRenderer) (string, error) {
+	if len(prs) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(prs)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal post-renderers: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func newRenderer(pr watches.PostRenderer) (postrender.PostRenderer, error) {
+	switch {
+	case pr.Exec != "":
+		return postrender.NewExec(pr.Exec)
+	case pr.Patches != nil:
+		patches := make([]interface{}, 0, len(pr.Patches))
+		for _, p := range pr.Patches {
+			patch := map[string]interface{}{"patch": p.Patch}
+			if p.Target != nil {
+				patch["target"] = p.Target
+			}
+			patches = append(patches, patch)
+		}
+		return newKustomizeRenderer(map[string]interface{}{"patches": patches})
+	default:
+		return newKustomizeRenderer(pr.Kustomize)
+	}
+}
+
+// chainRenderer applies post-renderers in order.
+type chainRenderer []postrender.PostRenderer
+
+func (c chainRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	out := renderedManifests
+	for _, r := range c {
+		var err error
+		if out, err = r.Run(out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// kustomizeRenderer applies a kustomization to rendered manifests.
+type kustomizeRenderer struct {
+	kustomization []byte
+}
+
+func newKustomizeRenderer(kustomization map[string]interface{}) (*kustomizeRenderer, error) {
+	k := map[string]interface{}{}
+	for key, v := range kustomization {
+		k[key] = v
+	}
+	k["resources"] = []string{manifestsFile}
+	data, err := yaml.Marshal(k)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal kustomization: %w", err)
+	}
+	// Catch invalid kustomizations before any chart is rendered.
+	if err := yaml.UnmarshalStrict(data, &types.Kustomization{}); err != nil {
+		return nil, fmt.Errorf("invalid kustomization: %w", err)
+	}
+	return &kustomizeRenderer{kustomization: data}, nil
+}
+
+func (r *kustomizeRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	fs := filesys.MakeFsInMemory()
+	if err := fs.MkdirAll(kustomizationDir); err != nil {
+		return nil, err
+	}
+	if err := fs.WriteFile(kustomizationDir+"/kustomization.yaml", r.kustomization); err != nil {
+		return nil, err
+	}
+	if err := fs.WriteFile(kustomizationDir+"/"+manifestsFile, renderedManifests.Bytes()); err != nil {
+		return nil, err
+	}
+
+	resources, err := krusty.MakeKustomizer(fs, krusty.MakeDefaultOptions()).Run(kustomizationDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply kustomization: %w", err)
+	}
+	out, err := resources.AsYaml()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal kustomized manifests: %w", err)
+	}
+	return bytes.NewBuffer(out), nil
+}
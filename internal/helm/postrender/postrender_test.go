@@ -0,0 +1,215 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postrender
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/operator-framework/operator-sdk/internal/helm/watches"
+)
+
+const testManifests = `---
+# Source: test/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: web
+        image: docker.io/nginx:1.19
+---
+# Source: test/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 80
+`
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		prs       []watches.PostRenderer
+		expected  string
+		expectErr bool
+	}{
+		{
+			name: "kustomize",
+			prs: []watches.PostRenderer{{Kustomize: map[string]interface{}{
+				"commonLabels": map[string]interface{}{"org": "example"},
+				"images": []interface{}{
+					map[string]interface{}{"name": "docker.io/nginx", "newName": "mirror.example.com/nginx"},
+				},
+			}}},
+			expected: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    org: example
+  name: web
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      org: example
+  template:
+    metadata:
+      labels:
+        org: example
+    spec:
+      containers:
+      - image: mirror.example.com/nginx:1.19
+        name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    org: example
+  name: web
+spec:
+  ports:
+  - port: 80
+  selector:
+    org: example
+`,
+		},
+		{
+			name: "chained patches",
+			prs: []watches.PostRenderer{
+				{Patches: []watches.Patch{{
+					Patch:  `[{"op": "replace", "path": "/spec/replicas", "value": 3}]`,
+					Target: &watches.PatchTarget{Kind: "Deployment", Name: "web"},
+				}}},
+				{Patches: []watches.Patch{{
+					Patch: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      tolerations:
+      - key: dedicated
+        operator: Exists
+`,
+				}}},
+			},
+			expected: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - image: docker.io/nginx:1.19
+        name: web
+      tolerations:
+      - key: dedicated
+        operator: Exists
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 80
+`,
+		},
+		{
+			name:      "invalid kustomization",
+			prs:       []watches.PostRenderer{{Kustomize: map[string]interface{}{"commonLabels": "org"}}},
+			expectErr: true,
+		},
+		{
+			name:      "missing exec",
+			prs:       []watches.PostRenderer{{Exec: "/nonexistent/post-renderer"}},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pr, err := New(tc.prs)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			out, err := pr.Run(bytes.NewBufferString(testManifests))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, out.String())
+		})
+	}
+}
+
+func TestNewEmpty(t *testing.T) {
+	pr, err := New(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, pr)
+}
+
+func TestDigest(t *testing.T) {
+	d, err := Digest(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, d)
+
+	prs := []watches.PostRenderer{{Kustomize: map[string]interface{}{"commonLabels": map[string]interface{}{"a": "b"}}}}
+	d, err = Digest(prs)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, d)
+	same, err := Digest(prs)
+	assert.NoError(t, err)
+	assert.Equal(t, d, same)
+	other, err := Digest([]watches.PostRenderer{{Exec: "/bin/cat"}})
+	assert.NoError(t, err)
+	assert.NotEqual(t, d, other)
+}
+
+func TestNewExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a shell")
+	}
+	dir, err := ioutil.TempDir("", "postrender")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "post-renderer")
+	assert.NoError(t, ioutil.WriteFile(script, []byte("#!/bin/sh\nsed 's/replicas: 1/replicas: 2/'\n"), 0700))
+
+	pr, err := New([]watches.PostRenderer{{Exec: script}})
+	assert.NoError(t, err)
+	out, err := pr.Run(bytes.NewBufferString(testManifests))
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "replicas: 2")
+}
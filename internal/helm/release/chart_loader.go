@@ -0,0 +1,209 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mitchellh/copystructure"
+	cpb "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("helm.release")
+
+// chartLoader loads a chart once and returns copies of it until the chart's
+// files change on disk. Changes are detected with a file watcher, after which
+// the chart is reloaded only if its content hash differs from the cached one.
+type chartLoader struct {
+	// resolve returns the path of the chart directory or archive to load.
+	resolve func() (string, error)
+
+	mu      sync.Mutex
+	path    string
+	hash    string
+	chart   *cpb.Chart
+	dirty   bool
+	watcher *fsnotify.Watcher
+}
+
+func newChartLoader(resolve func() (string, error)) *chartLoader {
+	return &chartLoader{resolve: resolve}
+}
+
+// Load returns a copy of the cached chart, reloading it first if it has
+// changed. Callers may modify the returned chart.
+func (l *chartLoader) Load() (*cpb.Chart, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.chart != nil && !l.dirty && l.watcher != nil {
+		return copyChart(l.chart)
+	}
+
+	path, err := l.resolve()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := hashPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash chart: %w", err)
+	}
+	if l.chart == nil || l.path != path || l.hash != hash {
+		c, err := loader.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		if l.chart != nil {
+			log.Info("Reloaded changed chart", "path", path, "chart", c.Name(), "version", c.Metadata.Version)
+		}
+		l.path, l.hash, l.chart = path, hash, c
+	}
+	l.dirty = false
+	l.watch(path)
+	return copyChart(l.chart)
+}
+
+// watch starts a file watcher on path, if one is not already running, and
+// (re)adds path and all directories below it to the watcher. If a watcher
+// cannot be started, the chart is hashed on every Load instead.
+func (l *chartLoader) watch(path string) {
+	if l.watcher == nil {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			log.Error(err, "Failed to watch chart for changes", "path", path)
+			return
+		}
+		l.watcher = w
+		go l.handleEvents()
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	// Watch a chart archive's parent directory, since archives are replaced
+	// rather than written in place.
+	if !info.IsDir() {
+		if err := l.watcher.Add(filepath.Dir(path)); err != nil {
+			log.Error(err, "Failed to watch chart for changes", "path", path)
+		}
+		return
+	}
+	_ = filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.IsDir() {
+			return nil
+		}
+		if err := l.watcher.Add(p); err != nil {
+			log.Error(err, "Failed to watch chart for changes", "path", p)
+		}
+		return nil
+	})
+}
+
+func (l *chartLoader) handleEvents() {
+	for {
+		select {
+		case _, ok := <-l.watcher.Events:
+			if !ok {
+				return
+			}
+			l.mu.Lock()
+			l.dirty = true
+			l.mu.Unlock()
+		case err, ok := <-l.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Error(err, "Chart watcher error")
+			l.mu.Lock()
+			l.dirty = true
+			l.mu.Unlock()
+		}
+	}
+}
+
+// hashPath returns a hash of the contents of the file or directory at path.
+func hashPath(path string) (string, error) {
+	h := sha256.New()
+	err := filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, _ = io.WriteString(h, rel+"\x00")
+		_, err = io.Copy(h, f)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyChart returns a copy of c that can be safely modified by Helm actions,
+// which prune disabled dependencies and import values in place. Templates and
+// files are immutable and are shared with c.
+func copyChart(c *cpb.Chart) (*cpb.Chart, error) {
+	out := *c
+	if c.Metadata != nil {
+		md := *c.Metadata
+		md.Dependencies = make([]*cpb.Dependency, 0, len(c.Metadata.Dependencies))
+		for _, d := range c.Metadata.Dependencies {
+			dep := *d
+			md.Dependencies = append(md.Dependencies, &dep)
+		}
+		if c.Metadata.Dependencies == nil {
+			md.Dependencies = nil
+		}
+		out.Metadata = &md
+	}
+	if c.Values != nil {
+		values, err := copystructure.Copy(c.Values)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy chart values: %w", err)
+		}
+		out.Values = values.(map[string]interface{})
+	}
+	deps := make([]*cpb.Chart, 0, len(c.Dependencies()))
+	for _, d := range c.Dependencies() {
+		dep, err := copyChart(d)
+		if err != nil {
+			return nil, err
+		}
+		deps = append(deps, dep)
+	}
+	out.SetDependencies(deps...)
+	return &out, nil
+}
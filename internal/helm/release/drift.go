@@ -0,0 +1,155 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	jsonpatch "gomodules.xyz/jsonpatch/v3"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// DriftPolicy configures how ReconcileRelease handles release resources that
+// differ from the deployed release's manifest.
+type DriftPolicy struct {
+	// ReportOnly reports drifted resources without creating or patching
+	// them.
+	ReportOnly bool
+	// Ignore lists fields that are neither reported nor healed when they
+	// drift.
+	Ignore []DriftIgnoreRule
+}
+
+// DriftIgnoreRule ignores drift of fields of matching release resources.
+type DriftIgnoreRule struct {
+	// Group, Kind and Name select the resources the rule applies to. Empty
+	// fields match all resources.
+	Group string
+	Kind  string
+	Name  string
+	// Paths are JSON pointers to the ignored fields, ex. "/spec/replicas".
+	// A "*" token matches any map key or list index.
+	Paths []string
+}
+
+// DriftedResource is a release resource that differs from the deployed
+// release's manifest.
+type DriftedResource struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	// Missing is true if the resource does not exist.
+	Missing bool
+	// Paths are JSON pointers to the fields that differ.
+	Paths []string
+	// Healed is true if the resource was created or patched to match the
+	// manifest.
+	Healed bool
+}
+
+// ignoredPaths returns the paths ignored by the policy for a resource.
+func (p DriftPolicy) ignoredPaths(gvk schema.GroupVersionKind, name string) []string {
+	var paths []string
+	for _, rule := range p.Ignore {
+		if (rule.Group == "" || rule.Group == gvk.Group) &&
+			(rule.Kind == "" || rule.Kind == gvk.Kind) &&
+			(rule.Name == "" || rule.Name == name) {
+			paths = append(paths, rule.Paths...)
+		}
+	}
+	return paths
+}
+
+// removePaths returns objJSON without the fields at paths.
+func removePaths(objJSON []byte, paths []string) ([]byte, error) {
+	if len(paths) == 0 {
+		return objJSON, nil
+	}
+	var obj interface{}
+	if err := json.Unmarshal(objJSON, &obj); err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		tokens := strings.Split(strings.TrimPrefix(p, "/"), "/")
+		for i := range tokens {
+			tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(tokens[i])
+		}
+		removePath(obj, tokens)
+	}
+	return json.Marshal(obj)
+}
+
+// removePath removes the field at the JSON pointer tokens from obj. List
+// elements can only be traversed, not removed, so that the indices of other
+// elements are unchanged.
+func removePath(obj interface{}, tokens []string) {
+	if len(tokens) == 0 {
+		return
+	}
+	token, rest := tokens[0], tokens[1:]
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		if token == "*" {
+			for k := range o {
+				if len(rest) == 0 {
+					delete(o, k)
+				} else {
+					removePath(o[k], rest)
+				}
+			}
+			return
+		}
+		if len(rest) == 0 {
+			delete(o, token)
+			return
+		}
+		removePath(o[token], rest)
+	case []interface{}:
+		if len(rest) == 0 {
+			return
+		}
+		if token == "*" {
+			for _, e := range o {
+				removePath(e, rest)
+			}
+			return
+		}
+		if i, err := strconv.Atoi(token); err == nil && i >= 0 && i < len(o) {
+			removePath(o[i], rest)
+		}
+	}
+}
+
+// driftedPaths returns the paths of the fields in expectedJSON that differ in
+// existingJSON. Fields that only exist in existingJSON, such as those set by
+// Kubernetes, are not drift.
+func driftedPaths(existingJSON, expectedJSON []byte) ([]string, error) {
+	ops, err := jsonpatch.CreatePatch(existingJSON, expectedJSON)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, op := range ops {
+		if op.Operation != "remove" && !(op.Operation == "add" && op.Value == nil) {
+			paths = append(paths, op.Path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+func TestDriftPolicyIgnoredPaths(t *testing.T) {
+	policy := DriftPolicy{Ignore: []DriftIgnoreRule{
+		{Group: "apps", Kind: "Deployment", Paths: []string{"/spec/replicas"}},
+		{Name: "web", Paths: []string{"/metadata/annotations"}},
+	}}
+	deployment := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	service := schema.GroupVersionKind{Version: "v1", Kind: "Service"}
+
+	assert.Equal(t, []string{"/spec/replicas"}, policy.ignoredPaths(deployment, "api"))
+	assert.Equal(t, []string{"/spec/replicas", "/metadata/annotations"}, policy.ignoredPaths(deployment, "web"))
+	assert.Equal(t, []string{"/metadata/annotations"}, policy.ignoredPaths(service, "web"))
+	assert.Empty(t, policy.ignoredPaths(service, "api"))
+}
+
+func TestRemovePaths(t *testing.T) {
+	in := `{"metadata":{"annotations":{"a/b":"x","c":"y"}},"spec":{"replicas":3,` +
+		`"containers":[{"name":"a","image":"a:1"},{"name":"b","image":"b:1"}]}}`
+	tests := []struct {
+		name     string
+		paths    []string
+		expected string
+	}{
+		{
+			name:     "no paths",
+			expected: in,
+		},
+		{
+			name:  "map field",
+			paths: []string{"/spec/replicas"},
+			expected: `{"metadata":{"annotations":{"a/b":"x","c":"y"}},"spec":{` +
+				`"containers":[{"image":"a:1","name":"a"},{"image":"b:1","name":"b"}]}}`,
+		},
+		{
+			name:  "escaped token",
+			paths: []string{"/metadata/annotations/a~1b"},
+			expected: `{"metadata":{"annotations":{"c":"y"}},"spec":{` +
+				`"containers":[{"image":"a:1","name":"a"},{"image":"b:1","name":"b"}],"replicas":3}}`,
+		},
+		{
+			name:  "wildcard list index",
+			paths: []string{"/spec/containers/*/image"},
+			expected: `{"metadata":{"annotations":{"a/b":"x","c":"y"}},"spec":{` +
+				`"containers":[{"name":"a"},{"name":"b"}],"replicas":3}}`,
+		},
+		{
+			name:  "list elements are not removed",
+			paths: []string{"/spec/containers/0", "/spec/missing/field"},
+			expected: `{"metadata":{"annotations":{"a/b":"x","c":"y"}},"spec":{` +
+				`"containers":[{"image":"a:1","name":"a"},{"image":"b:1","name":"b"}],"replicas":3}}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := removePaths([]byte(in), tc.paths)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tc.expected, string(out))
+		})
+	}
+}
+
+func TestDriftedPaths(t *testing.T) {
+	existing := `{"spec":{"replicas":5,"paused":false,"selector":{"app":"web"}},"status":{"replicas":5}}`
+	expected := `{"spec":{"replicas":3,"selector":{"app":"web","tier":"front"}}}`
+	paths, err := driftedPaths([]byte(existing), []byte(expected))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/spec/replicas", "/spec/selector/tier"}, paths)
+}
+
+func TestCreatePatchIgnoredPaths(t *testing.T) {
+	existingReplicas, expectedReplicas := int32(5), int32(3)
+	existing := &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "ns"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &existingReplicas},
+	}
+	expected := &resource.Info{Object: &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "ns"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &expectedReplicas},
+	}}
+
+	patch, patchType, err := createPatch(existing, expected)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.StrategicMergePatchType, patchType)
+	assert.Equal(t, `{"spec":{"replicas":3}}`, string(patch))
+	paths, err := resourceDriftedPaths(existing, expected, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/spec/replicas"}, paths)
+
+	patch, _, err = createPatch(existing, expected, "/spec/replicas")
+	assert.NoError(t, err)
+	assert.Equal(t, `{}`, string(patch))
+	paths, err = resourceDriftedPaths(existing, expected, []string{"/spec/replicas"})
+	assert.NoError(t, err)
+	assert.Empty(t, paths)
+}
@@ -17,6 +17,8 @@ package release
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -26,6 +28,7 @@ import (
 	"helm.sh/helm/v3/pkg/action"
 	cpb "helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/postrender"
 	rpb "helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/releaseutil"
 	"helm.sh/helm/v3/pkg/storage"
@@ -44,6 +47,7 @@ import (
 
 	"github.com/operator-framework/operator-sdk/internal/helm/internal/types"
 	"github.com/operator-framework/operator-sdk/internal/helm/manifestutil"
+	"github.com/operator-framework/operator-sdk/internal/helm/metrics"
 )
 
 // Manager manages a Helm release. It can install, upgrade, reconcile,
@@ -52,14 +56,23 @@ type Manager interface {
 	ReleaseName() string
 	IsInstalled() bool
 	IsUpgradeRequired() bool
+	Digest() (string, error)
 	Sync(context.Context) error
 	InstallRelease(context.Context, ...InstallOption) (*rpb.Release, error)
 	UpgradeRelease(context.Context, ...UpgradeOption) (*rpb.Release, *rpb.Release, error)
-	ReconcileRelease(context.Context) (*rpb.Release, error)
+	ReconcileRelease(context.Context) (*rpb.Release, []DriftedResource, error)
 	UninstallRelease(context.Context, ...UninstallOption) (*rpb.Release, error)
+	UninstallReleaseNamed(context.Context, string, ...UninstallOption) (*rpb.Release, error)
 	CleanupRelease(context.Context, string) (bool, error)
+	RollbackRelease(context.Context, ...RollbackOption) (*rpb.Release, error)
+	ReleaseHistory() ([]*rpb.Release, error)
+	ReleaseResources(context.Context, string) ([]ReleaseResource, error)
 }
 
+// defaultMaxHistory is the default number of revisions kept per release,
+// matching the default of the helm CLI's --history-max flag.
+const defaultMaxHistory = 10
+
 type manager struct {
 	actionConfig   *action.Configuration
 	storageBackend *storage.Storage
@@ -75,11 +88,26 @@ type manager struct {
 	isUpgradeRequired bool
 	deployedRelease   *rpb.Release
 	chart             *cpb.Chart
+	driftPolicy       DriftPolicy
+	postRenderer      postrender.PostRenderer
+	// renameFrom is the name the release was deployed with, if it differs
+	// from releaseName. The release must then be upgraded, so that its
+	// resources are rendered and labeled with the new name.
+	renameFrom string
+	// storedName is the name the release history is stored under. It is
+	// renameFrom until the history is moved to releaseName on upgrade.
+	storedName string
+	// gvk is the GroupVersionKind of the CR, used to label metrics.
+	gvk string
+	// observed holds the resources last observed by ReconcileRelease, which
+	// ReleaseResources returns instead of getting them again.
+	observed *observedResources
 }
 
 type InstallOption func(*action.Install) error
 type UpgradeOption func(*action.Upgrade) error
 type UninstallOption func(*action.Uninstall) error
+type RollbackOption func(*action.Rollback) error
 
 // ReleaseName returns the name of the release.
 func (m manager) ReleaseName() string {
@@ -94,20 +122,47 @@ func (m manager) IsUpgradeRequired() bool {
 	return m.isUpgradeRequired
 }
 
+// Digest returns a digest of the chart and values that the release is
+// installed or upgraded with.
+func (m manager) Digest() (string, error) {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	var encodeChart func(c *cpb.Chart) error
+	encodeChart = func(c *cpb.Chart) error {
+		if err := enc.Encode(c); err != nil {
+			return err
+		}
+		for _, d := range c.Dependencies() {
+			if err := encodeChart(d); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := encodeChart(m.chart); err != nil {
+		return "", fmt.Errorf("failed to encode chart: %w", err)
+	}
+	if err := enc.Encode(m.values); err != nil {
+		return "", fmt.Errorf("failed to encode values: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 // Sync ensures the Helm storage backend is in sync with the status of the
 // custom resource.
 func (m *manager) Sync(ctx context.Context) error {
 	// Get release history for this release name
-	releases, err := m.storageBackend.History(m.releaseName)
+	releases, err := m.storageBackend.History(m.storedName)
 	if err != nil && !notFoundErr(err) {
 		return fmt.Errorf("failed to retrieve release history: %w", err)
 	}
 
-	// Cleanup non-deployed release versions. If all release versions are
-	// non-deployed, this will ensure that failed installations are correctly
-	// retried.
+	// Cleanup failed and pending release versions. If all release versions
+	// are failed, this will ensure that failed installations are correctly
+	// retried. Superseded versions are kept so that they can be rolled back
+	// to.
 	for _, rel := range releases {
-		if rel.Info != nil && rel.Info.Status != rpb.StatusDeployed {
+		if rel.Info != nil && rel.Info.Status != rpb.StatusDeployed && rel.Info.Status != rpb.StatusSuperseded {
 			_, err := m.storageBackend.Delete(rel.Name, rel.Version)
 			if err != nil && !notFoundErr(err) {
 				return fmt.Errorf("failed to delete stale release version: %w", err)
@@ -143,6 +198,9 @@ func (m manager) isUpgrade(deployedRelease *rpb.Release) bool {
 	// Judging whether to skip updates
 	skip := m.namespace == deployedRelease.Namespace
 	skip = skip && m.releaseName == deployedRelease.Name
+	skip = skip && m.renameFrom == ""
+	// The chart is annotated with the digest of the post-renderer
+	// configuration, so a change to the post-renderers requires an upgrade.
 	skip = skip && apiequality.Semantic.DeepEqual(m.chart, deployedRelease.Chart)
 	skip = skip && apiequality.Semantic.DeepEqual(m.values, deployedRelease.Config)
 
@@ -150,7 +208,7 @@ func (m manager) isUpgrade(deployedRelease *rpb.Release) bool {
 }
 
 func (m manager) getDeployedRelease() (*rpb.Release, error) {
-	deployedRelease, err := m.storageBackend.Deployed(m.releaseName)
+	deployedRelease, err := m.storageBackend.Deployed(m.storedName)
 	if err != nil {
 		if strings.Contains(err.Error(), "has no deployed releases") {
 			return nil, driver.ErrReleaseNotFound
@@ -165,6 +223,7 @@ func (m manager) InstallRelease(ctx context.Context, opts ...InstallOption) (*rp
 	install := action.NewInstall(m.actionConfig)
 	install.ReleaseName = m.releaseName
 	install.Namespace = m.namespace
+	install.PostRenderer = m.postRenderer
 	for _, o := range opts {
 		if err := o(install); err != nil {
 			return nil, fmt.Errorf("failed to apply install option: %w", err)
@@ -172,9 +231,12 @@ func (m manager) InstallRelease(ctx context.Context, opts ...InstallOption) (*rp
 	}
 
 	installedRelease, err := install.Run(m.chart, m.values)
+	if !install.DryRun {
+		metrics.ReleaseAction(m.gvk, "install", err == nil)
+	}
 	if err != nil {
 		// Workaround for helm/helm#3338
-		if installedRelease != nil {
+		if installedRelease != nil && !install.DryRun {
 			uninstall := action.NewUninstall(m.actionConfig)
 			_, uninstallErr := uninstall.Run(m.releaseName)
 
@@ -202,22 +264,79 @@ func ForceUpgrade(force bool) UpgradeOption {
 	}
 }
 
-// UpgradeRelease performs a Helm release upgrade.
-func (m manager) UpgradeRelease(ctx context.Context, opts ...UpgradeOption) (*rpb.Release, *rpb.Release, error) {
-	upgrade := action.NewUpgrade(m.actionConfig)
+// DryRunInstall renders the release without installing it.
+func DryRunInstall(dryRun bool) InstallOption {
+	return func(i *action.Install) error {
+		i.DryRun = dryRun
+		return nil
+	}
+}
+
+// DryRunUpgrade renders the upgraded release without applying or
+// recording it.
+func DryRunUpgrade(dryRun bool) UpgradeOption {
+	return func(u *action.Upgrade) error {
+		u.DryRun = dryRun
+		return nil
+	}
+}
+
+func (m manager) newUpgrade(cfg *action.Configuration, opts []UpgradeOption) (*action.Upgrade, error) {
+	upgrade := action.NewUpgrade(cfg)
 	upgrade.Namespace = m.namespace
+	upgrade.MaxHistory = defaultMaxHistory
+	upgrade.PostRenderer = m.postRenderer
 	for _, o := range opts {
 		if err := o(upgrade); err != nil {
-			return nil, nil, fmt.Errorf("failed to apply upgrade option: %w", err)
+			return nil, fmt.Errorf("failed to apply upgrade option: %w", err)
 		}
 	}
+	return upgrade, nil
+}
+
+// UpgradeRelease performs a Helm release upgrade. If the release is being
+// renamed, its history is first moved to the new name, and moved back if the
+// upgrade fails. A dry-run upgrade does not move the history.
+func (m *manager) UpgradeRelease(ctx context.Context, opts ...UpgradeOption) (*rpb.Release, *rpb.Release, error) {
+	upgrade, err := m.newUpgrade(m.actionConfig, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	rename := m.storedName != m.releaseName
+	if rename && upgrade.DryRun {
+		cfg, err := m.renamedConfig()
+		if err != nil {
+			return nil, nil, err
+		}
+		if upgrade, err = m.newUpgrade(cfg, opts); err != nil {
+			return nil, nil, err
+		}
+	} else if rename {
+		if _, err := renameRelease(m.storageBackend, m.namespace, m.storedName, m.releaseName); err != nil {
+			return nil, nil, fmt.Errorf("failed to migrate release name: %w", err)
+		}
+		log.Info("Migrated release name", "namespace", m.namespace, "from", m.storedName, "to", m.releaseName)
+	}
 
 	upgradedRelease, err := upgrade.Run(m.releaseName, m.chart, m.values)
+	if !upgrade.DryRun {
+		metrics.ReleaseAction(m.gvk, "upgrade", err == nil)
+	}
 	if err != nil {
+		// The CR status records the previous name until the upgrade
+		// succeeds, so move the history back once the failed upgrade has
+		// been rolled back.
+		if rename && !upgrade.DryRun {
+			defer m.restoreName()
+		}
 		// Workaround for helm/helm#3338
-		if upgradedRelease != nil {
+		// With Atomic, the upgrade has already been rolled back by Helm.
+		if upgradedRelease != nil && !upgrade.DryRun && !upgrade.Atomic {
 			rollback := action.NewRollback(m.actionConfig)
 			rollback.Force = true
+			rollback.MaxHistory = upgrade.MaxHistory
+			rollback.DisableHooks = upgrade.DisableHooks
+			rollback.Timeout = upgrade.Timeout
 
 			// As of Helm 2.13, if UpgradeRelease returns a non-nil release, that
 			// means the release was also recorded in the release store.
@@ -231,33 +350,168 @@ func (m manager) UpgradeRelease(ctx context.Context, opts ...UpgradeOption) (*rp
 		}
 		return nil, nil, fmt.Errorf("failed to upgrade release: %w", err)
 	}
+	if rename && !upgrade.DryRun {
+		m.storedName = m.releaseName
+	}
 	return m.deployedRelease, upgradedRelease, err
 }
 
+// restoreName moves the release history back to the name it was stored under
+// before a failed upgrade renamed it.
+func (m *manager) restoreName() {
+	if _, err := renameRelease(m.storageBackend, m.namespace, m.releaseName, m.storedName); err != nil {
+		log.Error(err, "Failed to restore release name after failed upgrade", "namespace", m.namespace,
+			"from", m.releaseName, "to", m.storedName)
+	}
+}
+
+// renamedConfig returns a copy of the manager's action configuration whose
+// release storage holds a copy of the release history under the new release
+// name, so that a dry-run upgrade can be rendered without moving the history.
+func (m manager) renamedConfig() (*action.Configuration, error) {
+	history, _, err := releaseHistory(m.storageBackend, m.storedName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release history: %w", err)
+	}
+	releases := storage.Init(driver.NewMemory())
+	for _, rel := range history {
+		renamed := *rel
+		renamed.Name = m.releaseName
+		if err := releases.Create(&renamed); err != nil {
+			return nil, fmt.Errorf("failed to copy release history: %w", err)
+		}
+	}
+	cfg := *m.actionConfig
+	cfg.Releases = releases
+	return &cfg, nil
+}
+
+// RollbackToRevision sets the revision a release is rolled back to. By
+// default, a release is rolled back to its last deployed revision.
+func RollbackToRevision(revision int) RollbackOption {
+	return func(r *action.Rollback) error {
+		r.Version = revision
+		return nil
+	}
+}
+
+// RollbackRelease rolls the release back to a previous revision and returns
+// the resulting deployed release. If the deployed release already matches the
+// target revision, no rollback is performed.
+func (m manager) RollbackRelease(ctx context.Context, opts ...RollbackOption) (*rpb.Release, error) {
+	rollback := action.NewRollback(m.actionConfig)
+	rollback.MaxHistory = defaultMaxHistory
+	if m.deployedRelease != nil {
+		rollback.Version = m.deployedRelease.Version
+	}
+	for _, o := range opts {
+		if err := o(rollback); err != nil {
+			return nil, fmt.Errorf("failed to apply rollback option: %w", err)
+		}
+	}
+	if rollback.Version == 0 {
+		return nil, fmt.Errorf("failed to rollback release: %w", driver.ErrReleaseNotFound)
+	}
+
+	target, err := m.storageBackend.Get(m.storedName, rollback.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release revision %d: %w", rollback.Version, err)
+	}
+	current, err := m.getDeployedRelease()
+	if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, fmt.Errorf("failed to get deployed release: %w", err)
+	}
+	if current != nil && current.Manifest == target.Manifest &&
+		apiequality.Semantic.DeepEqual(current.Config, target.Config) {
+		return current, nil
+	}
+
+	err = rollback.Run(m.storedName)
+	metrics.ReleaseAction(m.gvk, "rollback", err == nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rollback release to revision %d: %w", rollback.Version, err)
+	}
+	return m.getDeployedRelease()
+}
+
+// ReleaseHistory returns all stored revisions of the release, ordered from
+// oldest to newest.
+func (m manager) ReleaseHistory() ([]*rpb.Release, error) {
+	history, _, err := releaseHistory(m.storageBackend, m.storedName)
+	if err != nil {
+		return nil, err
+	}
+	releaseutil.SortByRevision(history)
+	return history, nil
+}
+
 // ReconcileRelease creates or patches resources as necessary to match the
-// deployed release's manifest.
-func (m manager) ReconcileRelease(ctx context.Context) (*rpb.Release, error) {
-	err := reconcileRelease(ctx, m.kubeClient, m.deployedRelease.Manifest)
-	return m.deployedRelease, err
+// deployed release's manifest, as configured by the manager's drift policy. It
+// returns the resources that differed from the manifest.
+func (m manager) ReconcileRelease(ctx context.Context) (*rpb.Release, []DriftedResource, error) {
+	drifted, resources, err := reconcileRelease(ctx, m.kubeClient, m.deployedRelease.Manifest, m.driftPolicy)
+	if err == nil && m.observed != nil {
+		m.observed.manifest = m.deployedRelease.Manifest
+		m.observed.resources = resources
+	}
+	return m.deployedRelease, drifted, err
 }
 
-func reconcileRelease(_ context.Context, kubeClient kube.Interface, expectedManifest string) error {
+// reconcileRelease also returns the resources of the manifest as they are in
+// the cluster after reconciling them.
+func reconcileRelease(_ context.Context, kubeClient kube.Interface, expectedManifest string,
+	policy DriftPolicy) ([]DriftedResource, []ReleaseResource, error) {
 	expectedInfos, err := kubeClient.Build(bytes.NewBufferString(expectedManifest), false)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	var drifted []DriftedResource
+	resources := make([]ReleaseResource, 0, len(expectedInfos))
+	observe := func(drift DriftedResource, obj runtime.Object) error {
+		res := ReleaseResource{
+			APIVersion: drift.APIVersion,
+			Kind:       drift.Kind,
+			Namespace:  drift.Namespace,
+			Name:       drift.Name,
+		}
+		if obj != nil {
+			u, err := toUnstructured(obj)
+			if err != nil {
+				return err
+			}
+			res.Object = u
+		}
+		resources = append(resources, res)
+		return nil
 	}
-	return expectedInfos.Visit(func(expected *resource.Info, err error) error {
+	err = expectedInfos.Visit(func(expected *resource.Info, err error) error {
 		if err != nil {
 			return fmt.Errorf("visit error: %w", err)
 		}
 
+		gvk := expected.Mapping.GroupVersionKind
+		drift := DriftedResource{
+			APIVersion: gvk.GroupVersion().String(),
+			Kind:       gvk.Kind,
+			Namespace:  expected.Namespace,
+			Name:       expected.Name,
+		}
+
 		helper := resource.NewHelper(expected.Client, expected.Mapping)
 		existing, err := helper.Get(expected.Namespace, expected.Name)
 		if apierrors.IsNotFound(err) {
-			if _, err := helper.Create(expected.Namespace, true, expected.Object); err != nil {
+			drift.Missing = true
+			if policy.ReportOnly {
+				drifted = append(drifted, drift)
+				return observe(drift, nil)
+			}
+			created, err := helper.Create(expected.Namespace, true, expected.Object)
+			if err != nil {
 				return fmt.Errorf("create error: %s", err)
 			}
-			return nil
+			drift.Healed = true
+			drifted = append(drifted, drift)
+			return observe(drift, created)
 		} else if err != nil {
 			return fmt.Errorf("could not get object: %w", err)
 		}
@@ -267,31 +521,68 @@ func reconcileRelease(_ context.Context, kubeClient kube.Interface, expectedMani
 		// We also extend the JSON merge patch by ignoring "remove" operations for fields added by kubernetes
 		// Reference in the helm source code:
 		// https://github.com/helm/helm/blob/1c9b54ad7f62a5ce12f87c3ae55136ca20f09c98/pkg/kube/client.go#L392
-		patch, patchType, err := createPatch(existing, expected)
+		ignored := policy.ignoredPaths(gvk, expected.Name)
+		patch, patchType, err := createPatch(existing, expected, ignored...)
 		if err != nil {
 			return fmt.Errorf("error creating patch: %w", err)
 		}
 
-		if patch == nil {
+		// Strategic merge patches without changes are empty objects.
+		if patch == nil || bytes.Equal(patch, []byte("{}")) {
 			// nothing to do
-			return nil
+			return observe(drift, existing)
+		}
+
+		if drift.Paths, err = resourceDriftedPaths(existing, expected, ignored); err != nil {
+			return fmt.Errorf("error detecting drift: %w", err)
+		}
+		if policy.ReportOnly {
+			drifted = append(drifted, drift)
+			return observe(drift, existing)
 		}
 
-		_, err = helper.Patch(expected.Namespace, expected.Name, patchType, patch,
+		patched, err := helper.Patch(expected.Namespace, expected.Name, patchType, patch,
 			&metav1.PatchOptions{})
 		if err != nil {
 			return fmt.Errorf("patch error: %w", err)
 		}
-		return nil
+		drift.Healed = true
+		drifted = append(drifted, drift)
+		return observe(drift, patched)
 	})
+	return drifted, resources, err
 }
 
-func createPatch(existing runtime.Object, expected *resource.Info) ([]byte, apitypes.PatchType, error) {
+// resourceDriftedPaths returns the paths of the fields of expected that differ
+// in existing, except for ignored paths.
+func resourceDriftedPaths(existing runtime.Object, expected *resource.Info, ignored []string) ([]string, error) {
+	existingJSON, expectedJSON, err := patchJSON(existing, expected, ignored)
+	if err != nil {
+		return nil, err
+	}
+	return driftedPaths(existingJSON, expectedJSON)
+}
+
+// patchJSON returns the JSON of existing and expected. Ignored paths are
+// removed from expected, so that patches leave them unchanged.
+func patchJSON(existing runtime.Object, expected *resource.Info, ignored []string) ([]byte, []byte, error) {
 	existingJSON, err := json.Marshal(existing)
 	if err != nil {
-		return nil, apitypes.StrategicMergePatchType, err
+		return nil, nil, err
 	}
 	expectedJSON, err := json.Marshal(expected.Object)
+	if err != nil {
+		return nil, nil, err
+	}
+	expectedJSON, err = removePaths(expectedJSON, ignored)
+	if err != nil {
+		return nil, nil, err
+	}
+	return existingJSON, expectedJSON, nil
+}
+
+func createPatch(existing runtime.Object, expected *resource.Info, ignored ...string) ([]byte, apitypes.PatchType, error) {
+	existingJSON, expectedJSON, err := patchJSON(existing, expected, ignored)
 	if err != nil {
 		return nil, apitypes.StrategicMergePatchType, err
 	}
@@ -355,13 +646,22 @@ func createJSONMergePatch(existingJSON, expectedJSON []byte) ([]byte, error) {
 
 // UninstallRelease performs a Helm release uninstall.
 func (m manager) UninstallRelease(ctx context.Context, opts ...UninstallOption) (*rpb.Release, error) {
+	return m.UninstallReleaseNamed(ctx, m.storedName, opts...)
+}
+
+// UninstallReleaseNamed uninstalls another release of the CR's namespace, such
+// as the release of a chart that was removed from the CR's watch.
+func (m manager) UninstallReleaseNamed(_ context.Context, name string, opts ...UninstallOption) (*rpb.Release, error) {
 	uninstall := action.NewUninstall(m.actionConfig)
 	for _, o := range opts {
 		if err := o(uninstall); err != nil {
 			return nil, fmt.Errorf("failed to apply uninstall option: %w", err)
 		}
 	}
-	uninstallResponse, err := uninstall.Run(m.releaseName)
+	uninstallResponse, err := uninstall.Run(name)
+	if !errors.Is(err, driver.ErrReleaseNotFound) {
+		metrics.ReleaseAction(m.gvk, "uninstall", err == nil)
+	}
 	if uninstallResponse == nil {
 		return nil, err
 	}
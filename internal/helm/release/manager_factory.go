@@ -15,21 +15,26 @@
 package release
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"sync"
 
 	"helm.sh/helm/v3/pkg/action"
-	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/postrender"
 	helmrelease "helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/storage"
-	"helm.sh/helm/v3/pkg/storage/driver"
 	"helm.sh/helm/v3/pkg/strvals"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
 	crmanager "sigs.k8s.io/controller-runtime/pkg/manager"
 
+	"github.com/operator-framework/operator-sdk/internal/helm/chartsource"
 	"github.com/operator-framework/operator-sdk/internal/helm/client"
 	"github.com/operator-framework/operator-sdk/internal/helm/internal/types"
+	"github.com/operator-framework/operator-sdk/internal/helm/watches"
 )
 
 // ManagerFactory creates Managers that are specific to custom resources. It is
@@ -37,61 +42,237 @@ import (
 // improves decoupling between reconciliation logic and the Helm backend
 // components used to manage releases.
 type ManagerFactory interface {
-	NewManager(r *unstructured.Unstructured, overrideValues map[string]string) (Manager, error)
+	NewManager(r *unstructured.Unstructured, overrideValues map[string]string,
+		referencedValues ...map[string]interface{}) (Manager, error)
+	Validate(r *unstructured.Unstructured, overrideValues map[string]string,
+		referencedValues ...map[string]interface{}) error
 }
 
+// postRendererDigestAnnotation is the chart annotation in which the digest of
+// the post-renderer configuration a release was rendered with is stored.
+const postRendererDigestAnnotation = "helm.sdk.operatorframework.io/post-renderer-digest"
+
 type managerFactory struct {
-	mgr      crmanager.Manager
-	chartDir string
+	mgr            crmanager.Manager
+	chart          *chartLoader
+	storageOptions StorageOptions
+	driftPolicy    DriftPolicy
+	postRenderer   postrender.PostRenderer
+	releaseNames   ReleaseNameOptions
+	component      ComponentOptions
+	storage        *storageFactory
+
+	// postRendererDigest is recorded in the chart of each release, so that
+	// releases are upgraded when the post-renderer's configuration changes.
+	postRendererDigest string
+
+	mu      sync.Mutex
+	clients map[string]*namespaceClients
+}
+
+// namespaceClients holds the clients and storage backend shared by all
+// managers for custom resources in a namespace.
+type namespaceClients struct {
+	restClientGetter genericclioptions.RESTClientGetter
+	kubeClient       *kube.Client
+	storageBackend   *storage.Storage
+}
+
+// ManagerFactoryOption configures a ManagerFactory.
+type ManagerFactoryOption func(*managerFactory)
+
+// WithStorageOptions configures the backend in which a ManagerFactory's
+// managers store release history.
+func WithStorageOptions(opts StorageOptions) ManagerFactoryOption {
+	return func(f *managerFactory) {
+		f.storageOptions = opts
+	}
+}
+
+// WithDriftPolicy configures how a ManagerFactory's managers handle release
+// resources that differ from the release manifest.
+func WithDriftPolicy(policy DriftPolicy) ManagerFactoryOption {
+	return func(f *managerFactory) {
+		f.driftPolicy = policy
+	}
+}
+
+// WithPostRenderer configures a post-renderer that a ManagerFactory's managers
+// apply to rendered manifests on install and upgrade. digest identifies the
+// post-renderer's configuration; releases are upgraded when it changes.
+func WithPostRenderer(pr postrender.PostRenderer, digest string) ManagerFactoryOption {
+	return func(f *managerFactory) {
+		f.postRenderer = pr
+		f.postRendererDigest = digest
+	}
+}
+
+// WithReleaseNameOptions configures how a ManagerFactory derives the release
+// names of CRs.
+func WithReleaseNameOptions(opts ReleaseNameOptions) ManagerFactoryOption {
+	return func(f *managerFactory) {
+		f.releaseNames = opts
+	}
+}
+
+// ComponentOptions configures a ManagerFactory that manages one of the
+// releases of CRs composed of several charts.
+type ComponentOptions struct {
+	// Name identifies the release in the CR status.
+	Name string
+	// ValuesPath is the path of the release's values in the CR spec. If
+	// empty, the release's values are the whole spec.
+	ValuesPath []string
+}
+
+// WithComponent configures a ManagerFactory to manage one of the releases of
+// CRs composed of several charts.
+func WithComponent(opts ComponentOptions) ManagerFactoryOption {
+	return func(f *managerFactory) {
+		f.component = opts
+	}
 }
 
 // NewManagerFactory returns a new Helm manager factory capable of installing and uninstalling releases.
-func NewManagerFactory(mgr crmanager.Manager, chartDir string) ManagerFactory {
-	return &managerFactory{mgr, chartDir}
+func NewManagerFactory(mgr crmanager.Manager, chartDir string, opts ...ManagerFactoryOption) ManagerFactory {
+	return newManagerFactory(mgr, func() (string, error) {
+		return chartDir, nil
+	}, opts...)
 }
 
-func (f managerFactory) NewManager(cr *unstructured.Unstructured, overrideValues map[string]string) (Manager, error) {
-	// Get both v2 and v3 storage backends
-	clientv1, err := v1.NewForConfig(f.mgr.GetConfig())
-	if err != nil {
-		return nil, fmt.Errorf("failed to get core/v1 client: %w", err)
+// NewManagerFactoryForChartSource returns a new Helm manager factory that
+// resolves its chart from src using cache. The chart archive is verified
+// against the digest of src each time it is loaded.
+func NewManagerFactoryForChartSource(mgr crmanager.Manager, cache *chartsource.Cache, src watches.ChartSource,
+	opts ...ManagerFactoryOption) ManagerFactory {
+	return newManagerFactory(mgr, func() (string, error) {
+		return cache.Resolve(context.TODO(), src)
+	}, opts...)
+}
+
+func newManagerFactory(mgr crmanager.Manager, resolveChart func() (string, error),
+	opts ...ManagerFactoryOption) *managerFactory {
+	f := &managerFactory{
+		mgr:     mgr,
+		chart:   newChartLoader(resolveChart),
+		clients: map[string]*namespaceClients{},
+	}
+	for _, o := range opts {
+		o(f)
 	}
-	storageBackend := storage.Init(driver.NewSecrets(clientv1.Secrets(cr.GetNamespace())))
+	f.storage = newStorageFactory(mgr.GetConfig(), f.storageOptions)
+	return f
+}
 
-	// Get the necessary clients and client getters. Use a client that injects the CR
-	// as an owner reference into all resources templated by the chart.
-	rcg, err := client.NewRESTClientGetter(f.mgr, cr.GetNamespace())
+// clientsFor returns the clients for namespace ns, creating them on first use.
+func (f *managerFactory) clientsFor(ns string) (*namespaceClients, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if c, ok := f.clients[ns]; ok {
+		return c, nil
+	}
+	storageBackend, err := f.storage.storageFor(ns)
+	if err != nil {
+		return nil, err
+	}
+	rcg, err := client.NewRESTClientGetter(f.mgr, ns)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get REST client getter from manager: %w", err)
 	}
+	c := &namespaceClients{
+		restClientGetter: rcg,
+		kubeClient:       kube.New(rcg),
+		storageBackend:   storageBackend,
+	}
+	f.clients[ns] = c
+	return c, nil
+}
+
+// NewManager returns a Manager for the release of cr. The release's values are
+// the CR's spec, merged with each of referencedValues in order, and then with
+// overrideValues, so that later values take precedence.
+func (f *managerFactory) NewManager(cr *unstructured.Unstructured, overrideValues map[string]string,
+	referencedValues ...map[string]interface{}) (Manager, error) {
+	// Get the necessary clients and client getters, which are shared by all
+	// CRs in the namespace. Use a client that injects the CR as an owner
+	// reference into all resources templated by the chart.
+	clients, err := f.clientsFor(cr.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	rcg := clients.restClientGetter
+	// Share the namespace's storage driver, but not the storage itself,
+	// since actions set its MaxHistory.
+	storageBackend := storage.Init(clients.storageBackend.Driver)
 
-	kubeClient := kube.New(rcg)
 	restMapper := f.mgr.GetRESTMapper()
-	ownerRefClient, err := client.NewOwnerRefInjectingClient(*kubeClient, restMapper, cr)
+	ownerRefClient, err := client.NewOwnerRefInjectingClient(*clients.kubeClient, restMapper, cr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to inject owner references: %w", err)
 	}
 
-	crChart, err := loader.LoadDir(f.chartDir)
+	crChart, err := f.chart.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart: %w", err)
+	}
+	if f.postRendererDigest != "" {
+		annotations := make(map[string]string, len(crChart.Metadata.Annotations)+1)
+		for k, v := range crChart.Metadata.Annotations {
+			annotations[k] = v
+		}
+		annotations[postRendererDigestAnnotation] = f.postRendererDigest
+		crChart.Metadata.Annotations = annotations
+	}
+
+	values, err := releaseValues(cr, overrideValues, referencedValues, f.component.ValuesPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load chart dir: %w", err)
+		return nil, err
 	}
 
-	releaseName, err := getReleaseName(storageBackend, crChart.Name(), cr)
+	releaseName, err := f.releaseNames.releaseName(cr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get helm release name: %w", err)
 	}
+	// The release may have been deployed with a different name, ex. before
+	// the release name template changed. Its history is moved to the new
+	// name when the release is next upgraded, and the CR status keeps the
+	// previous name until the upgrade succeeds.
+	status := types.StatusFor(cr)
+	deployedName := releaseName
+	if f.component.Name != "" {
+		if rel := status.ComponentRelease(f.component.Name); rel != nil && rel.ReleaseName != "" {
+			deployedName = rel.ReleaseName
+		}
+	} else if status.DeployedRelease != nil && status.DeployedRelease.Name != "" {
+		deployedName = status.DeployedRelease.Name
+	}
 
-	crValues, ok := cr.Object["spec"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("failed to get spec: expected map[string]interface{}")
+	if err := f.storage.migrate(storageBackend, cr.GetNamespace(), deployedName); err != nil {
+		return nil, fmt.Errorf("failed to migrate release storage: %w", err)
+	}
+	// Verify the name before the release history is moved to it, so that the
+	// history is not merged into another chart's release.
+	if err := verifyReleaseName(storageBackend, crChart.Name(), releaseName); err != nil {
+		return nil, fmt.Errorf("failed to get helm release name: %w", err)
 	}
 
-	expOverrides, err := parseOverrides(overrideValues)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse override values: %w", err)
+	renameFrom, storedName := "", releaseName
+	if deployedName != releaseName {
+		if err := verifyReleaseName(storageBackend, crChart.Name(), deployedName); err != nil {
+			return nil, fmt.Errorf("failed to migrate release name: %w", err)
+		}
+		renameFrom = deployedName
+		// The history may already have been moved by an upgrade that did
+		// not update the CR status.
+		_, exists, err := releaseHistory(storageBackend, deployedName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get release history: %w", err)
+		}
+		if exists {
+			storedName = deployedName
+		}
 	}
-	values := mergeMaps(crValues, expOverrides)
 
 	actionConfig := &action.Configuration{
 		RESTClientGetter: rcg,
@@ -108,53 +289,127 @@ func (f managerFactory) NewManager(cr *unstructured.Unstructured, overrideValues
 		releaseName: releaseName,
 		namespace:   cr.GetNamespace(),
 
-		chart:  crChart,
-		values: values,
-		status: types.StatusFor(cr),
+		chart:        crChart,
+		values:       values,
+		status:       status,
+		driftPolicy:  f.driftPolicy,
+		postRenderer: f.postRenderer,
+		renameFrom:   renameFrom,
+		storedName:   storedName,
+		gvk:          cr.GroupVersionKind().String(),
+		observed:     &observedResources{},
 	}, nil
 }
 
-// getReleaseName returns a release name for the CR.
+// Validate returns an error if the release of cr cannot be installed or
+// upgraded: if the release's values do not match the chart's values schema, or
+// if the release name is used by a release of another chart.
+func (f *managerFactory) Validate(cr *unstructured.Unstructured, overrideValues map[string]string,
+	referencedValues ...map[string]interface{}) error {
+	crChart, err := f.chart.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load chart: %w", err)
+	}
+	values, err := releaseValues(cr, overrideValues, referencedValues, f.component.ValuesPath)
+	if err != nil {
+		return err
+	}
+	// Validate the values the chart is rendered with, including the chart's
+	// default values, as Helm does on install and upgrade.
+	coalesced, err := chartutil.CoalesceValues(crChart, values)
+	if err != nil {
+		return fmt.Errorf("failed to coalesce values: %w", err)
+	}
+	if err := chartutil.ValidateAgainstSchema(crChart, coalesced); err != nil {
+		return fmt.Errorf("values do not match the chart's schema: %w", err)
+	}
+
+	releaseName, err := f.releaseNames.releaseName(cr)
+	if err != nil {
+		return fmt.Errorf("failed to get helm release name: %w", err)
+	}
+	clients, err := f.clientsFor(cr.GetNamespace())
+	if err != nil {
+		return err
+	}
+	return verifyReleaseName(storage.Init(clients.storageBackend.Driver), crChart.Name(), releaseName)
+}
+
+// releaseValues returns the values of the release of cr: its spec, merged with
+// each of referencedValues in order, and then with overrideValues. If
+// valuesPath is set, only the values at that path are returned.
+func releaseValues(cr *unstructured.Unstructured, overrideValues map[string]string,
+	referencedValues []map[string]interface{}, valuesPath []string) (map[string]interface{}, error) {
+	crValues, ok := cr.Object["spec"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("failed to get spec: expected map[string]interface{}")
+	}
+
+	expOverrides, err := parseOverrides(overrideValues)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse override values: %w", err)
+	}
+	values := crValues
+	for _, v := range referencedValues {
+		values = mergeMaps(values, v)
+	}
+	values = mergeMaps(values, expOverrides)
+	if len(valuesPath) == 0 {
+		return values, nil
+	}
+
+	// A chart whose values are not set is installed with its defaults.
+	pathValues, _, err := unstructured.NestedFieldNoCopy(values, valuesPath...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get values at spec.%s: %w", strings.Join(valuesPath, "."), err)
+	}
+	switch v := pathValues.(type) {
+	case nil:
+		return map[string]interface{}{}, nil
+	case map[string]interface{}:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("failed to get values at spec.%s: expected map[string]interface{}, got %T",
+			strings.Join(valuesPath, "."), pathValues)
+	}
+}
+
+// verifyReleaseName verifies that the CR can use the release name.
 //
-// getReleaseName searches for a release using the CR name. If a release
-// cannot be found, or if it is found and was created by the chart managed
-// by this manager, the CR name is returned.
+// If a release with the name is not found, or if it is found and was created
+// by the chart managed by this manager, the name can be used.
 //
 // If a release is found but it was created by another chart, that means we
 // have a release name collision, so return an error. This case is possible
 // because Kubernetes allows instances of different types to have the same name
-// in the same namespace.
+// in the same namespace, and because release name templates may render the
+// same name for CRs of different types.
 //
-// TODO(jlanford): As noted above, using the CR name as the release name raises
-//   the possibility of collision. We should move this logic to a validating
-//   admission webhook so that the CR owner receives immediate feedback of the
-//   collision. As is, the only indication of collision will be in the CR status
-//   and operator logs.
-func getReleaseName(storageBackend *storage.Storage, crChartName string,
-	cr *unstructured.Unstructured) (string, error) {
-	// If a release with the CR name does not exist, return the CR name.
-	releaseName := cr.GetName()
+// Validate also rejects collisions, so that a watch's validating webhook gives
+// the CR owner immediate feedback. Otherwise, the only indication of collision
+// is in the CR status and operator logs.
+func verifyReleaseName(storageBackend *storage.Storage, crChartName, releaseName string) error {
 	history, exists, err := releaseHistory(storageBackend, releaseName)
 	if err != nil {
-		return "", err
+		return err
 	}
 	if !exists {
-		return releaseName, nil
+		return nil
 	}
 
-	// If a release name with the CR name exists, but the release's chart is
+	// If a release with the name exists, but the release's chart is
 	// different than the chart managed by this operator, return an error
 	// because something else created the existing release.
 	if history[0].Chart == nil {
-		return "", fmt.Errorf("could not find chart metadata in release with name %q", releaseName)
+		return fmt.Errorf("could not find chart metadata in release with name %q", releaseName)
 	}
 	existingChartName := history[0].Chart.Name()
 	if existingChartName != crChartName {
-		return "", fmt.Errorf("duplicate release name: found existing release with name %q for chart %q",
+		return fmt.Errorf("duplicate release name: found existing release with name %q for chart %q",
 			releaseName, existingChartName)
 	}
 
-	return releaseName, nil
+	return nil
 }
 
 func releaseHistory(storageBackend *storage.Storage, releaseName string) ([]*helmrelease.Release, bool, error) {
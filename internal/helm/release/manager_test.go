@@ -15,12 +15,22 @@
 package release
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/action"
 	cpb "helm.sh/helm/v3/pkg/chart"
 	lpb "helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
 	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -216,10 +226,13 @@ func TestManagerGenerateStrategicMergePatch(t *testing.T) {
 }
 
 func TestManagerisUpgrade(t *testing.T) {
+	postRendered := newTestChart(t, "./testdata/simple")
+	postRendered.Metadata.Annotations = map[string]string{postRendererDigestAnnotation: "digest"}
 	tests := []struct {
 		name            string
 		releaseName     string
 		releaseNs       string
+		renameFrom      string
 		values          map[string]interface{}
 		chart           *cpb.Chart
 		deployedRelease *rpb.Release
@@ -252,12 +265,32 @@ func TestManagerisUpgrade(t *testing.T) {
 			deployedRelease: newTestRelease(newTestChart(t, "./testdata/simple"), map[string]interface{}{"key": ""}, "deployed", "deployed-ns"),
 			want:            true,
 		},
+		{
+			name:            "different post-renderer",
+			releaseName:     "deployed",
+			releaseNs:       "deployed-ns",
+			values:          map[string]interface{}{"key": "value"},
+			chart:           postRendered,
+			deployedRelease: newTestRelease(newTestChart(t, "./testdata/simple"), map[string]interface{}{"key": "value"}, "deployed", "deployed-ns"),
+			want:            true,
+		},
+		{
+			name:            "renamed",
+			releaseName:     "deployed",
+			releaseNs:       "deployed-ns",
+			renameFrom:      "previous",
+			values:          map[string]interface{}{"key": "value"},
+			chart:           newTestChart(t, "./testdata/simple"),
+			deployedRelease: newTestRelease(newTestChart(t, "./testdata/simple"), map[string]interface{}{"key": "value"}, "deployed", "deployed-ns"),
+			want:            true,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			m := manager{
 				releaseName: test.releaseName,
 				namespace:   test.releaseNs,
+				renameFrom:  test.renameFrom,
 				values:      test.values,
 				chart:       test.chart,
 			}
@@ -267,6 +300,57 @@ func TestManagerisUpgrade(t *testing.T) {
 	}
 }
 
+func TestManagerDigest(t *testing.T) {
+	digest := func(chartPath string, values map[string]interface{}) string {
+		m := manager{chart: newTestChart(t, chartPath), values: values}
+		d, err := m.Digest()
+		assert.NoError(t, err)
+		return d
+	}
+	d := digest("./testdata/simple", map[string]interface{}{"key": "value"})
+	assert.Equal(t, d, digest("./testdata/simple", map[string]interface{}{"key": "value"}))
+	assert.NotEqual(t, d, digest("./testdata/simple", map[string]interface{}{"key": "other"}))
+	assert.NotEqual(t, d, digest("./testdata/simpledf", map[string]interface{}{"key": "value"}))
+}
+
+func TestManagerReleaseResourcesObserved(t *testing.T) {
+	observed := []ReleaseResource{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns", Name: "test"}}
+	// The kube client is not set, so resources must not be got again.
+	m := manager{observed: &observedResources{manifest: "manifest", resources: observed}}
+	resources, err := m.ReleaseResources(context.TODO(), "manifest")
+	assert.NoError(t, err)
+	assert.Equal(t, observed, resources)
+}
+
+func TestManagerUpgradeReleaseRename(t *testing.T) {
+	m := newTestRollbackManager(t,
+		newTestHistoryRelease(1, rpb.StatusSuperseded, "v1"),
+		newTestHistoryRelease(2, rpb.StatusDeployed, "v2"),
+	)
+	m.releaseName = "renamed"
+	m.renameFrom = "test"
+	m.chart = newTestChart(t, "./testdata/simple")
+	assert.True(t, m.isUpgrade(m.deployedRelease))
+
+	// A dry-run upgrade does not move the release history.
+	_, upgraded, err := m.UpgradeRelease(context.TODO(), DryRunUpgrade(true))
+	assert.NoError(t, err)
+	assert.Equal(t, "renamed", upgraded.Name)
+	_, exists, err := releaseHistory(m.storageBackend, "renamed")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	_, upgraded, err = m.UpgradeRelease(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, upgraded.Version)
+	history, err := m.ReleaseHistory()
+	assert.NoError(t, err)
+	assert.Len(t, history, 3)
+	_, exists, err = releaseHistory(m.storageBackend, "test")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
 func newTestChart(t *testing.T, path string) *cpb.Chart {
 	chart, err := lpb.Load(path)
 	assert.Nil(t, err)
@@ -283,3 +367,228 @@ func newTestRelease(chart *cpb.Chart, values map[string]interface{}, name, names
 	release.Config = values
 	return release
 }
+
+func TestChartLoader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chart-loader-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	copyTestChart(t, "./testdata/simple", dir)
+
+	l := newChartLoader(func() (string, error) { return dir, nil })
+	first, err := l.Load()
+	assert.Nil(t, err)
+
+	// Modifying a loaded chart must not affect the cached chart.
+	first.Values["injected"] = true
+	second, err := l.Load()
+	assert.Nil(t, err)
+	assert.NotContains(t, second.Values, "injected")
+
+	// Changing the chart on disk invalidates the cached chart.
+	chartFile := filepath.Join(dir, "Chart.yaml")
+	data, err := ioutil.ReadFile(chartFile)
+	assert.Nil(t, err)
+	data = append(data, []byte("description: changed\n")...)
+	assert.Nil(t, ioutil.WriteFile(chartFile, data, 0644))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c, err := l.Load()
+		assert.Nil(t, err)
+		if c.Metadata.Description == "changed" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for chart to be reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// BenchmarkLoadChartDir measures loading a chart from disk, which the manager
+// factory previously did for every custom resource on every reconcile.
+func BenchmarkLoadChartDir(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := lpb.LoadDir("./testdata/simple"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkChartLoader measures loading a chart through the manager factory's
+// chart cache.
+func BenchmarkChartLoader(b *testing.B) {
+	l := newChartLoader(func() (string, error) { return "./testdata/simple", nil })
+	if _, err := l.Load(); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := l.Load(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func copyTestChart(t *testing.T, src, dst string) {
+	err := filepath.Walk(src, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dst, rel), data, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestRollbackManager(t *testing.T, releases ...*rpb.Release) *manager {
+	store := storage.Init(driver.NewMemory())
+	for _, rel := range releases {
+		assert.Nil(t, store.Create(rel))
+	}
+	m := &manager{
+		actionConfig: &action.Configuration{
+			Releases:     store,
+			KubeClient:   &kubefake.PrintingKubeClient{Out: ioutil.Discard},
+			Capabilities: chartutil.DefaultCapabilities,
+			Log:          func(_ string, _ ...interface{}) {},
+		},
+		storageBackend: store,
+		releaseName:    "test",
+		namespace:      "ns",
+		storedName:     "test",
+	}
+	assert.Nil(t, m.Sync(context.TODO()))
+	return m
+}
+
+func newTestHistoryRelease(version int, status rpb.Status, manifest string) *rpb.Release {
+	rel := rpb.Mock(&rpb.MockReleaseOptions{
+		Name:      "test",
+		Namespace: "ns",
+		Version:   version,
+		Status:    status,
+	})
+	rel.Manifest = manifest
+	return rel
+}
+
+func TestManagerRollbackRelease(t *testing.T) {
+	m := newTestRollbackManager(t,
+		newTestHistoryRelease(1, rpb.StatusSuperseded, "v1"),
+		newTestHistoryRelease(2, rpb.StatusDeployed, "v2"),
+	)
+
+	rel, err := m.RollbackRelease(context.TODO(), RollbackToRevision(1))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, rel.Version)
+	assert.Equal(t, "v1", rel.Manifest)
+
+	history, err := m.ReleaseHistory()
+	assert.Nil(t, err)
+	assert.Len(t, history, 3)
+	assert.Equal(t, 1, history[0].Version)
+	assert.Equal(t, 3, history[2].Version)
+}
+
+func TestManagerRollbackReleaseAlreadyDeployed(t *testing.T) {
+	m := newTestRollbackManager(t,
+		newTestHistoryRelease(1, rpb.StatusSuperseded, "v1"),
+		newTestHistoryRelease(2, rpb.StatusDeployed, "v1"),
+	)
+
+	// The deployed release already matches revision 1, so no new revision
+	// is created.
+	rel, err := m.RollbackRelease(context.TODO(), RollbackToRevision(1))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, rel.Version)
+
+	history, err := m.ReleaseHistory()
+	assert.Nil(t, err)
+	assert.Len(t, history, 2)
+}
+
+func TestManagerSyncKeepsSupersededReleases(t *testing.T) {
+	m := newTestRollbackManager(t,
+		newTestHistoryRelease(1, rpb.StatusSuperseded, "v1"),
+		newTestHistoryRelease(2, rpb.StatusDeployed, "v2"),
+		newTestHistoryRelease(3, rpb.StatusFailed, "v3"),
+	)
+
+	history, err := m.ReleaseHistory()
+	assert.Nil(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, 2, m.deployedRelease.Version)
+}
+
+func TestManagerFactoryValidate(t *testing.T) {
+	storageBackend := storage.Init(driver.NewMemory())
+	f := &managerFactory{
+		chart: newChartLoader(func() (string, error) { return "./testdata/schema", nil }),
+		clients: map[string]*namespaceClients{
+			"ns": {storageBackend: storageBackend},
+		},
+	}
+	newCR := func(name string, spec map[string]interface{}) *unstructured.Unstructured {
+		cr := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+		cr.SetNamespace("ns")
+		cr.SetName(name)
+		return cr
+	}
+
+	assert.NoError(t, f.Validate(newCR("valid", map[string]interface{}{}), nil), "chart defaults are valid")
+	assert.NoError(t, f.Validate(newCR("valid", map[string]interface{}{"replicaCount": int64(3)}), nil))
+	assert.Error(t, f.Validate(newCR("invalid", map[string]interface{}{"replicaCount": int64(0)}), nil))
+	assert.Error(t, f.Validate(newCR("invalid", map[string]interface{}{"replicaCount": "three"}), nil))
+	assert.Error(t, f.Validate(newCR("image", map[string]interface{}{"image": "docker.io/nginx"}), nil))
+	assert.NoError(t, f.Validate(newCR("overridden", map[string]interface{}{"image": "docker.io/nginx"}),
+		map[string]string{"image": "registry.example.com/nginx"}))
+	assert.Error(t, f.Validate(newCR("referenced", map[string]interface{}{}), nil,
+		map[string]interface{}{"replicaCount": int64(0)}))
+
+	// A release of another chart with the CR's name collides.
+	assert.NoError(t, storageBackend.Create(newTestRelease(newTestChart(t, "./testdata/simple"), nil, "taken", "ns")))
+	assert.Error(t, f.Validate(newCR("taken", map[string]interface{}{}), nil))
+	assert.NoError(t, storageBackend.Create(newTestRelease(newTestChart(t, "./testdata/schema"), nil, "mine", "ns")))
+	assert.NoError(t, f.Validate(newCR("mine", map[string]interface{}{}), nil))
+}
+
+func TestReleaseValuesPath(t *testing.T) {
+	cr := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"database": map[string]interface{}{"replicaCount": int64(1)},
+			"app":      map[string]interface{}{"image": "nginx"},
+			"invalid":  "value",
+		},
+	}}
+
+	values, err := releaseValues(cr, map[string]string{"database.replicaCount": "3"},
+		[]map[string]interface{}{{"database": map[string]interface{}{"storage": "1Gi"}}}, []string{"database"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"replicaCount": "3", "storage": "1Gi"}, values)
+
+	values, err = releaseValues(cr, nil, nil, []string{"cache"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{}, values, "unset values default to the chart's")
+
+	_, err = releaseValues(cr, nil, nil, []string{"invalid"})
+	assert.Error(t, err)
+	_, err = releaseValues(cr, nil, nil, []string{"invalid", "nested"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,176 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+)
+
+// WaitInstall waits until all release resources are ready, up to the
+// install timeout, before marking an install as successful.
+func WaitInstall(wait bool) InstallOption {
+	return func(i *action.Install) error {
+		i.Wait = wait
+		return nil
+	}
+}
+
+// TimeoutInstall sets the time to wait for Kubernetes operations, including
+// hooks, during an install.
+func TimeoutInstall(timeout time.Duration) InstallOption {
+	return func(i *action.Install) error {
+		i.Timeout = timeout
+		return nil
+	}
+}
+
+// AtomicInstall uninstalls a release if its install fails. It implies
+// WaitInstall.
+func AtomicInstall(atomic bool) InstallOption {
+	return func(i *action.Install) error {
+		i.Atomic = atomic
+		if atomic {
+			i.Wait = true
+		}
+		return nil
+	}
+}
+
+// DisableHooksInstall prevents chart hooks from running during an install.
+func DisableHooksInstall(disable bool) InstallOption {
+	return func(i *action.Install) error {
+		i.DisableHooks = disable
+		return nil
+	}
+}
+
+// SkipCRDsInstall skips installing the CRDs in a chart's crds directory.
+func SkipCRDsInstall(skip bool) InstallOption {
+	return func(i *action.Install) error {
+		i.SkipCRDs = skip
+		return nil
+	}
+}
+
+// WaitUpgrade waits until all release resources are ready, up to the
+// upgrade timeout, before marking an upgrade as successful.
+func WaitUpgrade(wait bool) UpgradeOption {
+	return func(u *action.Upgrade) error {
+		u.Wait = wait
+		return nil
+	}
+}
+
+// TimeoutUpgrade sets the time to wait for Kubernetes operations, including
+// hooks, during an upgrade.
+func TimeoutUpgrade(timeout time.Duration) UpgradeOption {
+	return func(u *action.Upgrade) error {
+		u.Timeout = timeout
+		return nil
+	}
+}
+
+// AtomicUpgrade rolls a release back if its upgrade fails. It implies
+// WaitUpgrade.
+func AtomicUpgrade(atomic bool) UpgradeOption {
+	return func(u *action.Upgrade) error {
+		u.Atomic = atomic
+		if atomic {
+			u.Wait = true
+		}
+		return nil
+	}
+}
+
+// DisableHooksUpgrade prevents chart hooks from running during an upgrade.
+func DisableHooksUpgrade(disable bool) UpgradeOption {
+	return func(u *action.Upgrade) error {
+		u.DisableHooks = disable
+		return nil
+	}
+}
+
+// SkipCRDsUpgrade skips the CRDs in a chart's crds directory during an
+// upgrade.
+func SkipCRDsUpgrade(skip bool) UpgradeOption {
+	return func(u *action.Upgrade) error {
+		u.SkipCRDs = skip
+		return nil
+	}
+}
+
+// MaxHistoryUpgrade limits the number of revisions kept per release after an
+// upgrade. Zero means no limit.
+func MaxHistoryUpgrade(maxHistory int) UpgradeOption {
+	return func(u *action.Upgrade) error {
+		u.MaxHistory = maxHistory
+		return nil
+	}
+}
+
+// TimeoutUninstall sets the time to wait for Kubernetes operations, including
+// hooks, during an uninstall.
+func TimeoutUninstall(timeout time.Duration) UninstallOption {
+	return func(u *action.Uninstall) error {
+		u.Timeout = timeout
+		return nil
+	}
+}
+
+// DisableHooksUninstall prevents chart hooks from running during an
+// uninstall.
+func DisableHooksUninstall(disable bool) UninstallOption {
+	return func(u *action.Uninstall) error {
+		u.DisableHooks = disable
+		return nil
+	}
+}
+
+// WaitRollback waits until all release resources are ready, up to the
+// rollback timeout, before marking a rollback as successful.
+func WaitRollback(wait bool) RollbackOption {
+	return func(r *action.Rollback) error {
+		r.Wait = wait
+		return nil
+	}
+}
+
+// TimeoutRollback sets the time to wait for Kubernetes operations, including
+// hooks, during a rollback.
+func TimeoutRollback(timeout time.Duration) RollbackOption {
+	return func(r *action.Rollback) error {
+		r.Timeout = timeout
+		return nil
+	}
+}
+
+// DisableHooksRollback prevents chart hooks from running during a rollback.
+func DisableHooksRollback(disable bool) RollbackOption {
+	return func(r *action.Rollback) error {
+		r.DisableHooks = disable
+		return nil
+	}
+}
+
+// MaxHistoryRollback limits the number of revisions kept per release after a
+// rollback. Zero means no limit.
+func MaxHistoryRollback(maxHistory int) RollbackOption {
+	return func(r *action.Rollback) error {
+		r.MaxHistory = maxHistory
+		return nil
+	}
+}
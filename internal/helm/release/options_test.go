@@ -0,0 +1,67 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/action"
+)
+
+func TestInstallOptions(t *testing.T) {
+	i := action.NewInstall(&action.Configuration{})
+	for _, o := range []InstallOption{
+		AtomicInstall(true),
+		TimeoutInstall(time.Minute),
+		DisableHooksInstall(true),
+		SkipCRDsInstall(true),
+	} {
+		assert.NoError(t, o(i))
+	}
+	assert.True(t, i.Atomic)
+	assert.True(t, i.Wait, "atomic installs must wait")
+	assert.Equal(t, time.Minute, i.Timeout)
+	assert.True(t, i.DisableHooks)
+	assert.True(t, i.SkipCRDs)
+}
+
+func TestUpgradeOptions(t *testing.T) {
+	u := action.NewUpgrade(&action.Configuration{})
+	for _, o := range []UpgradeOption{
+		AtomicUpgrade(true),
+		TimeoutUpgrade(time.Minute),
+		DisableHooksUpgrade(true),
+		SkipCRDsUpgrade(true),
+		MaxHistoryUpgrade(3),
+	} {
+		assert.NoError(t, o(u))
+	}
+	assert.True(t, u.Atomic)
+	assert.True(t, u.Wait, "atomic upgrades must wait")
+	assert.Equal(t, time.Minute, u.Timeout)
+	assert.True(t, u.DisableHooks)
+	assert.True(t, u.SkipCRDs)
+	assert.Equal(t, 3, u.MaxHistory)
+}
+
+func TestWaitOptionsWithoutAtomic(t *testing.T) {
+	i := action.NewInstall(&action.Configuration{})
+	assert.NoError(t, WaitInstall(true)(i))
+	assert.NoError(t, AtomicInstall(false)(i))
+	assert.True(t, i.Wait)
+	assert.False(t, i.Atomic)
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/operator-framework/operator-sdk/internal/helm/watches"
+)
+
+const (
+	// maxReleaseNameLen is the maximum length of a Helm release name.
+	maxReleaseNameLen = 53
+	// releaseNameHashLen is the number of hex characters of the hash
+	// appended to shortened release names.
+	releaseNameHashLen = 8
+)
+
+// ReleaseNameOptions configures how the release name of a CR is derived.
+type ReleaseNameOptions struct {
+	// Template is executed with the CR's watches.ReleaseNameData. If nil, the
+	// CR name is used.
+	Template *template.Template
+	// Suffix is appended to the name before it is shortened, ex. to name
+	// each release of a CR composed of several charts.
+	Suffix string
+	// Hash shortens names longer than 53 characters by truncating them and
+	// appending a hash of the full name.
+	Hash bool
+}
+
+// releaseName returns the release name of cr.
+func (o ReleaseNameOptions) releaseName(cr *unstructured.Unstructured) (string, error) {
+	name := cr.GetName()
+	if o.Template != nil {
+		gvk := cr.GroupVersionKind()
+		data := watches.ReleaseNameData{
+			Group:     gvk.Group,
+			Version:   gvk.Version,
+			Kind:      gvk.Kind,
+			Namespace: cr.GetNamespace(),
+			Name:      cr.GetName(),
+		}
+		var b strings.Builder
+		if err := o.Template.Execute(&b, data); err != nil {
+			return "", fmt.Errorf("failed to execute release name template: %w", err)
+		}
+		// Kinds are conventionally capitalized, but release names must be
+		// lowercase.
+		name = strings.ToLower(b.String())
+	}
+	name += o.Suffix
+	if o.Hash && len(name) > maxReleaseNameLen {
+		name = hashReleaseName(name)
+	}
+	// Invalid CR names are left to Helm to reject, as they were before
+	// release names could be configured.
+	if o.Template != nil || o.Hash || o.Suffix != "" {
+		if err := chartutil.ValidateReleaseName(name); err != nil {
+			return "", fmt.Errorf("invalid release name %q: %w", name, err)
+		}
+	}
+	return name, nil
+}
+
+// hashReleaseName truncates name and appends a hash of the full name, so that
+// distinct long names remain distinct.
+func hashReleaseName(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	prefix := strings.TrimRight(name[:maxReleaseNameLen-releaseNameHashLen-1], "-.")
+	return prefix + "-" + hex.EncodeToString(sum[:])[:releaseNameHashLen]
+}
+
+// renameRelease moves the release history of a CR from the release name it
+// was previously deployed with to its current release name. It returns true
+// if any release versions were moved.
+func renameRelease(storageBackend *storage.Storage, crNamespace, from, to string) (bool, error) {
+	if from == "" || from == to {
+		return false, nil
+	}
+	history, exists, err := releaseHistory(storageBackend, from)
+	if err != nil || !exists {
+		return false, err
+	}
+
+	var moved []int
+	for _, rel := range history {
+		if rel.Namespace != "" && rel.Namespace != crNamespace {
+			continue
+		}
+		renamed := *rel
+		renamed.Name = to
+		// A previous rename may have been interrupted after creating some
+		// of the versions.
+		if err := storageBackend.Create(&renamed); err != nil && !errors.Is(err, driver.ErrReleaseExists) {
+			return false, fmt.Errorf("failed to rename release %q to %q: %w", from, to, err)
+		}
+		moved = append(moved, rel.Version)
+	}
+	for _, version := range moved {
+		if _, err := storageBackend.Delete(from, version); err != nil && !notFoundErr(err) {
+			return false, fmt.Errorf("failed to delete release %q version %d: %w", from, version, err)
+		}
+	}
+	return len(moved) > 0, nil
+}
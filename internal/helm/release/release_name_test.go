@@ -0,0 +1,153 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/operator-framework/operator-sdk/internal/helm/watches"
+)
+
+func newReleaseNameCR(name string) *unstructured.Unstructured {
+	cr := &unstructured.Unstructured{}
+	cr.SetGroupVersionKind(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Nginx"})
+	cr.SetNamespace("ns")
+	cr.SetName(name)
+	return cr
+}
+
+func TestReleaseName(t *testing.T) {
+	tmpl, err := watches.ParseReleaseNameTemplate("{{.Kind}}-{{.Name}}")
+	assert.NoError(t, err)
+	longName := strings.Repeat("a", 60)
+
+	tests := []struct {
+		name      string
+		opts      ReleaseNameOptions
+		crName    string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "default",
+			crName:   "web",
+			expected: "web",
+		},
+		{
+			name:     "default long name",
+			crName:   longName,
+			expected: longName,
+		},
+		{
+			name:     "template",
+			opts:     ReleaseNameOptions{Template: tmpl},
+			crName:   "web",
+			expected: "nginx-web",
+		},
+		{
+			name:      "template long name",
+			opts:      ReleaseNameOptions{Template: tmpl},
+			crName:    longName,
+			expectErr: true,
+		},
+		{
+			name:     "hashed long name",
+			opts:     ReleaseNameOptions{Template: tmpl, Hash: true},
+			crName:   longName,
+			expected: hashReleaseName("nginx-" + longName),
+		},
+		{
+			name:     "hashed short name",
+			opts:     ReleaseNameOptions{Hash: true},
+			crName:   "web",
+			expected: "web",
+		},
+		{
+			name:     "suffix",
+			opts:     ReleaseNameOptions{Template: tmpl, Suffix: "-db"},
+			crName:   "web",
+			expected: "nginx-web-db",
+		},
+		{
+			name:      "suffix long name",
+			opts:      ReleaseNameOptions{Suffix: "-db"},
+			crName:    longName,
+			expectErr: true,
+		},
+		{
+			name:     "hashed suffix long name",
+			opts:     ReleaseNameOptions{Suffix: "-db", Hash: true},
+			crName:   longName,
+			expected: hashReleaseName(longName + "-db"),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			name, err := tc.opts.releaseName(newReleaseNameCR(tc.crName))
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, name)
+		})
+	}
+}
+
+func TestHashReleaseName(t *testing.T) {
+	a := hashReleaseName(strings.Repeat("a", 43) + "-" + strings.Repeat("b", 20))
+	b := hashReleaseName(strings.Repeat("a", 43) + "-" + strings.Repeat("c", 20))
+	assert.Len(t, a, 52, "trailing dash of the prefix is trimmed")
+	assert.True(t, strings.HasPrefix(a, strings.Repeat("a", 43)+"-"))
+	assert.NotEqual(t, a, b)
+}
+
+func TestRenameRelease(t *testing.T) {
+	s := storage.Init(driver.NewMemory())
+	for v := 1; v <= 2; v++ {
+		assert.NoError(t, s.Create(newTestStoredRelease("web", "ns", v)))
+	}
+	assert.NoError(t, s.Create(newTestStoredRelease("other", "ns", 1)))
+
+	renamed, err := renameRelease(s, "ns", "web", "nginx-web")
+	assert.NoError(t, err)
+	assert.True(t, renamed)
+
+	history, exists, err := releaseHistory(s, "nginx-web")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Len(t, history, 2)
+	for _, rel := range history {
+		assert.Equal(t, "nginx-web", rel.Name)
+	}
+	_, exists, err = releaseHistory(s, "web")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	renamed, err = renameRelease(s, "ns", "web", "nginx-web")
+	assert.NoError(t, err)
+	assert.False(t, renamed, "already renamed")
+
+	renamed, err = renameRelease(s, "other-ns", "other", "nginx-other")
+	assert.NoError(t, err)
+	assert.False(t, renamed, "release of another namespace")
+}
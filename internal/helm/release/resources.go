@@ -0,0 +1,90 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// ReleaseResource is a resource of a release manifest.
+type ReleaseResource struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	// Object is the resource in the cluster, or nil if it does not exist.
+	Object *unstructured.Unstructured
+}
+
+// observedResources are the resources of a release manifest as observed in the
+// cluster.
+type observedResources struct {
+	manifest  string
+	resources []ReleaseResource
+}
+
+// ReleaseResources returns the resources of a release manifest, in manifest
+// order, with their current state in the cluster. The resources observed by
+// ReconcileRelease for the same manifest are returned as is.
+func (m manager) ReleaseResources(_ context.Context, manifest string) ([]ReleaseResource, error) {
+	if m.observed != nil && m.observed.resources != nil && m.observed.manifest == manifest {
+		return m.observed.resources, nil
+	}
+	infos, err := m.kubeClient.Build(bytes.NewBufferString(manifest), false)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]ReleaseResource, 0, len(infos))
+	for _, info := range infos {
+		gvk := info.Mapping.GroupVersionKind
+		res := ReleaseResource{
+			APIVersion: gvk.GroupVersion().String(),
+			Kind:       gvk.Kind,
+			Namespace:  info.Namespace,
+			Name:       info.Name,
+		}
+		obj, err := resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+		if apierrors.IsNotFound(err) {
+			resources = append(resources, res)
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("could not get object %s/%s: %w", gvk.Kind, info.Name, err)
+		}
+		if res.Object, err = toUnstructured(obj); err != nil {
+			return nil, err
+		}
+		resources = append(resources, res)
+	}
+	return resources, nil
+}
+
+func toUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u, nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: content}, nil
+}
@@ -0,0 +1,402 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+// Release storage drivers supported by a manager factory.
+const (
+	StorageDriverSecret    = "secret"
+	StorageDriverConfigMap = "configmap"
+	StorageDriverMemory    = "memory"
+	StorageDriverSQL       = "sql"
+)
+
+// StorageOptions configures the backend in which a manager factory stores
+// release history.
+type StorageOptions struct {
+	// Driver is one of the StorageDriver constants. Defaults to
+	// StorageDriverSecret.
+	Driver string
+	// Namespace is the namespace in which releases are stored. Defaults to
+	// the namespace of each custom resource. Ignored by StorageDriverMemory.
+	Namespace string
+	// SQLConnectionString is the connection string of the PostgreSQL
+	// database used by StorageDriverSQL.
+	SQLConnectionString string
+}
+
+// Validate returns an error if o does not configure a supported storage
+// backend.
+func (o StorageOptions) Validate() error {
+	switch o.Driver {
+	case "", StorageDriverSecret, StorageDriverConfigMap, StorageDriverMemory:
+	case StorageDriverSQL:
+		if o.SQLConnectionString == "" {
+			return errors.New("a connection string is required by the sql release storage driver")
+		}
+	default:
+		return fmt.Errorf("unknown release storage driver %q: must be one of %s, %s, %s or %s", o.Driver,
+			StorageDriverSecret, StorageDriverConfigMap, StorageDriverMemory, StorageDriverSQL)
+	}
+	return nil
+}
+
+type storageKey struct {
+	driver    string
+	namespace string
+}
+
+// storageFactory creates and caches release storage backends, and migrates
+// release history into them from other backends.
+type storageFactory struct {
+	opts StorageOptions
+	cfg  *rest.Config
+
+	mu       sync.Mutex
+	coreV1   v1.CoreV1Interface
+	backends map[storageKey]*storage.Storage
+	checked  map[string]struct{}
+}
+
+func newStorageFactory(cfg *rest.Config, opts StorageOptions) *storageFactory {
+	if opts.Driver == "" {
+		opts.Driver = StorageDriverSecret
+	}
+	return &storageFactory{
+		opts:     opts,
+		cfg:      cfg,
+		backends: map[storageKey]*storage.Storage{},
+		checked:  map[string]struct{}{},
+	}
+}
+
+// storageFor returns the configured storage backend for releases of custom
+// resources in namespace crNamespace.
+func (f *storageFactory) storageFor(crNamespace string) (*storage.Storage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.backend(f.targetKey(crNamespace))
+	if err != nil {
+		return nil, err
+	}
+	if f.shared() {
+		return storage.Init(&namespacedDriver{Driver: s.Driver, namespace: crNamespace}), nil
+	}
+	return s, nil
+}
+
+// shared returns true if the releases of custom resources in different
+// namespaces are stored in the same backend.
+func (f *storageFactory) shared() bool {
+	return f.opts.Namespace != "" && f.opts.Driver != StorageDriverMemory
+}
+
+func (f *storageFactory) targetKey(crNamespace string) storageKey {
+	ns := f.opts.Namespace
+	if ns == "" || f.opts.Driver == StorageDriverMemory {
+		ns = crNamespace
+	}
+	return storageKey{driver: f.opts.Driver, namespace: ns}
+}
+
+// backend returns the storage backend for key, creating it on first use.
+// f.mu must be held by the caller.
+func (f *storageFactory) backend(key storageKey) (*storage.Storage, error) {
+	if s, ok := f.backends[key]; ok {
+		return s, nil
+	}
+	if f.coreV1 == nil && (key.driver == StorageDriverSecret || key.driver == StorageDriverConfigMap) {
+		clientv1, err := v1.NewForConfig(f.cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get core/v1 client: %w", err)
+		}
+		f.coreV1 = clientv1
+	}
+
+	var d driver.Driver
+	switch key.driver {
+	case StorageDriverSecret:
+		d = driver.NewSecrets(f.coreV1.Secrets(key.namespace))
+	case StorageDriverConfigMap:
+		d = driver.NewConfigMaps(f.coreV1.ConfigMaps(key.namespace))
+	case StorageDriverMemory:
+		m := driver.NewMemory()
+		m.SetNamespace(key.namespace)
+		d = m
+	case StorageDriverSQL:
+		s, err := driver.NewSQL(f.opts.SQLConnectionString, func(string, ...interface{}) {}, key.namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to SQL release storage: %w", err)
+		}
+		d = s
+	default:
+		return nil, fmt.Errorf("unknown release storage driver %q", key.driver)
+	}
+	s := storage.Init(d)
+	f.backends[key] = s
+	return s, nil
+}
+
+// migrate moves the history of the release named releaseName for a custom
+// resource in crNamespace into target, if target does not already contain
+// it. The Secret and ConfigMap backends in crNamespace and in the configured
+// storage namespace are searched for existing history. Each release is
+// checked at most once per factory. Nothing is migrated into memory storage,
+// which would lose the history when the operator restarts.
+func (f *storageFactory) migrate(target *storage.Storage, crNamespace, releaseName string) error {
+	if f.opts.Driver == StorageDriverMemory {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	checkedKey := crNamespace + "/" + releaseName
+	if _, ok := f.checked[checkedKey]; ok {
+		return nil
+	}
+
+	_, exists, err := releaseHistory(target, releaseName)
+	if err != nil {
+		return err
+	}
+	if exists {
+		f.checked[checkedKey] = struct{}{}
+		return nil
+	}
+
+	targetKey := f.targetKey(crNamespace)
+	for _, key := range f.migrationSources(crNamespace) {
+		// The releases in a shared backend are stored under qualified names,
+		// so its releases stored under their plain names are migrated too.
+		if key == targetKey && !f.shared() {
+			continue
+		}
+		source, err := f.backend(key)
+		if err != nil {
+			return err
+		}
+		history, exists, err := releaseHistory(source, releaseName)
+		if apierrors.IsForbidden(err) {
+			log.V(1).Info("Skipping release storage migration source", "driver", key.driver,
+				"namespace", key.namespace, "reason", err.Error())
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get release history from %s storage in namespace %q: %w",
+				key.driver, key.namespace, err)
+		}
+		if !exists {
+			continue
+		}
+		if err := moveReleases(history, crNamespace, source, target); err != nil {
+			return fmt.Errorf("failed to migrate release %q from %s storage in namespace %q: %w",
+				releaseName, key.driver, key.namespace, err)
+		}
+		log.Info("Migrated release history", "release", releaseName, "namespace", crNamespace,
+			"fromDriver", key.driver, "fromNamespace", key.namespace,
+			"toDriver", targetKey.driver, "toNamespace", targetKey.namespace)
+		break
+	}
+	f.checked[checkedKey] = struct{}{}
+	return nil
+}
+
+func (f *storageFactory) migrationSources(crNamespace string) []storageKey {
+	namespaces := []string{crNamespace}
+	if f.opts.Namespace != "" && f.opts.Namespace != crNamespace {
+		namespaces = append(namespaces, f.opts.Namespace)
+	}
+	var keys []storageKey
+	for _, ns := range namespaces {
+		for _, d := range []string{StorageDriverSecret, StorageDriverConfigMap} {
+			keys = append(keys, storageKey{driver: d, namespace: ns})
+		}
+	}
+	return keys
+}
+
+// moveReleases copies the releases in history that belong to crNamespace from
+// source to target, and then deletes them from source.
+func moveReleases(history []*rpb.Release, crNamespace string, source, target *storage.Storage) error {
+	var moved []*rpb.Release
+	for _, rel := range history {
+		if rel.Namespace != "" && rel.Namespace != crNamespace {
+			continue
+		}
+		if err := target.Create(rel); err != nil && !errors.Is(err, driver.ErrReleaseExists) {
+			return err
+		}
+		moved = append(moved, rel)
+	}
+	for _, rel := range moved {
+		if _, err := source.Delete(rel.Name, rel.Version); err != nil && !notFoundErr(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// namespacedDriver stores the releases of custom resources in namespace in a
+// backend shared with other namespaces. Releases are stored under names
+// qualified with the namespace, so that the releases of same-named custom
+// resources in different namespaces do not collide.
+type namespacedDriver struct {
+	driver.Driver
+	namespace string
+}
+
+// maxStoredNameLen is the maximum length of a stored release name, which the
+// Secret and ConfigMap drivers set as a label value.
+const maxStoredNameLen = 63
+
+// prefix returns the prefix that qualifies the stored name of the release
+// named name: the namespace, or a hash of the namespace if the qualified name
+// would be too long to be a label value.
+func (d *namespacedDriver) prefix(name string) string {
+	if p := d.namespace + "."; len(p)+len(name) <= maxStoredNameLen {
+		return p
+	}
+	return d.hashPrefix()
+}
+
+func (d *namespacedDriver) hashPrefix() string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(d.namespace)))[:8] + "."
+}
+
+func (d *namespacedDriver) qualify(name string) string {
+	return d.prefix(name) + name
+}
+
+// unqualify returns the name of a release stored under storedName, or false
+// if storedName is not qualified with the namespace.
+func (d *namespacedDriver) unqualify(storedName string) (string, bool) {
+	for _, p := range []string{d.namespace + ".", d.hashPrefix()} {
+		if name := strings.TrimPrefix(storedName, p); name != storedName && d.prefix(name) == p {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// qualifyKey qualifies the release name in a storage key, which has the
+// format <storage type>.<release name>.v<release version>.
+func (d *namespacedDriver) qualifyKey(key string) string {
+	typePrefix := storage.HelmStorageType + "."
+	name := strings.TrimPrefix(key, typePrefix)
+	if i := strings.LastIndex(name, ".v"); i >= 0 {
+		name = name[:i]
+	}
+	return typePrefix + d.prefix(name) + strings.TrimPrefix(key, typePrefix)
+}
+
+// own returns a copy of rls with its name unqualified, or false if rls
+// belongs to another namespace.
+func (d *namespacedDriver) own(rls *rpb.Release) (*rpb.Release, bool) {
+	if rls == nil || rls.Namespace != d.namespace {
+		return nil, false
+	}
+	name, ok := d.unqualify(rls.Name)
+	if !ok {
+		return nil, false
+	}
+	owned := *rls
+	owned.Name = name
+	return &owned, true
+}
+
+func (d *namespacedDriver) stored(rls *rpb.Release) *rpb.Release {
+	stored := *rls
+	stored.Name = d.qualify(rls.Name)
+	return &stored
+}
+
+func (d *namespacedDriver) Create(key string, rls *rpb.Release) error {
+	return d.Driver.Create(d.qualifyKey(key), d.stored(rls))
+}
+
+func (d *namespacedDriver) Update(key string, rls *rpb.Release) error {
+	return d.Driver.Update(d.qualifyKey(key), d.stored(rls))
+}
+
+func (d *namespacedDriver) Delete(key string) (*rpb.Release, error) {
+	rls, err := d.Driver.Delete(d.qualifyKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if rls, ok := d.own(rls); ok {
+		return rls, nil
+	}
+	return nil, driver.ErrReleaseNotFound
+}
+
+func (d *namespacedDriver) Get(key string) (*rpb.Release, error) {
+	rls, err := d.Driver.Get(d.qualifyKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if rls, ok := d.own(rls); ok {
+		return rls, nil
+	}
+	return nil, driver.ErrReleaseNotFound
+}
+
+func (d *namespacedDriver) List(filter func(*rpb.Release) bool) ([]*rpb.Release, error) {
+	var owned []*rpb.Release
+	_, err := d.Driver.List(func(rls *rpb.Release) bool {
+		if rls, ok := d.own(rls); ok && filter(rls) {
+			owned = append(owned, rls)
+		}
+		return false
+	})
+	return owned, err
+}
+
+func (d *namespacedDriver) Query(labels map[string]string) ([]*rpb.Release, error) {
+	qualified := make(map[string]string, len(labels))
+	for k, v := range labels {
+		qualified[k] = v
+	}
+	if name, ok := labels["name"]; ok {
+		qualified["name"] = d.qualify(name)
+	}
+	results, err := d.Driver.Query(qualified)
+	if err != nil {
+		return nil, err
+	}
+	var owned []*rpb.Release
+	for _, rls := range results {
+		if rls, ok := d.own(rls); ok {
+			owned = append(owned, rls)
+		}
+	}
+	if len(owned) == 0 {
+		return nil, driver.ErrReleaseNotFound
+	}
+	return owned, nil
+}
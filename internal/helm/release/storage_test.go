@@ -0,0 +1,168 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func newTestStorageFactory(opts StorageOptions, crNamespace string) *storageFactory {
+	f := newStorageFactory(&rest.Config{}, opts)
+	// Replace the Secret and ConfigMap backends with in-memory ones.
+	for _, key := range append(f.migrationSources(crNamespace), f.targetKey(crNamespace)) {
+		f.backends[key] = storage.Init(driver.NewMemory())
+	}
+	return f
+}
+
+func newTestStoredRelease(name, namespace string, version int) *rpb.Release {
+	return rpb.Mock(&rpb.MockReleaseOptions{
+		Name:      name,
+		Namespace: namespace,
+		Version:   version,
+	})
+}
+
+func TestStorageFactoryTargetKey(t *testing.T) {
+	f := newStorageFactory(&rest.Config{}, StorageOptions{})
+	assert.Equal(t, storageKey{driver: StorageDriverSecret, namespace: "ns"}, f.targetKey("ns"))
+
+	f = newStorageFactory(&rest.Config{}, StorageOptions{Driver: StorageDriverConfigMap, Namespace: "central"})
+	assert.Equal(t, storageKey{driver: StorageDriverConfigMap, namespace: "central"}, f.targetKey("ns"))
+
+	f = newStorageFactory(&rest.Config{}, StorageOptions{Driver: StorageDriverMemory, Namespace: "central"})
+	assert.Equal(t, storageKey{driver: StorageDriverMemory, namespace: "ns"}, f.targetKey("ns"))
+}
+
+func TestStorageOptionsValidate(t *testing.T) {
+	assert.NoError(t, StorageOptions{}.Validate())
+	assert.NoError(t, StorageOptions{Driver: StorageDriverConfigMap}.Validate())
+	assert.NoError(t, StorageOptions{Driver: StorageDriverSQL, SQLConnectionString: "postgres://db"}.Validate())
+	assert.Error(t, StorageOptions{Driver: StorageDriverSQL}.Validate())
+	assert.Error(t, StorageOptions{Driver: "etcd"}.Validate())
+}
+
+func TestStorageFactorySharedNamespace(t *testing.T) {
+	f := newStorageFactory(&rest.Config{}, StorageOptions{Driver: StorageDriverConfigMap, Namespace: "central"})
+	shared := storage.Init(driver.NewConfigMaps(fake.NewSimpleClientset().CoreV1().ConfigMaps("central")))
+	f.backends[f.targetKey("a")] = shared
+	a, err := f.storageFor("a")
+	assert.NoError(t, err)
+	b, err := f.storageFor("b")
+	assert.NoError(t, err)
+
+	assert.NoError(t, a.Create(newTestStoredRelease("test", "a", 1)))
+	assert.NoError(t, b.Create(newTestStoredRelease("test", "b", 1)))
+	assert.NoError(t, b.Create(newTestStoredRelease("test", "b", 2)))
+
+	history, err := a.History("test")
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+	assert.Equal(t, "test", history[0].Name)
+	assert.Equal(t, "a", history[0].Namespace)
+
+	rel, err := b.Get("test", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "test", rel.Name)
+	assert.Equal(t, "b", rel.Namespace)
+
+	deployed, err := b.ListDeployed()
+	assert.NoError(t, err)
+	assert.Len(t, deployed, 2)
+
+	_, err = shared.Get("a.test", 1)
+	assert.NoError(t, err)
+
+	_, err = a.Delete("test", 1)
+	assert.NoError(t, err)
+	_, err = b.Get("test", 1)
+	assert.NoError(t, err)
+
+	// Names that would be too long to be label values are qualified with a
+	// hash of the namespace.
+	longNamespace, longName := strings.Repeat("n", 20), strings.Repeat("x", 50)
+	c, err := f.storageFor(longNamespace)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Create(newTestStoredRelease(longName, longNamespace, 1)))
+	rel, err = c.Get(longName, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, longName, rel.Name)
+	_, err = shared.Get(longNamespace+"."+longName, 1)
+	assert.Error(t, err)
+}
+
+func TestStorageFactoryMigrate(t *testing.T) {
+	f := newTestStorageFactory(StorageOptions{Driver: StorageDriverConfigMap, Namespace: "central"}, "ns")
+	source := f.backends[storageKey{driver: StorageDriverSecret, namespace: "ns"}]
+	target, err := f.storageFor("ns")
+	assert.NoError(t, err)
+
+	for v := 1; v <= 2; v++ {
+		assert.NoError(t, source.Create(newTestStoredRelease("test", "ns", v)))
+	}
+
+	assert.NoError(t, f.migrate(target, "ns", "test"))
+
+	history, exists, err := releaseHistory(target, "test")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Len(t, history, 2)
+
+	_, exists, err = releaseHistory(source, "test")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestStorageFactoryMigrateSkipsOtherNamespaces(t *testing.T) {
+	f := newTestStorageFactory(StorageOptions{Driver: StorageDriverConfigMap, Namespace: "central"}, "ns")
+	source := f.backends[storageKey{driver: StorageDriverSecret, namespace: "central"}]
+	target, err := f.storageFor("ns")
+	assert.NoError(t, err)
+
+	assert.NoError(t, source.Create(newTestStoredRelease("test", "other", 1)))
+
+	assert.NoError(t, f.migrate(target, "ns", "test"))
+
+	_, exists, err := releaseHistory(target, "test")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestStorageFactoryMigrateMemory(t *testing.T) {
+	f := newTestStorageFactory(StorageOptions{Driver: StorageDriverMemory}, "ns")
+	source := f.backends[storageKey{driver: StorageDriverSecret, namespace: "ns"}]
+	target, err := f.storageFor("ns")
+	assert.NoError(t, err)
+
+	assert.NoError(t, source.Create(newTestStoredRelease("test", "ns", 1)))
+
+	assert.NoError(t, f.migrate(target, "ns", "test"))
+
+	_, exists, err := releaseHistory(target, "test")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	_, exists, err = releaseHistory(source, "test")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
@@ -20,10 +20,17 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
+	"text/template"
 
 	"helm.sh/helm/v3/pkg/chartutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/jsonpath"
 	"sigs.k8s.io/yaml"
+
+	"github.com/operator-framework/operator-sdk/internal/helm/internal/types"
 )
 
 const WatchesFile = "watches.yaml"
@@ -32,9 +39,250 @@ const WatchesFile = "watches.yaml"
 // custom resource.
 type Watch struct {
 	schema.GroupVersionKind `json:",inline"`
-	ChartDir                string            `json:"chart"`
+	ChartDir                string            `json:"chart,omitempty"`
+	ChartSource             *ChartSource      `json:"chartSource,omitempty"`
 	WatchDependentResources *bool             `json:"watchDependentResources,omitempty"`
 	OverrideValues          map[string]string `json:"overrideValues,omitempty"`
+	ReleaseStorage          *ReleaseStorage   `json:"releaseStorage,omitempty"`
+	RollbackOnFailure       bool              `json:"rollbackOnFailure,omitempty"`
+
+	// Wait waits until a release's resources are ready before an install,
+	// upgrade or rollback is considered successful.
+	Wait bool `json:"wait,omitempty"`
+	// Timeout is the time to wait for Kubernetes operations, including
+	// hooks. Defaults to 5m if Wait or Atomic is set, and to no timeout
+	// otherwise.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// Atomic uninstalls a release whose install fails, and rolls back a
+	// release whose upgrade fails. It implies Wait.
+	Atomic bool `json:"atomic,omitempty"`
+	// DisableHooks prevents chart hooks from running.
+	DisableHooks bool `json:"disableHooks,omitempty"`
+	// SkipCRDs skips the CRDs in a chart's crds directory.
+	SkipCRDs bool `json:"skipCRDs,omitempty"`
+	// MaxHistory is the number of revisions kept per release. Defaults to 10.
+	MaxHistory int `json:"maxHistory,omitempty"`
+
+	// Selector restricts the watch to CRs whose labels match it.
+	Selector metav1.LabelSelector `json:"selector,omitempty"`
+	// NamespaceSelector restricts the watch to CRs in namespaces whose labels
+	// match it.
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Drift configures how changes made to release resources outside of the
+	// operator are handled.
+	Drift *DriftPolicy `json:"drift,omitempty"`
+
+	// PostRenderers modify the manifests rendered from the chart, in order,
+	// before they are applied.
+	PostRenderers []PostRenderer `json:"postRenderers,omitempty"`
+
+	// ReleaseNameTemplate is a Go template of the release name of a CR,
+	// executed with ReleaseNameData. Defaults to the CR name.
+	ReleaseNameTemplate string `json:"releaseNameTemplate,omitempty"`
+	// HashReleaseNames shortens release names longer than Helm's limit of 53
+	// characters by truncating them and appending a hash of the full name.
+	HashReleaseNames bool `json:"hashReleaseNames,omitempty"`
+
+	// StatusMappings copy fields of release resources into the CR status.
+	StatusMappings []StatusMapping `json:"statusMappings,omitempty"`
+
+	// ValidatingWebhook serves a validating admission webhook that rejects
+	// CRs whose values do not match the chart's values schema, or whose
+	// release name collides with another release.
+	ValidatingWebhook bool `json:"validatingWebhook,omitempty"`
+
+	// Charts composes a CR of the releases of several charts, which are
+	// installed in order. A chart's release is installed or upgraded once
+	// the releases of the charts before it are ready. Charts may not be set
+	// with ChartDir or ChartSource.
+	Charts []ComposedChart `json:"charts,omitempty"`
+}
+
+// ComposedChart is one of the charts of a watch that composes a CR of several
+// releases. Exactly one of ChartDir or ChartSource must be set.
+type ComposedChart struct {
+	// Name identifies the chart's release in the CR status. It must be a
+	// DNS label that is unique within the watch.
+	Name        string       `json:"name"`
+	ChartDir    string       `json:"chart,omitempty"`
+	ChartSource *ChartSource `json:"chartSource,omitempty"`
+	// ValuesPath is the dot-separated path of the chart's values in the CR
+	// spec, ex. "database". If unset, the chart's values are the whole spec.
+	ValuesPath string `json:"valuesPath,omitempty"`
+	// ReleaseNameSuffix is appended to the CR's release name to name the
+	// chart's release. Defaults to "-" followed by Name.
+	ReleaseNameSuffix string `json:"releaseNameSuffix,omitempty"`
+}
+
+// StatusMapping copies the value of a JSONPath expression evaluated on a
+// release resource into a field of the CR status.
+type StatusMapping struct {
+	// Field is the dot-separated path of the status field, ex.
+	// "web.readyReplicas".
+	Field string `json:"field"`
+	// APIVersion and Kind select the release resource. APIVersion is
+	// optional.
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind"`
+	// Name is the name of the release resource, which may reference
+	// {{ .Release.Name }} and {{ .Release.Namespace }}. If unset, the first
+	// release resource of the kind is selected.
+	Name string `json:"name,omitempty"`
+	// JSONPath is a kubectl-style JSONPath expression, ex.
+	// "{.status.readyReplicas}". The enclosing braces are optional.
+	JSONPath string `json:"jsonPath"`
+}
+
+// StatusMappingNameData is the data a status mapping's name is executed with.
+type StatusMappingNameData struct {
+	Release struct {
+		Name      string
+		Namespace string
+	}
+}
+
+// ParseStatusMappingName parses the name of a status mapping.
+func ParseStatusMappingName(name string) (*template.Template, error) {
+	return template.New("name").Option("missingkey=error").Parse(name)
+}
+
+// ParseStatusMappingJSONPath parses the JSONPath expression of a status
+// mapping. Missing keys yield no results rather than errors.
+func ParseStatusMappingJSONPath(expr string) (*jsonpath.JSONPath, error) {
+	if !strings.HasPrefix(expr, "{") {
+		expr = "{" + expr + "}"
+	}
+	jp := jsonpath.New("status").AllowMissingKeys(true)
+	if err := jp.Parse(expr); err != nil {
+		return nil, err
+	}
+	return jp, nil
+}
+
+// ReleaseNameData is the data a release name template is executed with.
+type ReleaseNameData struct {
+	Group     string
+	Version   string
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// ParseReleaseNameTemplate parses a release name template. The template must
+// render distinct names for CRs with distinct names, so that their releases
+// do not collide.
+func ParseReleaseNameTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("releaseName").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]struct{}{}
+	for _, name := range []string{"a", "b"} {
+		var b strings.Builder
+		data := ReleaseNameData{Group: "example.com", Version: "v1", Kind: "Example", Namespace: "ns", Name: name}
+		if err := tmpl.Execute(&b, data); err != nil {
+			return nil, err
+		}
+		names[b.String()] = struct{}{}
+	}
+	if len(names) != 2 {
+		return nil, errors.New("template must include the CR name, ex. {{.Name}}")
+	}
+	return tmpl, nil
+}
+
+// PostRenderer modifies the manifests rendered from a chart. Exactly one of
+// its fields must be set.
+type PostRenderer struct {
+	// Kustomize is an inline kustomization, ex. with commonLabels, images or
+	// patches, whose resources are the rendered manifests.
+	Kustomize map[string]interface{} `json:"kustomize,omitempty"`
+	// Patches are strategic merge or JSON 6902 patches applied to the
+	// rendered manifests.
+	Patches []Patch `json:"patches,omitempty"`
+	// Exec is the path of an executable that reads rendered manifests from
+	// stdin and writes the modified manifests to stdout.
+	Exec string `json:"exec,omitempty"`
+}
+
+// Patch is a strategic merge or JSON 6902 patch of rendered manifests.
+type Patch struct {
+	// Patch is the patch in YAML or JSON.
+	Patch string `json:"patch"`
+	// Target selects the manifests to patch. It is required for JSON 6902
+	// patches. Strategic merge patches without a target patch the manifest
+	// with the patch's kind and name.
+	Target *PatchTarget `json:"target,omitempty"`
+}
+
+// PatchTarget selects the manifests a patch applies to. Unset fields match
+// all manifests.
+type PatchTarget struct {
+	Group              string `json:"group,omitempty"`
+	Version            string `json:"version,omitempty"`
+	Kind               string `json:"kind,omitempty"`
+	Name               string `json:"name,omitempty"`
+	Namespace          string `json:"namespace,omitempty"`
+	LabelSelector      string `json:"labelSelector,omitempty"`
+	AnnotationSelector string `json:"annotationSelector,omitempty"`
+}
+
+const (
+	// DriftModeSelfHeal patches drifted resources to match the release
+	// manifest.
+	DriftModeSelfHeal = "selfHeal"
+	// DriftModeReport reports drifted resources without changing them.
+	DriftModeReport = "report"
+)
+
+// DriftPolicy configures how changes made to release resources outside of the
+// operator are handled.
+type DriftPolicy struct {
+	// Mode is one of "selfHeal", the default, or "report".
+	Mode string `json:"mode,omitempty"`
+	// Ignore lists fields that are neither reported nor healed when they
+	// drift, ex. the replicas of a Deployment scaled by an autoscaler.
+	Ignore []DriftIgnoreRule `json:"ignore,omitempty"`
+}
+
+// DriftIgnoreRule ignores drift of fields of matching release resources.
+type DriftIgnoreRule struct {
+	// Group, Kind and Name select the resources the rule applies to. Unset
+	// fields match all resources.
+	Group string `json:"group,omitempty"`
+	Kind  string `json:"kind,omitempty"`
+	Name  string `json:"name,omitempty"`
+	// Paths are JSON pointers to the ignored fields, ex. "/spec/replicas".
+	// A "*" token matches any map key or list index.
+	Paths []string `json:"paths"`
+}
+
+// ReleaseStorage configures where release history is stored for a watch.
+// Unset fields default to the helm-operator's release storage flags.
+type ReleaseStorage struct {
+	// Driver is one of "secret", "configmap", "memory" or "sql".
+	Driver string `json:"driver,omitempty"`
+	// Namespace is the namespace in which releases are stored, instead of
+	// the namespace of each custom resource.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// ChartSource defines a remote location from which a watch's chart is
+// fetched. Exactly one of Repository or OCI must be set.
+type ChartSource struct {
+	// Repository is the URL of a chart repository containing an index.yaml.
+	Repository string `json:"repository,omitempty"`
+	// Name is the name of the chart in Repository.
+	Name string `json:"name,omitempty"`
+	// Version is the exact version of the chart in Repository.
+	Version string `json:"version,omitempty"`
+	// OCI is a reference to a chart in an OCI registry, ex.
+	// "registry.example.com/charts/foo:1.2.3". An "oci://" prefix is allowed.
+	OCI string `json:"oci,omitempty"`
+	// Digest is the expected digest of the chart archive, ex. "sha256:<hex>".
+	// If set, a fetched or cached chart archive is only used if it matches.
+	Digest string `json:"digest,omitempty"`
 }
 
 // UnmarshalYAML unmarshals an individual watch from the Helm watches.yaml file
@@ -98,10 +346,75 @@ func LoadReader(reader io.Reader) ([]Watch, error) {
 			return nil, fmt.Errorf("invalid GVK: %s: %w", gvk, err)
 		}
 
-		if _, err := chartutil.IsChartDir(w.ChartDir); err != nil {
+		if len(w.Charts) != 0 {
+			if w.ChartDir != "" || w.ChartSource != nil {
+				return nil, fmt.Errorf("invalid watch for %s: only one of chart, chartSource and charts may be set", gvk)
+			}
+			if err := verifyComposedCharts(w.Charts); err != nil {
+				return nil, fmt.Errorf("invalid charts for %s: %w", gvk, err)
+			}
+			// Each of these options applies to a single release.
+			if len(w.StatusMappings) != 0 {
+				return nil, fmt.Errorf("invalid watch for %s: statusMappings may not be set with charts", gvk)
+			}
+			if w.RollbackOnFailure {
+				return nil, fmt.Errorf("invalid watch for %s: rollbackOnFailure may not be set with charts", gvk)
+			}
+		} else if w.ChartSource != nil {
+			if w.ChartDir != "" {
+				return nil, fmt.Errorf("invalid watch for %s: only one of chart and chartSource may be set", gvk)
+			}
+			if err := verifyChartSource(*w.ChartSource); err != nil {
+				return nil, fmt.Errorf("invalid chart source for %s: %w", gvk, err)
+			}
+		} else if _, err := chartutil.IsChartDir(w.ChartDir); err != nil {
 			return nil, fmt.Errorf("invalid chart directory %s: %w", w.ChartDir, err)
 		}
 
+		if w.ReleaseStorage != nil {
+			if err := verifyReleaseStorage(*w.ReleaseStorage); err != nil {
+				return nil, fmt.Errorf("invalid release storage for %s: %w", gvk, err)
+			}
+		}
+
+		if w.Timeout != nil && w.Timeout.Duration < 0 {
+			return nil, fmt.Errorf("invalid timeout for %s: must not be negative", gvk)
+		}
+		if w.MaxHistory < 0 {
+			return nil, fmt.Errorf("invalid maxHistory for %s: must not be negative", gvk)
+		}
+
+		if _, err := metav1.LabelSelectorAsSelector(&w.Selector); err != nil {
+			return nil, fmt.Errorf("invalid selector for %s: %w", gvk, err)
+		}
+		if _, err := metav1.LabelSelectorAsSelector(&w.NamespaceSelector); err != nil {
+			return nil, fmt.Errorf("invalid namespace selector for %s: %w", gvk, err)
+		}
+
+		if w.Drift != nil {
+			if err := verifyDriftPolicy(*w.Drift); err != nil {
+				return nil, fmt.Errorf("invalid drift policy for %s: %w", gvk, err)
+			}
+		}
+
+		for _, pr := range w.PostRenderers {
+			if err := verifyPostRenderer(pr); err != nil {
+				return nil, fmt.Errorf("invalid post-renderer for %s: %w", gvk, err)
+			}
+		}
+
+		if w.ReleaseNameTemplate != "" {
+			if _, err := ParseReleaseNameTemplate(w.ReleaseNameTemplate); err != nil {
+				return nil, fmt.Errorf("invalid release name template for %s: %w", gvk, err)
+			}
+		}
+
+		for _, m := range w.StatusMappings {
+			if err := verifyStatusMapping(m); err != nil {
+				return nil, fmt.Errorf("invalid status mapping for %s: %w", gvk, err)
+			}
+		}
+
 		if _, ok := watchesMap[gvk]; ok {
 			return nil, fmt.Errorf("duplicate GVK: %s", gvk)
 		}
@@ -111,6 +424,11 @@ func LoadReader(reader io.Reader) ([]Watch, error) {
 			w.WatchDependentResources = &trueVal
 		}
 		w.OverrideValues = expandOverrideEnvs(w.OverrideValues)
+		for j, c := range w.Charts {
+			if c.ReleaseNameSuffix == "" {
+				w.Charts[j].ReleaseNameSuffix = "-" + c.Name
+			}
+		}
 		watches[i] = w
 	}
 	return watches, nil
@@ -139,3 +457,153 @@ func verifyGVK(gvk schema.GroupVersionKind) error {
 	}
 	return nil
 }
+
+func verifyChartSource(src ChartSource) error {
+	switch {
+	case src.Repository != "" && src.OCI != "":
+		return errors.New("only one of repository and oci may be set")
+	case src.Repository != "":
+		if src.Name == "" {
+			return errors.New("name must not be empty")
+		}
+		if src.Version == "" {
+			return errors.New("version must not be empty")
+		}
+	case src.OCI != "":
+		if src.Name != "" || src.Version != "" {
+			return errors.New("name and version must not be set with oci; set the tag in the reference instead")
+		}
+	default:
+		return errors.New("one of repository or oci must be set")
+	}
+	if src.Digest != "" && !strings.HasPrefix(src.Digest, "sha256:") {
+		return fmt.Errorf("unsupported digest %q: only sha256 digests are supported", src.Digest)
+	}
+	return nil
+}
+
+func verifyComposedCharts(charts []ComposedChart) error {
+	names := map[string]struct{}{}
+	suffixes := map[string]struct{}{}
+	for _, c := range charts {
+		if errs := validation.IsDNS1123Label(c.Name); len(errs) != 0 {
+			return fmt.Errorf("invalid name %q: %s", c.Name, strings.Join(errs, ", "))
+		}
+		if _, ok := names[c.Name]; ok {
+			return fmt.Errorf("duplicate name %q", c.Name)
+		}
+		names[c.Name] = struct{}{}
+
+		suffix := c.ReleaseNameSuffix
+		if suffix == "" {
+			suffix = "-" + c.Name
+		}
+		if _, ok := suffixes[suffix]; ok {
+			return fmt.Errorf("duplicate release name suffix %q of chart %q", suffix, c.Name)
+		}
+		suffixes[suffix] = struct{}{}
+
+		if c.ChartSource != nil {
+			if c.ChartDir != "" {
+				return fmt.Errorf("only one of chart and chartSource of chart %q may be set", c.Name)
+			}
+			if err := verifyChartSource(*c.ChartSource); err != nil {
+				return fmt.Errorf("invalid chart source of chart %q: %w", c.Name, err)
+			}
+		} else if _, err := chartutil.IsChartDir(c.ChartDir); err != nil {
+			return fmt.Errorf("invalid chart directory %s of chart %q: %w", c.ChartDir, c.Name, err)
+		}
+
+		if c.ValuesPath != "" {
+			for _, f := range strings.Split(c.ValuesPath, ".") {
+				if f == "" {
+					return fmt.Errorf("values path %q of chart %q must not contain empty path elements",
+						c.ValuesPath, c.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func verifyReleaseStorage(rs ReleaseStorage) error {
+	switch rs.Driver {
+	case "", "secret", "configmap", "memory", "sql":
+		return nil
+	default:
+		return fmt.Errorf("unknown driver %q: must be one of secret, configmap, memory or sql", rs.Driver)
+	}
+}
+
+func verifyDriftPolicy(p DriftPolicy) error {
+	switch p.Mode {
+	case "", DriftModeSelfHeal, DriftModeReport:
+	default:
+		return fmt.Errorf("unknown mode %q: must be one of %s or %s", p.Mode, DriftModeSelfHeal, DriftModeReport)
+	}
+	for _, rule := range p.Ignore {
+		if len(rule.Paths) == 0 {
+			return errors.New("ignore rules must set paths")
+		}
+		for _, path := range rule.Paths {
+			if !strings.HasPrefix(path, "/") || path == "/" {
+				return fmt.Errorf("invalid path %q: must be a JSON pointer to a field, ex. /spec/replicas", path)
+			}
+		}
+	}
+	return nil
+}
+
+func verifyPostRenderer(pr PostRenderer) error {
+	set := 0
+	if pr.Kustomize != nil {
+		set++
+		for _, field := range []string{"resources", "bases", "components"} {
+			if _, ok := pr.Kustomize[field]; ok {
+				return fmt.Errorf("kustomize must not set %s: its resources are the rendered manifests", field)
+			}
+		}
+	}
+	if pr.Patches != nil {
+		set++
+		for _, p := range pr.Patches {
+			if strings.TrimSpace(p.Patch) == "" {
+				return errors.New("patch must not be empty")
+			}
+		}
+	}
+	if pr.Exec != "" {
+		set++
+	}
+	if set != 1 {
+		return errors.New("exactly one of kustomize, patches or exec must be set")
+	}
+	return nil
+}
+
+func verifyStatusMapping(m StatusMapping) error {
+	if m.Field == "" {
+		return errors.New("field must be set")
+	}
+	for _, f := range strings.Split(m.Field, ".") {
+		if f == "" {
+			return fmt.Errorf("field %q must not contain empty path elements", m.Field)
+		}
+	}
+	if top := strings.Split(m.Field, ".")[0]; types.IsReservedStatusField(top) {
+		return fmt.Errorf("field %q is set by the operator", top)
+	}
+	if m.Kind == "" {
+		return errors.New("kind must be set")
+	}
+	if _, err := ParseStatusMappingName(m.Name); err != nil {
+		return fmt.Errorf("invalid name: %w", err)
+	}
+	if m.JSONPath == "" {
+		return errors.New("jsonPath must be set")
+	}
+	if _, err := ParseStatusMappingJSONPath(m.JSONPath); err != nil {
+		return fmt.Errorf("invalid jsonPath: %w", err)
+	}
+	return nil
+}
@@ -19,8 +19,10 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -102,6 +104,581 @@ func TestLoadReader(t *testing.T) {
 			},
 			expectErr: false,
 		},
+		{
+			name: "valid chart source repository",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chartSource:
+    repository: https://charts.example.com
+    name: mychart
+    version: 1.2.3
+    digest: sha256:abc123
+`,
+			expectWatches: []Watch{
+				{
+					GroupVersionKind: schema.GroupVersionKind{Group: "mygroup", Version: "v1alpha1", Kind: "MyKind"},
+					ChartSource: &ChartSource{
+						Repository: "https://charts.example.com",
+						Name:       "mychart",
+						Version:    "1.2.3",
+						Digest:     "sha256:abc123",
+					},
+					WatchDependentResources: &trueVal,
+				},
+			},
+			expectErr: false,
+		},
+		{
+			name: "valid chart source oci",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chartSource:
+    oci: oci://registry.example.com/charts/mychart:1.2.3
+`,
+			expectWatches: []Watch{
+				{
+					GroupVersionKind:        schema.GroupVersionKind{Group: "mygroup", Version: "v1alpha1", Kind: "MyKind"},
+					ChartSource:             &ChartSource{OCI: "oci://registry.example.com/charts/mychart:1.2.3"},
+					WatchDependentResources: &trueVal,
+				},
+			},
+			expectErr: false,
+		},
+		{
+			name: "chart and chart source",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  chartSource:
+    oci: registry.example.com/charts/mychart:1.2.3
+`,
+			expectErr: true,
+		},
+		{
+			name: "chart source repository and oci",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chartSource:
+    repository: https://charts.example.com
+    name: mychart
+    version: 1.2.3
+    oci: registry.example.com/charts/mychart:1.2.3
+`,
+			expectErr: true,
+		},
+		{
+			name: "chart source repository without version",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chartSource:
+    repository: https://charts.example.com
+    name: mychart
+`,
+			expectErr: true,
+		},
+		{
+			name: "chart source unsupported digest",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chartSource:
+    oci: registry.example.com/charts/mychart:1.2.3
+    digest: md5:abc123
+`,
+			expectErr: true,
+		},
+		{
+			name: "valid release storage",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  releaseStorage:
+    driver: configmap
+    namespace: operator-ns
+`,
+			expectWatches: []Watch{
+				{
+					GroupVersionKind:        schema.GroupVersionKind{Group: "mygroup", Version: "v1alpha1", Kind: "MyKind"},
+					ChartDir:                "../../../internal/plugins/helm/v1/chartutil/testdata/test-chart",
+					WatchDependentResources: &trueVal,
+					ReleaseStorage:          &ReleaseStorage{Driver: "configmap", Namespace: "operator-ns"},
+				},
+			},
+			expectErr: false,
+		},
+		{
+			name: "invalid release storage driver",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  releaseStorage:
+    driver: etcd
+`,
+			expectErr: true,
+		},
+		{
+			name: "valid release options",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  wait: true
+  timeout: 10m
+  atomic: true
+  disableHooks: true
+  skipCRDs: true
+  maxHistory: 3
+`,
+			expectWatches: []Watch{
+				{
+					GroupVersionKind:        schema.GroupVersionKind{Group: "mygroup", Version: "v1alpha1", Kind: "MyKind"},
+					ChartDir:                "../../../internal/plugins/helm/v1/chartutil/testdata/test-chart",
+					WatchDependentResources: &trueVal,
+					Wait:                    true,
+					Timeout:                 &metav1.Duration{Duration: 10 * time.Minute},
+					Atomic:                  true,
+					DisableHooks:            true,
+					SkipCRDs:                true,
+					MaxHistory:              3,
+				},
+			},
+			expectErr: false,
+		},
+		{
+			name: "negative timeout",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  timeout: -1m
+`,
+			expectErr: true,
+		},
+		{
+			name: "negative max history",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  maxHistory: -1
+`,
+			expectErr: true,
+		},
+		{
+			name: "valid selectors",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  selector:
+    matchLabels:
+      shard: a
+  namespaceSelector:
+    matchExpressions:
+    - key: env
+      operator: In
+      values: ["canary"]
+`,
+			expectWatches: []Watch{
+				{
+					GroupVersionKind:        schema.GroupVersionKind{Group: "mygroup", Version: "v1alpha1", Kind: "MyKind"},
+					ChartDir:                "../../../internal/plugins/helm/v1/chartutil/testdata/test-chart",
+					WatchDependentResources: &trueVal,
+					Selector:                metav1.LabelSelector{MatchLabels: map[string]string{"shard": "a"}},
+					NamespaceSelector: metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "env", Operator: metav1.LabelSelectorOpIn, Values: []string{"canary"}},
+					}},
+				},
+			},
+			expectErr: false,
+		},
+		{
+			name: "invalid selector operator",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  namespaceSelector:
+    matchExpressions:
+    - key: env
+      operator: Like
+      values: ["canary"]
+`,
+			expectErr: true,
+		},
+		{
+			name: "valid drift policy",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  drift:
+    mode: report
+    ignore:
+    - group: apps
+      kind: Deployment
+      paths: ["/spec/replicas"]
+`,
+			expectWatches: []Watch{
+				{
+					GroupVersionKind:        schema.GroupVersionKind{Group: "mygroup", Version: "v1alpha1", Kind: "MyKind"},
+					ChartDir:                "../../../internal/plugins/helm/v1/chartutil/testdata/test-chart",
+					WatchDependentResources: &trueVal,
+					Drift: &DriftPolicy{
+						Mode: DriftModeReport,
+						Ignore: []DriftIgnoreRule{
+							{Group: "apps", Kind: "Deployment", Paths: []string{"/spec/replicas"}},
+						},
+					},
+				},
+			},
+			expectErr: false,
+		},
+		{
+			name: "invalid drift mode",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  drift:
+    mode: ignore
+`,
+			expectErr: true,
+		},
+		{
+			name: "invalid drift ignore path",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  drift:
+    ignore:
+    - kind: Deployment
+      paths: ["spec.replicas"]
+`,
+			expectErr: true,
+		},
+		{
+			name: "valid post-renderers",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  postRenderers:
+  - kustomize:
+      commonLabels:
+        team: web
+  - patches:
+    - patch: '[{"op": "add", "path": "/spec/replicas", "value": 2}]'
+      target:
+        kind: Deployment
+  - exec: /usr/local/bin/post-render
+`,
+			expectWatches: []Watch{
+				{
+					GroupVersionKind:        schema.GroupVersionKind{Group: "mygroup", Version: "v1alpha1", Kind: "MyKind"},
+					ChartDir:                "../../../internal/plugins/helm/v1/chartutil/testdata/test-chart",
+					WatchDependentResources: &trueVal,
+					PostRenderers: []PostRenderer{
+						{Kustomize: map[string]interface{}{"commonLabels": map[string]interface{}{"team": "web"}}},
+						{Patches: []Patch{{
+							Patch:  `[{"op": "add", "path": "/spec/replicas", "value": 2}]`,
+							Target: &PatchTarget{Kind: "Deployment"},
+						}}},
+						{Exec: "/usr/local/bin/post-render"},
+					},
+				},
+			},
+			expectErr: false,
+		},
+		{
+			name: "invalid post-renderer with multiple fields",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  postRenderers:
+  - exec: /usr/local/bin/post-render
+    kustomize:
+      commonLabels:
+        team: web
+`,
+			expectErr: true,
+		},
+		{
+			name: "invalid post-renderer kustomization resources",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  postRenderers:
+  - kustomize:
+      resources: ["extra.yaml"]
+`,
+			expectErr: true,
+		},
+		{
+			name: "valid release name template",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  releaseNameTemplate: "{{.Kind}}-{{.Name}}"
+  hashReleaseNames: true
+`,
+			expectWatches: []Watch{
+				{
+					GroupVersionKind:        schema.GroupVersionKind{Group: "mygroup", Version: "v1alpha1", Kind: "MyKind"},
+					ChartDir:                "../../../internal/plugins/helm/v1/chartutil/testdata/test-chart",
+					WatchDependentResources: &trueVal,
+					ReleaseNameTemplate:     "{{.Kind}}-{{.Name}}",
+					HashReleaseNames:        true,
+				},
+			},
+			expectErr: false,
+		},
+		{
+			name: "invalid release name template without name",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  releaseNameTemplate: "{{.Kind}}"
+`,
+			expectErr: true,
+		},
+		{
+			name: "invalid release name template field",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  releaseNameTemplate: "{{.Labels}}-{{.Name}}"
+`,
+			expectErr: true,
+		},
+		{
+			name: "valid status mappings",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  statusMappings:
+  - field: web.readyReplicas
+    kind: Deployment
+    name: "{{ .Release.Name }}-web"
+    jsonPath: "{.status.readyReplicas}"
+`,
+			expectWatches: []Watch{
+				{
+					GroupVersionKind:        schema.GroupVersionKind{Group: "mygroup", Version: "v1alpha1", Kind: "MyKind"},
+					ChartDir:                "../../../internal/plugins/helm/v1/chartutil/testdata/test-chart",
+					WatchDependentResources: &trueVal,
+					StatusMappings: []StatusMapping{
+						{Field: "web.readyReplicas", Kind: "Deployment", Name: "{{ .Release.Name }}-web", JSONPath: "{.status.readyReplicas}"},
+					},
+				},
+			},
+			expectErr: false,
+		},
+		{
+			name: "invalid status mapping of reserved field",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  statusMappings:
+  - field: conditions
+    kind: Deployment
+    jsonPath: "{.status.conditions}"
+`,
+			expectErr: true,
+		},
+		{
+			name: "invalid status mapping jsonPath",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  statusMappings:
+  - field: readyReplicas
+    kind: Deployment
+    jsonPath: "{.status.readyReplicas"
+`,
+			expectErr: true,
+		},
+		{
+			name: "valid validating webhook",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  validatingWebhook: true
+`,
+			expectWatches: []Watch{
+				{
+					GroupVersionKind:        schema.GroupVersionKind{Group: "mygroup", Version: "v1alpha1", Kind: "MyKind"},
+					ChartDir:                "../../../internal/plugins/helm/v1/chartutil/testdata/test-chart",
+					WatchDependentResources: &trueVal,
+					ValidatingWebhook:       true,
+				},
+			},
+			expectErr: false,
+		},
+		{
+			name: "valid charts",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  charts:
+  - name: database
+    chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+    valuesPath: database
+  - name: app
+    chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+    valuesPath: app.values
+    releaseNameSuffix: -web
+`,
+			expectWatches: []Watch{
+				{
+					GroupVersionKind:        schema.GroupVersionKind{Group: "mygroup", Version: "v1alpha1", Kind: "MyKind"},
+					WatchDependentResources: &trueVal,
+					Charts: []ComposedChart{
+						{
+							Name:              "database",
+							ChartDir:          "../../../internal/plugins/helm/v1/chartutil/testdata/test-chart",
+							ValuesPath:        "database",
+							ReleaseNameSuffix: "-database",
+						},
+						{
+							Name:              "app",
+							ChartDir:          "../../../internal/plugins/helm/v1/chartutil/testdata/test-chart",
+							ValuesPath:        "app.values",
+							ReleaseNameSuffix: "-web",
+						},
+					},
+				},
+			},
+			expectErr: false,
+		},
+		{
+			name: "chart and charts",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  charts:
+  - name: app
+    chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+`,
+			expectErr: true,
+		},
+		{
+			name: "charts with duplicate names",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  charts:
+  - name: app
+    chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  - name: app
+    chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+    releaseNameSuffix: -other
+`,
+			expectErr: true,
+		},
+		{
+			name: "charts with duplicate release name suffixes",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  charts:
+  - name: app
+    chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  - name: web
+    chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+    releaseNameSuffix: -app
+`,
+			expectErr: true,
+		},
+		{
+			name: "charts with invalid name",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  charts:
+  - name: My_App
+    chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+`,
+			expectErr: true,
+		},
+		{
+			name: "charts with empty values path element",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  charts:
+  - name: app
+    chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+    valuesPath: app..values
+`,
+			expectErr: true,
+		},
+		{
+			name: "charts with status mappings",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  charts:
+  - name: app
+    chart: ../../../internal/plugins/helm/v1/chartutil/testdata/test-chart
+  statusMappings:
+  - field: replicas
+    kind: Deployment
+    jsonPath: .status.replicas
+`,
+			expectErr: true,
+		},
 		{
 			name: "duplicate gvk",
 			data: `---
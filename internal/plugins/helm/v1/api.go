@@ -22,6 +22,7 @@ import (
 	"github.com/iancoleman/strcase"
 	"github.com/spf13/pflag"
 	"helm.sh/helm/v3/pkg/chart"
+	helmchartutil "helm.sh/helm/v3/pkg/chartutil"
 	"sigs.k8s.io/kubebuilder/v3/pkg/config"
 	"sigs.k8s.io/kubebuilder/v3/pkg/machinery"
 	"sigs.k8s.io/kubebuilder/v3/pkg/model/resource"
@@ -38,6 +39,9 @@ const (
 	helmChartFlag        = "helm-chart"
 	helmChartRepoFlag    = "helm-chart-repo"
 	helmChartVersionFlag = "helm-chart-version"
+	rbacOfflineFlag      = "rbac-offline"
+	rbacAPIResourcesFlag = "rbac-api-resources"
+	rbacValuesFilesFlag  = "rbac-values-files"
 
 	defaultCrdVersion = "v1"
 
@@ -52,6 +56,15 @@ type createAPIOptions struct {
 	CRDVersion string
 
 	chartOptions chartutil.Options
+
+	// rbacOffline generates RBAC rules without a cluster.
+	rbacOffline bool
+	// rbacAPIResources is a file listing the API resources used to generate
+	// RBAC rules without a cluster.
+	rbacAPIResources string
+	// rbacValuesFiles are values files the chart is rendered with to generate
+	// RBAC rules.
+	rbacValuesFiles []string
 }
 
 // UpdateResource updates the base resource with the information obtained from the flags
@@ -73,6 +86,8 @@ type createAPISubcommand struct {
 	resource *resource.Resource
 	chart    *chart.Chart
 	options  createAPIOptions
+
+	rbacOptions scaffolds.RBACOptions
 }
 
 func (p *createAPISubcommand) UpdateMetadata(cliMeta plugin.CLIMetadata, subcmdMeta *plugin.SubcommandMetadata) {
@@ -108,6 +123,16 @@ func (p *createAPISubcommand) UpdateMetadata(cliMeta plugin.CLIMetadata, subcmdM
 
   $ %[1]s create api \
       --helm-chart=/path/to/local/chart-archives/app-1.2.3.tgz
+
+  $ %[1]s create api \
+      --helm-chart=myrepo/app \
+      --rbac-offline \
+      --rbac-values-files=values-ha.yaml
+
+  $ kubectl api-resources > api-resources.txt
+  $ %[1]s create api \
+      --helm-chart=myrepo/app \
+      --rbac-api-resources=api-resources.txt
 `, cliMeta.CommandName)
 }
 
@@ -120,6 +145,13 @@ func (p *createAPISubcommand) BindFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&p.options.chartOptions.Version, helmChartVersionFlag, "", "helm chart version (default: latest)")
 
 	fs.StringVar(&p.options.CRDVersion, crdVersionFlag, defaultCrdVersion, "crd version to generate")
+
+	fs.BoolVar(&p.options.rbacOffline, rbacOfflineFlag, false,
+		"generate RBAC rules without a cluster, using a bundled list of API resources")
+	fs.StringVar(&p.options.rbacAPIResources, rbacAPIResourcesFlag, "",
+		"file listing API resources, as printed by 'kubectl api-resources', to generate RBAC rules without a cluster")
+	fs.StringSliceVar(&p.options.rbacValuesFiles, rbacValuesFilesFlag, nil,
+		"values files to render the chart with, in addition to its default values, to generate RBAC rules")
 }
 
 func (p *createAPISubcommand) InjectConfig(c config.Config) error {
@@ -176,6 +208,10 @@ func (p *createAPISubcommand) InjectResource(res *resource.Resource) error {
 		}
 	}
 
+	if err := p.loadRBACOptions(); err != nil {
+		return err
+	}
+
 	p.options.UpdateResource(p.resource)
 
 	if err := p.resource.Validate(); err != nil {
@@ -208,7 +244,7 @@ func (p *createAPISubcommand) Scaffold(fs machinery.Filesystem) error {
 		return fmt.Errorf("error updating kustomization.yaml files: %v", err)
 	}
 
-	scaffolder := scaffolds.NewAPIScaffolder(p.config, *p.resource, p.chart)
+	scaffolder := scaffolds.NewAPIScaffolder(p.config, *p.resource, p.chart, p.rbacOptions)
 	scaffolder.InjectFS(fs)
 	if err := scaffolder.Scaffold(); err != nil {
 		return err
@@ -217,3 +253,28 @@ func (p *createAPISubcommand) Scaffold(fs machinery.Filesystem) error {
 
 	return nil
 }
+
+// loadRBACOptions loads the API resources and values RBAC rules are generated
+// with.
+func (p *createAPISubcommand) loadRBACOptions() (err error) {
+	switch {
+	case p.options.rbacAPIResources != "":
+		p.rbacOptions.APIResources, err = chartutil.LoadAPIResources(p.options.rbacAPIResources)
+		if err != nil {
+			return fmt.Errorf("error loading --%s: %v", rbacAPIResourcesFlag, err)
+		}
+	case p.options.rbacOffline:
+		if p.rbacOptions.APIResources, err = chartutil.BundledAPIResources(); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range p.options.rbacValuesFiles {
+		vals, err := helmchartutil.ReadValuesFile(path)
+		if err != nil {
+			return fmt.Errorf("error loading --%s: %v", rbacValuesFilesFlag, err)
+		}
+		p.rbacOptions.Values = append(p.rbacOptions.Values, vals)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chartutil
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed apiresources.yaml
+var bundledAPIResources []byte
+
+// BundledAPIResources returns the API resources of Kubernetes' built-in APIs,
+// and of CRDs commonly created by charts, for use without a cluster.
+func BundledAPIResources() ([]*metav1.APIResourceList, error) {
+	return parseAPIResourceLists(bundledAPIResources)
+}
+
+// LoadAPIResources loads API resources from a file containing either the
+// output of 'kubectl api-resources', or a YAML list of API resource lists in
+// the format of the bundled API resources.
+func LoadAPIResources(path string) ([]*metav1.APIResourceList, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "NAME") {
+		return parseAPIResourcesTable(data)
+	}
+	lists, err := parseAPIResourceLists(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse API resources file %s: %w", path, err)
+	}
+	return lists, nil
+}
+
+func parseAPIResourceLists(data []byte) ([]*metav1.APIResourceList, error) {
+	var lists []*metav1.APIResourceList
+	if err := yaml.UnmarshalStrict(data, &lists); err != nil {
+		return nil, err
+	}
+	for _, list := range lists {
+		if list.GroupVersion == "" {
+			return nil, fmt.Errorf("API resource list has no groupVersion")
+		}
+	}
+	return lists, nil
+}
+
+// parseAPIResourcesTable parses the table printed by 'kubectl api-resources',
+// whose columns are aligned with its header.
+func parseAPIResourcesTable(data []byte) ([]*metav1.APIResourceList, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Scan()
+	header := scanner.Text()
+	columns := strings.Fields(header)
+	starts := make(map[string]int, len(columns))
+	ends := make(map[string]int, len(columns))
+	for i, column := range columns {
+		starts[column] = strings.Index(header, column)
+		if i > 0 {
+			ends[columns[i-1]] = starts[column]
+		}
+	}
+	for _, column := range []string{"NAME", "APIVERSION", "NAMESPACED", "KIND"} {
+		if _, ok := starts[column]; !ok {
+			return nil, fmt.Errorf("API resources table has no %s column; it is printed by kubectl 1.20 and later",
+				column)
+		}
+	}
+	cell := func(line, column string) string {
+		start, end := starts[column], len(line)
+		if e, ok := ends[column]; ok && e < end {
+			end = e
+		}
+		if start >= end {
+			return ""
+		}
+		return strings.TrimSpace(line[start:end])
+	}
+
+	var lists []*metav1.APIResourceList
+	listsByGroupVersion := map[string]*metav1.APIResourceList{}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		groupVersion := cell(line, "APIVERSION")
+		res := metav1.APIResource{
+			Name:       cell(line, "NAME"),
+			Kind:       cell(line, "KIND"),
+			Namespaced: cell(line, "NAMESPACED") == "true",
+		}
+		if groupVersion == "" || res.Name == "" || res.Kind == "" {
+			return nil, fmt.Errorf("invalid API resources table row %q", line)
+		}
+		list, ok := listsByGroupVersion[groupVersion]
+		if !ok {
+			list = &metav1.APIResourceList{GroupVersion: groupVersion}
+			listsByGroupVersion[groupVersion] = list
+			lists = append(lists, list)
+		}
+		list.APIResources = append(list.APIResources, res)
+	}
+	return lists, scanner.Err()
+}
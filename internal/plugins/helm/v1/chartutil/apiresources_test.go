@@ -0,0 +1,119 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chartutil_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/operator-framework/operator-sdk/internal/plugins/helm/v1/chartutil"
+)
+
+func TestBundledAPIResources(t *testing.T) {
+	lists, err := chartutil.BundledAPIResources()
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, list := range lists {
+		for _, res := range list.APIResources {
+			assert.NotEmpty(t, res.Name, list.GroupVersion)
+			assert.NotEmpty(t, res.Kind, list.GroupVersion)
+		}
+	}
+}
+
+func TestLoadAPIResources(t *testing.T) {
+	expected := []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "configmaps", Kind: "ConfigMap", Namespaced: true},
+				{Name: "namespaces", Kind: "Namespace", Namespaced: false},
+			},
+		},
+		{
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "deployments", Kind: "Deployment", Namespaced: true},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name      string
+		data      string
+		expectErr bool
+	}{
+		{
+			name: "kubectl table",
+			data: `NAME          SHORTNAMES   APIVERSION   NAMESPACED   KIND
+configmaps    cm           v1           true         ConfigMap
+namespaces    ns           v1           false        Namespace
+deployments                apps/v1      true         Deployment
+`,
+		},
+		{
+			name: "kubectl wide table",
+			data: `NAME          SHORTNAMES   APIVERSION   NAMESPACED   KIND         VERBS
+configmaps    cm           v1           true         ConfigMap    [create delete get list]
+namespaces    ns           v1           false        Namespace    [create delete get list]
+deployments                apps/v1      true         Deployment   [create delete get list]
+`,
+		},
+		{
+			name: "yaml",
+			data: `- groupVersion: v1
+  resources:
+  - {name: configmaps, kind: ConfigMap, namespaced: true}
+  - {name: namespaces, kind: Namespace, namespaced: false}
+- groupVersion: apps/v1
+  resources:
+  - {name: deployments, kind: Deployment, namespaced: true}
+`,
+		},
+		{
+			name: "table from old kubectl",
+			data: `NAME          SHORTNAMES   APIGROUP   NAMESPACED   KIND
+configmaps    cm                      true         ConfigMap
+`,
+			expectErr: true,
+		},
+		{
+			name:      "yaml without groupVersion",
+			data:      `- resources: []`,
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "api-resources")
+			if !assert.NoError(t, ioutil.WriteFile(path, []byte(tc.data), 0644)) {
+				return
+			}
+			lists, err := chartutil.LoadAPIResources(path)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, expected, lists)
+		})
+	}
+}
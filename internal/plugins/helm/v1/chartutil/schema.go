@@ -0,0 +1,400 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chartutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// supportedFormats are the JSON Schema formats accepted by the Kubernetes API
+// server. Other formats are dropped from generated schemas.
+var supportedFormats = map[string]bool{
+	"bsonobjectid": true, "uri": true, "email": true, "hostname": true, "ipv4": true, "ipv6": true,
+	"cidr": true, "mac": true, "uuid": true, "uuid3": true, "uuid4": true, "uuid5": true,
+	"isbn": true, "isbn10": true, "isbn13": true, "creditcard": true, "ssn": true, "hexcolor": true,
+	"rgbcolor": true, "byte": true, "password": true, "date": true, "duration": true, "datetime": true,
+	"date-time": true, "int32": true, "int64": true, "float": true, "double": true,
+}
+
+// SpecSchema returns a structural OpenAPI v3 schema for the spec of a CR whose
+// spec holds the values of chrt. The schema is converted from the chart's
+// values.schema.json if it has one, and otherwise inferred from the types of
+// the chart's default values. Schemas of the chart's dependencies are added
+// under their names.
+//
+// Objects preserve unknown fields unless the values schema sets
+// additionalProperties to false, since Helm accepts values its schema does
+// not describe. Keywords a structural schema cannot express, like oneOf, and
+// keywords that only hold for values merged with the chart's defaults, like
+// required, are dropped; Helm still validates values against the full values
+// schema on install and upgrade.
+func SpecSchema(chrt *chart.Chart) (*apiextv1.JSONSchemaProps, error) {
+	var props *apiextv1.JSONSchemaProps
+	if len(chrt.Schema) > 0 {
+		root := map[string]interface{}{}
+		if err := json.Unmarshal(chrt.Schema, &root); err != nil {
+			return nil, fmt.Errorf("failed to parse values schema of chart %q: %w", chrt.Name(), err)
+		}
+		c := schemaConverter{root: root, refs: map[string]bool{}}
+		var err error
+		if props, err = c.convert(root); err != nil {
+			return nil, fmt.Errorf("failed to convert values schema of chart %q: %w", chrt.Name(), err)
+		}
+	} else {
+		props = inferSchema(chrt.Values)
+	}
+	switch props.Type {
+	case "object":
+	case "":
+		// Schemas that only constrain values, ex. with required, describe
+		// any object.
+		props.Type = "object"
+		props.XIntOrString = false
+		props.XPreserveUnknownFields = boolPtr(true)
+	default:
+		return nil, fmt.Errorf("values schema of chart %q is not an object", chrt.Name())
+	}
+
+	for _, dep := range chrt.Dependencies() {
+		if _, ok := props.Properties[dep.Name()]; ok {
+			continue
+		}
+		depProps, err := SpecSchema(dep)
+		if err != nil {
+			return nil, err
+		}
+		if props.Properties == nil {
+			props.Properties = map[string]apiextv1.JSONSchemaProps{}
+		}
+		props.Properties[dep.Name()] = *depProps
+	}
+	// A dependency may have added properties to a schema that previously
+	// had none.
+	if len(props.Properties) > 0 && props.AdditionalProperties != nil {
+		props.AdditionalProperties = nil
+		props.XPreserveUnknownFields = boolPtr(true)
+	}
+	return props, nil
+}
+
+// SpecSchemaYAML returns the YAML of SpecSchema without a description, for
+// use in CRD manifests.
+func SpecSchemaYAML(chrt *chart.Chart) (string, error) {
+	props, err := SpecSchema(chrt)
+	if err != nil {
+		return "", err
+	}
+	props.Description = ""
+	b, err := yaml.Marshal(props)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// inferSchema returns a schema matching the type of a default value. Numbers
+// are never inferred to be integers, since a whole default does not mean the
+// chart rejects fractions. Fields are nullable, since null values unset the
+// chart's defaults.
+func inferSchema(v interface{}) *apiextv1.JSONSchemaProps {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		props := &apiextv1.JSONSchemaProps{
+			Type: "object",
+			// Charts commonly accept keys their default values omit.
+			XPreserveUnknownFields: boolPtr(true),
+		}
+		if len(val) > 0 {
+			props.Properties = make(map[string]apiextv1.JSONSchemaProps, len(val))
+			for key, elem := range val {
+				elemProps := inferSchema(elem)
+				elemProps.Nullable = true
+				props.Properties[key] = *elemProps
+			}
+		}
+		return props
+	case []interface{}:
+		items := &apiextv1.JSONSchemaProps{XPreserveUnknownFields: boolPtr(true)}
+		if len(val) > 0 {
+			items = inferSchema(val[0])
+			for _, elem := range val[1:] {
+				if inferSchema(elem).Type != items.Type {
+					items = &apiextv1.JSONSchemaProps{XPreserveUnknownFields: boolPtr(true)}
+					break
+				}
+			}
+		}
+		return &apiextv1.JSONSchemaProps{Type: "array", Items: &apiextv1.JSONSchemaPropsOrArray{Schema: items}}
+	case string:
+		return &apiextv1.JSONSchemaProps{Type: "string"}
+	case bool:
+		return &apiextv1.JSONSchemaProps{Type: "boolean"}
+	case float64, int, int64:
+		return &apiextv1.JSONSchemaProps{Type: "number"}
+	default:
+		// Null defaults give no hint of the value's type.
+		return &apiextv1.JSONSchemaProps{XPreserveUnknownFields: boolPtr(true)}
+	}
+}
+
+// schemaConverter converts JSON Schema documents to structural schemas.
+type schemaConverter struct {
+	root map[string]interface{}
+	// refs are the references being converted, to break reference cycles.
+	refs map[string]bool
+}
+
+func (c schemaConverter) convert(s map[string]interface{}) (*apiextv1.JSONSchemaProps, error) {
+	if ref, ok := s["$ref"].(string); ok {
+		if c.refs[ref] {
+			return &apiextv1.JSONSchemaProps{XPreserveUnknownFields: boolPtr(true)}, nil
+		}
+		target, err := c.resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+		c.refs[ref] = true
+		defer delete(c.refs, ref)
+		props, err := c.convert(target)
+		if err != nil {
+			return nil, err
+		}
+		if desc, ok := s["description"].(string); ok {
+			props.Description = desc
+		}
+		return props, nil
+	}
+
+	props := &apiextv1.JSONSchemaProps{}
+	if desc, ok := s["description"].(string); ok {
+		props.Description = desc
+	}
+	if err := c.setType(props, s); err != nil {
+		return nil, err
+	}
+
+	switch props.Type {
+	case "object":
+		if err := c.convertObject(props, s); err != nil {
+			return nil, err
+		}
+	case "array":
+		if err := c.convertArray(props, s); err != nil {
+			return nil, err
+		}
+	case "string":
+		if format, ok := s["format"].(string); ok && supportedFormats[format] {
+			props.Format = format
+		}
+		if pattern, ok := s["pattern"].(string); ok {
+			props.Pattern = pattern
+		}
+		props.MinLength = intKeyword(s, "minLength")
+		props.MaxLength = intKeyword(s, "maxLength")
+	case "integer", "number":
+		if format, ok := s["format"].(string); ok && supportedFormats[format] {
+			props.Format = format
+		}
+		props.Minimum, props.ExclusiveMinimum = boundKeyword(s, "minimum", "exclusiveMinimum")
+		props.Maximum, props.ExclusiveMaximum = boundKeyword(s, "maximum", "exclusiveMaximum")
+		if m, ok := s["multipleOf"].(float64); ok && m > 0 {
+			props.MultipleOf = &m
+		}
+	}
+
+	if enum, ok := s["enum"].([]interface{}); ok && props.Type != "" {
+		for _, v := range enum {
+			if v == nil {
+				continue
+			}
+			raw, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			props.Enum = append(props.Enum, apiextv1.JSON{Raw: raw})
+		}
+	}
+	return props, nil
+}
+
+// setType sets the type of props. Structural schemas have a single type, so
+// unions other than of a type and null, or of an integer and a string, leave
+// the type unset and preserve unknown fields.
+func (c schemaConverter) setType(props *apiextv1.JSONSchemaProps, s map[string]interface{}) error {
+	var types []string
+	switch t := s["type"].(type) {
+	case string:
+		types = []string{t}
+	case []interface{}:
+		for _, elem := range t {
+			name, ok := elem.(string)
+			if !ok {
+				return fmt.Errorf("invalid type %v", t)
+			}
+			types = append(types, name)
+		}
+	case nil:
+		// Untyped schemas are commonly objects or arrays whose type is
+		// implied by their keywords.
+		if _, ok := s["properties"]; ok {
+			types = []string{"object"}
+		} else if _, ok := s["items"]; ok {
+			types = []string{"array"}
+		}
+	default:
+		return fmt.Errorf("invalid type %v", t)
+	}
+
+	nonNull := make([]string, 0, len(types))
+	for _, t := range types {
+		if t == "null" {
+			props.Nullable = true
+			continue
+		}
+		nonNull = append(nonNull, t)
+	}
+	sort.Strings(nonNull)
+
+	switch {
+	case len(nonNull) == 1:
+		props.Type = nonNull[0]
+	case strings.Join(nonNull, ",") == "integer,string":
+		props.XIntOrString = true
+	case strings.Join(nonNull, ",") == "integer,number":
+		props.Type = "number"
+	default:
+		props.XPreserveUnknownFields = boolPtr(true)
+	}
+	return nil
+}
+
+func (c schemaConverter) convertObject(props *apiextv1.JSONSchemaProps, s map[string]interface{}) error {
+	properties, _ := s["properties"].(map[string]interface{})
+	for name, value := range properties {
+		sub, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		subProps, err := c.convert(sub)
+		if err != nil {
+			return fmt.Errorf("property %q: %w", name, err)
+		}
+		if props.Properties == nil {
+			props.Properties = make(map[string]apiextv1.JSONSchemaProps, len(properties))
+		}
+		props.Properties[name] = *subProps
+	}
+	// Keywords constraining the set of properties, like required, are not
+	// converted: they apply to values merged with the chart's defaults,
+	// while CRs only set the values they override.
+
+	// Structural schemas cannot set both properties and
+	// additionalProperties.
+	switch additional := s["additionalProperties"].(type) {
+	case bool:
+		if additional {
+			props.XPreserveUnknownFields = boolPtr(true)
+		}
+	case map[string]interface{}:
+		if len(props.Properties) > 0 {
+			props.XPreserveUnknownFields = boolPtr(true)
+			break
+		}
+		valueProps, err := c.convert(additional)
+		if err != nil {
+			return fmt.Errorf("additionalProperties: %w", err)
+		}
+		props.AdditionalProperties = &apiextv1.JSONSchemaPropsOrBool{Allows: true, Schema: valueProps}
+	default:
+		// Objects accept properties their schema does not list unless
+		// additionalProperties is false.
+		props.XPreserveUnknownFields = boolPtr(true)
+	}
+	return nil
+}
+
+func (c schemaConverter) convertArray(props *apiextv1.JSONSchemaProps, s map[string]interface{}) error {
+	items := &apiextv1.JSONSchemaProps{XPreserveUnknownFields: boolPtr(true)}
+	// Tuple validation, where items is a list, cannot be expressed.
+	if itemSchema, ok := s["items"].(map[string]interface{}); ok {
+		var err error
+		if items, err = c.convert(itemSchema); err != nil {
+			return fmt.Errorf("items: %w", err)
+		}
+	}
+	props.Items = &apiextv1.JSONSchemaPropsOrArray{Schema: items}
+	props.MinItems = intKeyword(s, "minItems")
+	props.MaxItems = intKeyword(s, "maxItems")
+	return nil
+}
+
+// resolve returns the schema referenced by a local JSON pointer, such as
+// "#/definitions/image".
+func (c schemaConverter) resolve(ref string) (map[string]interface{}, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported reference %q: only local references are supported", ref)
+	}
+	var node interface{} = c.root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("reference %q not found", ref)
+		}
+		if node, ok = m[token]; !ok {
+			return nil, fmt.Errorf("reference %q not found", ref)
+		}
+	}
+	target, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("reference %q is not a schema", ref)
+	}
+	return target, nil
+}
+
+// intKeyword returns the value of an integer keyword of s, or nil if unset.
+func intKeyword(s map[string]interface{}, key string) *int64 {
+	f, ok := s[key].(float64)
+	if !ok || f < 0 {
+		return nil
+	}
+	i := int64(f)
+	return &i
+}
+
+// boundKeyword returns a bound and whether it is exclusive. Draft 4 schemas
+// set the exclusive keyword to a boolean, later drafts set it to the bound.
+func boundKeyword(s map[string]interface{}, key, exclusiveKey string) (*float64, bool) {
+	if exclusive, ok := s[exclusiveKey].(float64); ok {
+		return &exclusive, true
+	}
+	bound, ok := s[key].(float64)
+	if !ok {
+		return nil, false
+	}
+	exclusive, _ := s[exclusiveKey].(bool)
+	return &bound, exclusive
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
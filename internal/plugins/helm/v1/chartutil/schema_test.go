@@ -0,0 +1,172 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chartutil_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/chart"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/operator-framework/operator-sdk/internal/plugins/helm/v1/chartutil"
+)
+
+func TestSpecSchemaFromValuesSchema(t *testing.T) {
+	chrt := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "test"},
+		Schema: []byte(`{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["image"],
+  "properties": {
+    "replicaCount": {"type": "integer", "minimum": 1, "description": "Number of replicas"},
+    "image": {"$ref": "#/definitions/image"},
+    "port": {"type": ["integer", "string"]},
+    "ratio": {"type": ["number", "null"], "exclusiveMaximum": 1},
+    "mode": {"type": "string", "enum": ["a", "b"], "format": "not-a-k8s-format"},
+    "labels": {"type": "object", "additionalProperties": {"type": "string"}},
+    "args": {"type": "array", "items": {"type": "string"}, "minItems": 1},
+    "strict": {"type": "object", "additionalProperties": false, "properties": {"a": {"type": "boolean"}}},
+    "anything": {"oneOf": [{"type": "string"}, {"type": "object"}]},
+    "tree": {"$ref": "#/definitions/node"}
+  },
+  "definitions": {
+    "image": {
+      "type": "object",
+      "properties": {"repository": {"type": "string", "pattern": "^[a-z/]+$"}}
+    },
+    "node": {
+      "type": "object",
+      "properties": {"children": {"type": "array", "items": {"$ref": "#/definitions/node"}}}
+    }
+  }
+}`),
+	}
+
+	props, err := chartutil.SpecSchema(chrt)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assertStructural(t, props)
+
+	assert.Equal(t, "object", props.Type)
+	assert.Empty(t, props.Required)
+	assert.True(t, *props.XPreserveUnknownFields)
+
+	replicas := props.Properties["replicaCount"]
+	assert.Equal(t, "integer", replicas.Type)
+	assert.Equal(t, "Number of replicas", replicas.Description)
+	assert.Equal(t, 1.0, *replicas.Minimum)
+
+	image := props.Properties["image"]
+	assert.Equal(t, "object", image.Type)
+	assert.Equal(t, "^[a-z/]+$", image.Properties["repository"].Pattern)
+
+	assert.True(t, props.Properties["port"].XIntOrString)
+
+	ratio := props.Properties["ratio"]
+	assert.Equal(t, "number", ratio.Type)
+	assert.True(t, ratio.Nullable)
+	assert.Equal(t, 1.0, *ratio.Maximum)
+	assert.True(t, ratio.ExclusiveMaximum)
+
+	mode := props.Properties["mode"]
+	assert.Empty(t, mode.Format)
+	assert.Equal(t, []apiextv1.JSON{{Raw: []byte(`"a"`)}, {Raw: []byte(`"b"`)}}, mode.Enum)
+
+	labels := props.Properties["labels"]
+	assert.Equal(t, "string", labels.AdditionalProperties.Schema.Type)
+	assert.Nil(t, labels.XPreserveUnknownFields)
+
+	args := props.Properties["args"]
+	assert.Equal(t, "string", args.Items.Schema.Type)
+	assert.Equal(t, int64(1), *args.MinItems)
+
+	assert.Nil(t, props.Properties["strict"].XPreserveUnknownFields)
+	assert.True(t, *props.Properties["anything"].XPreserveUnknownFields)
+
+	children := props.Properties["tree"].Properties["children"]
+	assert.True(t, *children.Items.Schema.XPreserveUnknownFields)
+}
+
+func TestSpecSchemaFromValues(t *testing.T) {
+	dep := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "dep"},
+		Values:   map[string]interface{}{"enabled": true},
+	}
+	chrt := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "test"},
+		Values: map[string]interface{}{
+			"replicaCount": float64(3),
+			"ratio":        0.5,
+			"image":        map[string]interface{}{"repository": "nginx"},
+			"ports":        []interface{}{float64(80), float64(443)},
+			"mixed":        []interface{}{"a", float64(1)},
+			"tolerations":  []interface{}{},
+			"nodeSelector": map[string]interface{}{},
+			"tag":          nil,
+		},
+	}
+	chrt.AddDependency(dep)
+
+	props, err := chartutil.SpecSchema(chrt)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assertStructural(t, props)
+
+	assert.Equal(t, "number", props.Properties["replicaCount"].Type)
+	assert.True(t, props.Properties["replicaCount"].Nullable)
+	assert.Equal(t, "number", props.Properties["ratio"].Type)
+	assert.Equal(t, "string", props.Properties["image"].Properties["repository"].Type)
+	assert.True(t, *props.Properties["image"].XPreserveUnknownFields)
+	assert.True(t, props.Properties["image"].Properties["repository"].Nullable)
+	assert.Equal(t, "number", props.Properties["ports"].Items.Schema.Type)
+	assert.True(t, *props.Properties["mixed"].Items.Schema.XPreserveUnknownFields)
+	assert.True(t, *props.Properties["tolerations"].Items.Schema.XPreserveUnknownFields)
+	assert.True(t, *props.Properties["nodeSelector"].XPreserveUnknownFields)
+	assert.Empty(t, props.Properties["tag"].Type)
+	assert.True(t, props.Properties["tag"].Nullable)
+	assert.Equal(t, "boolean", props.Properties["dep"].Properties["enabled"].Type)
+}
+
+func TestSpecSchemaErrors(t *testing.T) {
+	for _, schema := range []string{
+		`{"type": "object"`,
+		`{"type": "string"}`,
+		`{"type": "object", "properties": {"a": {"$ref": "http://example.com/schema.json"}}}`,
+		`{"type": "object", "properties": {"a": {"$ref": "#/definitions/missing"}}}`,
+	} {
+		chrt := &chart.Chart{Metadata: &chart.Metadata{Name: "test"}, Schema: []byte(schema)}
+		_, err := chartutil.SpecSchema(chrt)
+		assert.Error(t, err, schema)
+	}
+}
+
+func assertStructural(t *testing.T, props *apiextv1.JSONSchemaProps) {
+	internal := &apiextensions.JSONSchemaProps{}
+	if !assert.NoError(t, apiextv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(props, internal, nil)) {
+		return
+	}
+	s, err := structuralschema.NewStructural(internal)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, structuralschema.ValidateStructural(field.NewPath("spec"), s))
+}
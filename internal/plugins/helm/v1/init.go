@@ -169,6 +169,15 @@ func (p *initSubcommand) PostScaffold() error {
 		if p.apiSubcommand.options.chartOptions.Version != "" {
 			args = append(args, fmt.Sprintf("--%s", helmChartVersionFlag), p.apiSubcommand.options.chartOptions.Version)
 		}
+		if p.apiSubcommand.options.rbacOffline {
+			args = append(args, fmt.Sprintf("--%s", rbacOfflineFlag))
+		}
+		if p.apiSubcommand.options.rbacAPIResources != "" {
+			args = append(args, fmt.Sprintf("--%s", rbacAPIResourcesFlag), p.apiSubcommand.options.rbacAPIResources)
+		}
+		for _, path := range p.apiSubcommand.options.rbacValuesFiles {
+			args = append(args, fmt.Sprintf("--%s", rbacValuesFilesFlag), path)
+		}
 		if err := util.RunCmd("Creating the API", os.Args[0], args...); err != nil {
 			return err
 		}
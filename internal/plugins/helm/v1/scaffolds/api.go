@@ -21,7 +21,9 @@ import (
 	"fmt"
 	"os"
 
+	log "github.com/sirupsen/logrus"
 	"helm.sh/helm/v3/pkg/chart"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/kubebuilder/v3/pkg/config"
 	"sigs.k8s.io/kubebuilder/v3/pkg/machinery"
 	"sigs.k8s.io/kubebuilder/v3/pkg/model/resource"
@@ -41,17 +43,29 @@ var _ plugins.Scaffolder = &apiScaffolder{}
 type apiScaffolder struct {
 	fs machinery.Filesystem
 
-	config   config.Config
-	resource resource.Resource
-	chrt     *chart.Chart
+	config      config.Config
+	resource    resource.Resource
+	chrt        *chart.Chart
+	rbacOptions RBACOptions
+}
+
+// RBACOptions configure how the RBAC rules of a chart are generated.
+type RBACOptions struct {
+	// APIResources resolve the resources of the chart's manifests without a
+	// cluster. If nil, they are discovered from the cluster.
+	APIResources []*metav1.APIResourceList
+	// Values are sets of values the chart is rendered with in addition to
+	// its default values.
+	Values []map[string]interface{}
 }
 
 // NewAPIScaffolder returns a new plugins.Scaffolder for API/controller creation operations
-func NewAPIScaffolder(cfg config.Config, res resource.Resource, chrt *chart.Chart) plugins.Scaffolder {
+func NewAPIScaffolder(cfg config.Config, res resource.Resource, chrt *chart.Chart, rbacOptions RBACOptions) plugins.Scaffolder {
 	return &apiScaffolder{
-		config:   cfg,
-		resource: res,
-		chrt:     chrt,
+		config:      cfg,
+		resource:    res,
+		chrt:        chrt,
+		rbacOptions: rbacOptions,
 	}
 }
 
@@ -80,6 +94,12 @@ func (s *apiScaffolder) Scaffold() error {
 	}
 	fmt.Printf("Created %s\n", chartPath)
 
+	// Unconvertible values schemas should not prevent scaffolding.
+	specSchema, err := chartutil.SpecSchemaYAML(s.chrt)
+	if err != nil {
+		log.Warnf("Spec schema not generated, CRD spec will preserve unknown fields: %v", err)
+	}
+
 	// Initialize the machinery.Scaffold that will write the files to disk
 	scaffold := machinery.NewScaffold(s.fs,
 		// NOTE: kubebuilder's default permissions are only for root users
@@ -91,9 +111,13 @@ func (s *apiScaffolder) Scaffold() error {
 
 	if err := scaffold.Execute(
 		&templates.WatchesUpdater{ChartPath: chartPath},
-		&crd.CRD{},
+		&crd.CRD{SpecSchema: specSchema},
 		&crd.Kustomization{},
-		&rbac.ManagerRoleUpdater{Chart: s.chrt},
+		&rbac.ManagerRoleUpdater{
+			Chart:        s.chrt,
+			APIResources: s.rbacOptions.APIResources,
+			Values:       s.rbacOptions.Values,
+		},
 		&samples.CustomResource{ChartPath: chartPath, Chart: s.chrt},
 	); err != nil {
 		return fmt.Errorf("error scaffolding APIs: %w", err)
@@ -17,17 +17,24 @@ package crd
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
+	"text/template"
 
 	"github.com/kr/text"
 	"sigs.k8s.io/kubebuilder/v3/pkg/machinery"
 )
 
 var _ machinery.Template = &CRD{}
+var _ machinery.UseCustomFuncMap = &CRD{}
 
 // CRD scaffolds a manifest for CRD sample.
 type CRD struct {
 	machinery.TemplateMixin
 	machinery.ResourceMixin
+
+	// SpecSchema is the YAML of the OpenAPI v3 schema of the CRD's spec. If
+	// empty, the spec preserves unknown fields.
+	SpecSchema string
 }
 
 // SetTemplateDefaults implements machinery.Template
@@ -39,14 +46,24 @@ func (f *CRD) SetTemplateDefaults() error {
 
 	f.IfExistsAction = machinery.Error
 
+	// The spec schema is nested 6 spaces deeper than openAPIV3Schema.
 	f.TemplateBody = fmt.Sprintf(crdTemplate,
-		text.Indent(openAPIV3SchemaTemplate, "    "),
-		text.Indent(openAPIV3SchemaTemplate, "      "),
+		text.Indent(fmt.Sprintf(openAPIV3SchemaTemplate, 10), "    "),
+		text.Indent(fmt.Sprintf(openAPIV3SchemaTemplate, 12), "      "),
 	)
 
 	return nil
 }
 
+// GetFuncMap implements machinery.UseCustomFuncMap
+func (f *CRD) GetFuncMap() template.FuncMap {
+	fm := machinery.DefaultFuncMap()
+	fm["nindent"] = func(spaces int, s string) string {
+		return "\n" + text.Indent(strings.TrimSuffix(s, "\n"), strings.Repeat(" ", spaces))
+	}
+	return fm
+}
+
 const crdTemplate = `---
 apiVersion: apiextensions.k8s.io/{{ .Resource.API.CRDVersion }}
 kind: CustomResourceDefinition
@@ -97,8 +114,12 @@ const openAPIV3SchemaTemplate = `openAPIV3Schema:
       type: object
     spec:
       description: Spec defines the desired state of {{ .Resource.Kind }}
+{{- if .SpecSchema }}
+{{- nindent %d .SpecSchema }}
+{{- else }}
       type: object
       x-kubernetes-preserve-unknown-fields: true
+{{- end }}
     status:
       description: Status defines the observed state of {{ .Resource.Kind }}
       type: object
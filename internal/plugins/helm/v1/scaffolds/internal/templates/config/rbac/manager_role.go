@@ -30,6 +30,7 @@ import (
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
 	crconfig "sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/kubebuilder/v3/pkg/machinery"
@@ -61,7 +62,14 @@ var _ machinery.Inserter = &ManagerRoleUpdater{}
 type ManagerRoleUpdater struct {
 	machinery.ResourceMixin
 
-	Chart            *chart.Chart
+	Chart *chart.Chart
+	// APIResources resolve the resources of the chart's manifests. If nil,
+	// they are discovered from the cluster.
+	APIResources []*metav1.APIResourceList
+	// Values are sets of values the chart is rendered with in addition to its
+	// default values.
+	Values []map[string]interface{}
+
 	SkipDefaultRules bool
 	CustomRules      []rbacv1.PolicyRule
 }
@@ -92,7 +100,9 @@ func (f *ManagerRoleUpdater) GetCodeFragments() machinery.CodeFragmentsMap {
 		return fragments
 	}
 
-	if k8sCfg, err := crconfig.GetConfig(); err != nil {
+	if f.APIResources != nil {
+		f.updateForChart(staticDiscovery(f.APIResources))
+	} else if k8sCfg, err := crconfig.GetConfig(); err != nil {
 		log.Warnf("Using default RBAC rules: failed to get Kubernetes config: %s", err)
 	} else if dc, err := discovery.NewDiscoveryClientForConfig(k8sCfg); err != nil {
 		log.Warnf("Using default RBAC rules: failed to create Kubernetes discovery client: %s", err)
@@ -242,14 +252,23 @@ type roleDiscoveryInterface interface {
 	ServerGroupsAndResources() ([]*metav1.APIGroup, []*metav1.APIResourceList, error)
 }
 
+// staticDiscovery serves a fixed list of API resources, to generate rules
+// without a cluster.
+type staticDiscovery []*metav1.APIResourceList
+
+func (d staticDiscovery) ServerGroupsAndResources() ([]*metav1.APIGroup, []*metav1.APIResourceList, error) {
+	return nil, d, nil
+}
+
 // updateForChart updates the role scaffold from the provided helm chart. It
-// renders a release manifest using the chart's default values and uses the Kubernetes
-// discovery API to lookup each resource in the resulting manifest.
+// renders release manifests using the chart's default values and each of the
+// updater's values, and uses the Kubernetes discovery API to lookup each
+// resource in the resulting manifests.
 // The role scaffold will have IsClusterScoped=true if the chart lists cluster scoped resources
 func (f *ManagerRoleUpdater) updateForChart(dc roleDiscoveryInterface) {
 	fmt.Println("Generating RBAC rules")
 
-	clusterResourceRules, namespacedResourceRules, err := generateRoleRules(dc, f.Chart)
+	clusterResourceRules, namespacedResourceRules, err := generateRoleRules(dc, f.Chart, f.Values...)
 	if err != nil {
 		log.Warnf("Using default RBAC rules: failed to generate RBAC rules: %s", err)
 		return
@@ -259,23 +278,33 @@ func (f *ManagerRoleUpdater) updateForChart(dc roleDiscoveryInterface) {
 	f.CustomRules = append(f.CustomRules, append(clusterResourceRules,
 		namespacedResourceRules...)...)
 
-	log.Warn("The RBAC rules generated in config/rbac/role.yaml are based on the chart's default manifest." +
-		" Some rules may be missing for resources that are only enabled with custom values, and" +
+	log.Warn("The RBAC rules generated in config/rbac/role.yaml are based on the chart's manifests rendered with" +
+		" its default values and any provided values files. Some rules may be missing for resources that are" +
+		" only enabled with other values, and" +
 		" some existing rules may be overly broad. Double check the rules generated in config/rbac/role.yaml" +
 		" to ensure they meet the operator's permission requirements.")
 }
 
-func generateRoleRules(dc roleDiscoveryInterface, chart *chart.Chart) ([]rbacv1.PolicyRule,
+func generateRoleRules(dc roleDiscoveryInterface, chart *chart.Chart, values ...map[string]interface{}) ([]rbacv1.PolicyRule,
 	[]rbacv1.PolicyRule, error) {
 	_, serverResources, err := dc.ServerGroupsAndResources()
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get server resources: %v", err)
 	}
+	// Charts may create custom resources of the CRDs they install.
+	serverResources = append(serverResources, chartCRDResources(chart)...)
 
-	manifests, err := getDefaultManifests(chart)
+	manifests, err := getManifests(chart, nil)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get default manifest: %v", err)
 	}
+	for i, vals := range values {
+		valuesManifests, err := getManifests(chart, vals)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get manifest of values set %d: %v", i+1, err)
+		}
+		manifests = append(manifests, valuesManifests...)
+	}
 
 	// Use maps of sets of resources, keyed by their group. This helps us
 	// de-duplicate resources within a group as we traverse the manifests.
@@ -346,13 +375,13 @@ func generateRoleRules(dc roleDiscoveryInterface, chart *chart.Chart) ([]rbacv1.
 	return clusterRules, namespacedRules, nil
 }
 
-func getDefaultManifests(c *chart.Chart) ([]releaseutil.Manifest, error) {
+func getManifests(c *chart.Chart, vals map[string]interface{}) ([]releaseutil.Manifest, error) {
 	install := action.NewInstall(&action.Configuration{})
 	install.DryRun = true
 	install.ReleaseName = "RELEASE-NAME"
 	install.Replace = true
 	install.ClientOnly = true
-	rel, err := install.Run(c, nil)
+	rel, err := install.Run(c, vals)
 	if err != nil {
 		return nil, fmt.Errorf("failed to render chart templates: %v", err)
 	}
@@ -371,12 +400,71 @@ func getResource(namespacedResourceList []*metav1.APIResourceList, groupVersion,
 			}
 		}
 	}
+	// Resources keep their name and scope across versions, so a version that
+	// is not served, ex. a deprecated one, can be resolved by another version.
+	gv, err := schema.ParseGroupVersion(groupVersion)
+	if err != nil {
+		return "", false, false
+	}
+	for _, apiResourceList := range namespacedResourceList {
+		listGV, err := schema.ParseGroupVersion(apiResourceList.GroupVersion)
+		if err != nil || listGV.Group != gv.Group {
+			continue
+		}
+		for _, apiResource := range apiResourceList.APIResources {
+			if apiResource.Kind == kind {
+				return apiResource.Name, apiResource.Namespaced, true
+			}
+		}
+	}
 	return "", false, false
 }
 
+// chartCRDResources returns the resources of the CRDs in a chart's crds
+// directory.
+func chartCRDResources(c *chart.Chart) []*metav1.APIResourceList {
+	var lists []*metav1.APIResourceList
+	for _, obj := range c.CRDObjects() {
+		for _, doc := range releaseutil.SplitManifests(string(obj.File.Data)) {
+			crd := unstructured.Unstructured{}
+			if err := yaml.Unmarshal([]byte(doc), &crd.Object); err != nil || crd.GetKind() != "CustomResourceDefinition" {
+				continue
+			}
+			group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+			plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+			kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+			scope, _, _ := unstructured.NestedString(crd.Object, "spec", "scope")
+			res := metav1.APIResource{Name: plural, Kind: kind, Namespaced: scope != "Cluster"}
+
+			// v1beta1 CRDs may only set a single version.
+			versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+			if version, ok, _ := unstructured.NestedString(crd.Object, "spec", "version"); ok {
+				versions = append(versions, map[string]interface{}{"name": version})
+			}
+			for _, v := range versions {
+				if version, ok := v.(map[string]interface{}); ok {
+					lists = append(lists, &metav1.APIResourceList{
+						GroupVersion: fmt.Sprintf("%s/%s", group, version["name"]),
+						APIResources: []metav1.APIResource{res},
+					})
+				}
+			}
+		}
+	}
+	return lists
+}
+
 func buildRulesFromGroups(groups map[string]map[string]struct{}) []rbacv1.PolicyRule {
+	// Sort groups so that rules are generated in a stable order.
+	groupNames := make([]string, 0, len(groups))
+	for group := range groups {
+		groupNames = append(groupNames, group)
+	}
+	sort.Strings(groupNames)
+
 	rules := []rbacv1.PolicyRule{}
-	for group, resourceNames := range groups {
+	for _, group := range groupNames {
+		resourceNames := groups[group]
 		resources := []string{}
 		for resource := range resourceNames {
 			resources = append(resources, resource)
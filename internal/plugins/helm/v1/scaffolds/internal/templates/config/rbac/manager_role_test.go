@@ -21,6 +21,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"helm.sh/helm/v3/pkg/chart"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -203,3 +204,86 @@ metadata:
   name: %s`, name),
 	)
 }
+
+func TestGenerateRoleRulesOffline(t *testing.T) {
+	dc := staticDiscovery{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "configmaps", Kind: "ConfigMap", Namespaced: true},
+				{Name: "namespaces", Kind: "Namespace", Namespaced: false},
+			},
+		},
+		{
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "deployments", Kind: "Deployment", Namespaced: true},
+			},
+		},
+	}
+
+	chrt := &chart.Chart{
+		Metadata: &chart.Metadata{
+			Name: "offline",
+		},
+		Values: map[string]interface{}{"namespace": false},
+		Templates: []*chart.File{
+			{Name: "cm.yaml", Data: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm`)},
+			// Deprecated versions resolve to the group's other versions.
+			{Name: "deploy.yaml", Data: []byte(`apiVersion: apps/v1beta2
+kind: Deployment
+metadata:
+  name: deploy`)},
+			{Name: "ns.yaml", Data: []byte(`{{- if .Values.namespace }}
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: ns
+{{- end }}`)},
+			{Name: "widget.yaml", Data: []byte(`apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget`)},
+		},
+		Files: []*chart.File{
+			{Name: "crds/widgets.yaml", Data: []byte(`apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  scope: Cluster
+  versions:
+  - name: v1`)},
+		},
+	}
+
+	clusterRules, namespacedRules, err := generateRoleRules(dc, chrt)
+	assert.NoError(t, err)
+	assert.Equal(t, []rbacv1.PolicyRule{
+		{APIGroups: []string{"example.com"}, Resources: []string{"widgets"}, Verbs: []string{"*"}},
+	}, clusterRules)
+	assert.Equal(t, []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"*"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"*"}},
+	}, namespacedRules)
+
+	// Resources enabled by values are added to those of the default values.
+	clusterRules, _, err = generateRoleRules(dc, chrt, map[string]interface{}{"namespace": true})
+	assert.NoError(t, err)
+	assert.Equal(t, []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"*"}},
+		{APIGroups: []string{"example.com"}, Resources: []string{"widgets"}, Verbs: []string{"*"}},
+	}, clusterRules)
+
+	f := ManagerRoleUpdater{Chart: chrt, Values: []map[string]interface{}{{"namespace": true}}}
+	f.updateForChart(dc)
+	assert.True(t, f.SkipDefaultRules)
+	assert.Equal(t, 4, len(f.CustomRules))
+}